@@ -1,3 +1,4 @@
+// Command validator-demo evaluates a sample rule condition against a sample cart.
 package main
 
 import (
@@ -5,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/na-ho/Work/Validator/01/validator"
 )
 
 func main() {
@@ -62,7 +65,7 @@ func main() {
 
 	// Measure JSON unmarshaling
 	unmarshalStart := time.Now()
-	var condition Condition
+	var condition validator.Condition
 	err := json.Unmarshal([]byte(jsonStr), &condition)
 	unmarshalDuration := time.Since(unmarshalStart)
 
@@ -71,13 +74,13 @@ func main() {
 	}
 
 	// Create a sample cart
-	cart := Cart{
-		Items: []Item{
+	cart := validator.Cart{
+		Items: []validator.Item{
 			{SKU: "1012096", Name: "Product 1", Quantity: 1, Price: 50.0, CategoryIDs: []int{1, 2, 3}},
 			{SKU: "1132342", Name: "Product 2", Quantity: 1, Price: 75.0, CategoryIDs: []int{1, 2, 3}},
 		},
 		Subtotal: 175.0,
-		Customer: Customer{
+		Customer: validator.Customer{
 			ID:      1,
 			GroupID: 2,
 			Email:   "customer@example.com",
@@ -85,11 +88,11 @@ func main() {
 	}
 
 	// Initialize and use the validator
-	validator := NewConditionValidator()
+	conditionValidator := validator.NewConditionValidator()
 
 	// Measure validation time
 	validateStart := time.Now()
-	isValid, err := validator.Validate(condition, cart)
+	isValid, err := conditionValidator.Validate(condition, cart)
 	validateDuration := time.Since(validateStart)
 
 	if err != nil {
@@ -107,21 +110,8 @@ func main() {
 
 	// Explain the condition
 	fmt.Println("\nCondition explanation:")
-	fmt.Println("1. There must be at least 2 items in the cart from categories 1, 2, or 3")
-	fmt.Println("2. The cart subtotal must be at least $100")
-	fmt.Println("3. The customer must belong to group ID 2")
-
-	// Explain the result
-	fmt.Println("\nResult explanation:")
-	if isValid {
-		fmt.Println("The condition is met because:")
-		fmt.Println("- There are 2 items in the cart from the specified categories")
-		fmt.Println("- The cart subtotal ($175.0) is greater than $100")
-		fmt.Println("- The customer belongs to group ID 2")
-	} else {
-		fmt.Println("The condition is not met. One or more of the following is false:")
-		fmt.Println("- There are fewer than 2 items in the cart from the specified categories")
-		fmt.Println("- The cart subtotal is less than $100")
-		fmt.Println("- The customer does not belong to group ID 2")
+	fmt.Print(validator.NewRenderer().Text(condition))
+	if expression, err := validator.FormatExpression(condition); err == nil {
+		fmt.Printf("\nAs an expression:\n%s\n", expression)
 	}
 }
@@ -0,0 +1,33 @@
+// Command validator-repl debugs a sales rule interactively: it loads a rule
+// and a cart, steps through the evaluation of the conditions and lets the
+// cart be changed and evaluated again. Type help for the commands.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/na-ho/Work/Validator/01/repl"
+)
+
+func main() {
+	rule := flag.String("rule", "", "rule file to load")
+	cart := flag.String("cart", "", "cart file to load")
+	flag.Parse()
+
+	session := repl.NewSession(os.Stdout)
+	if *rule != "" {
+		if err := session.LoadRule(*rule); err != nil {
+			log.Fatalf("Error loading rule: %v", err)
+		}
+	}
+	if *cart != "" {
+		if err := session.LoadCart(*cart); err != nil {
+			log.Fatalf("Error loading cart: %v", err)
+		}
+	}
+	if err := session.Run(os.Stdin); err != nil {
+		log.Fatalf("Error reading commands: %v", err)
+	}
+}
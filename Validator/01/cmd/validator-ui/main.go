@@ -0,0 +1,34 @@
+// Command validator-ui serves the browser UI for authoring sales rules, saving
+// them as JSON files in a rule directory.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+	"github.com/na-ho/Work/Validator/01/web"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	dir := flag.String("rules", "rules", "directory of rule files")
+	flag.Parse()
+
+	store, err := validator.NewDirectoryStore(*dir)
+	if err != nil {
+		log.Fatalf("Error opening rule directory: %v", err)
+	}
+	repo := validator.NewRuleRepository(store)
+	defer repo.Close()
+
+	router := gin.Default()
+	web.NewServer(repo).Register(router)
+
+	log.Printf("Rule editor listening on %s, saving rules to %s", *addr, *dir)
+	if err := router.Run(*addr); err != nil {
+		log.Fatalf("Error serving: %v", err)
+	}
+}
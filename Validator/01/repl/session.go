@@ -0,0 +1,488 @@
+// Package repl is an interactive debugger for sales rules: it loads a rule and
+// a cart, steps through the evaluation of the rule conditions one node at a
+// time, shows the attribute values the conditions compare and lets the cart
+// be changed and evaluated again without recompiling anything.
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+)
+
+// ErrQuit is returned by Execute for the quit command.
+var ErrQuit = errors.New("quit")
+
+const help = `Commands:
+  load rule <file>     load a rule, a rule history or a bare condition tree
+  load cart <file>     load a cart
+  run                  evaluate the rule against the cart
+  step [n]             show the next n evaluated conditions, 1 by default
+  trace                show every evaluated condition
+  inspect              show the attribute of the last shown condition for each item
+  inspect <attribute>  show a product attribute for each item
+  inspect item <i>     show the product attributes of item i
+  set <path> <value>   change a cart field, e.g. set item[0].quantity 3
+  show rule|cart       show the rule conditions or the cart as JSON
+  help                 show this help
+  quit                 leave
+`
+
+// Session holds the rule, the cart and the evaluation being stepped through.
+type Session struct {
+	out      io.Writer
+	cv       *validator.ConditionValidator
+	recorder *validator.TraceRecorder
+	rule     *validator.Rule
+	cart     *validator.Cart
+	steps    []validator.TraceStep // Steps of the last evaluation, nil once the rule or cart changes
+	next     int                   // Position of the next step to show
+}
+
+// NewSession creates a Session writing its output to out.
+func NewSession(out io.Writer) *Session {
+	recorder := validator.NewTraceRecorder()
+	return &Session{
+		out:      out,
+		cv:       validator.NewConditionValidator().WithObserver(recorder),
+		recorder: recorder,
+	}
+}
+
+// WithSegmentProvider makes evaluations look up customer segments with the provider.
+func (s *Session) WithSegmentProvider(provider validator.SegmentProvider) *Session {
+	s.cv.WithSegmentProvider(provider)
+	return s
+}
+
+// Run reads commands from in until it ends or a quit command, printing the
+// errors of failed commands and carrying on.
+func (s *Session) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(s.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(s.out)
+			return scanner.Err()
+		}
+		if err := s.Execute(scanner.Text()); errors.Is(err, ErrQuit) {
+			return nil
+		} else if err != nil {
+			fmt.Fprintf(s.out, "error: %v\n", err)
+		}
+	}
+}
+
+// Execute runs one command line.
+func (s *Session) Execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	args := fields[1:]
+	switch fields[0] {
+	case "load":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: load rule|cart <file>")
+		}
+		switch args[0] {
+		case "rule":
+			return s.LoadRule(args[1])
+		case "cart":
+			return s.LoadCart(args[1])
+		}
+		return fmt.Errorf("cannot load %s, only a rule or a cart", args[0])
+	case "run":
+		return s.run()
+	case "step":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				return fmt.Errorf("invalid step count %s", args[0])
+			}
+		}
+		return s.step(n)
+	case "trace":
+		return s.trace()
+	case "inspect":
+		return s.inspect(args)
+	case "set":
+		// The value is the rest of the line, so that it may hold spaces
+		rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "set"))
+		path, value, ok := strings.Cut(rest, " ")
+		if !ok {
+			return fmt.Errorf("usage: set <path> <value>")
+		}
+		return s.set(path, strings.TrimSpace(value))
+	case "show":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: show rule|cart")
+		}
+		return s.show(args[0])
+	case "help":
+		fmt.Fprint(s.out, help)
+		return nil
+	case "quit", "exit":
+		return ErrQuit
+	default:
+		return fmt.Errorf("unknown command %s, try help", fields[0])
+	}
+}
+
+// LoadRule loads a rule from a JSON file holding a rule, a rule history as
+// saved by a DirectoryStore or a bare condition tree, which becomes the
+// conditions of an active rule.
+func (s *Session) LoadRule(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rule, err := parseRule(data, filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if err := s.cv.CheckLimits(rule.Conditions); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	s.rule = &rule
+	s.steps = nil
+	fmt.Fprintf(s.out, "Loaded rule %d %q: %s\n", rule.ID, rule.Name, validator.NewRenderer().Text(rule.Conditions))
+	if !validator.IsEmptyCondition(rule.Conditions) {
+		for _, diagnostic := range validator.NewLinter().Lint(rule.Conditions) {
+			fmt.Fprintf(s.out, "  %s\n", diagnostic)
+		}
+	}
+	return nil
+}
+
+// parseRule decodes the rule of a rule file.
+func parseRule(data []byte, name string) (validator.Rule, error) {
+	var rule validator.Rule
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var versions []validator.RuleVersion
+		if err := json.Unmarshal(data, &versions); err != nil {
+			return rule, fmt.Errorf("invalid rule history: %v", err)
+		}
+		if len(versions) == 0 {
+			return rule, fmt.Errorf("the rule history is empty")
+		}
+		latest := versions[len(versions)-1]
+		if latest.Deleted {
+			return rule, fmt.Errorf("rule %d is deleted", latest.RuleID)
+		}
+		return latest.Rule, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return rule, fmt.Errorf("invalid rule: %v", err)
+	}
+	if _, ok := fields["type"]; ok {
+		var condition validator.Condition
+		if err := json.Unmarshal(data, &condition); err != nil {
+			return rule, fmt.Errorf("invalid condition: %v", err)
+		}
+		return validator.Rule{Name: name, IsActive: true, Conditions: condition}, nil
+	}
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return rule, fmt.Errorf("invalid rule: %v", err)
+	}
+	return rule, nil
+}
+
+// LoadCart loads a cart from a JSON file.
+func (s *Session) LoadCart(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var cart validator.Cart
+	if err := json.Unmarshal(data, &cart); err != nil {
+		return fmt.Errorf("%s: invalid cart: %v", path, err)
+	}
+	s.cart = &cart
+	s.steps = nil
+	fmt.Fprintf(s.out, "Loaded cart with %d items, subtotal %.2f\n", len(cart.Items), cart.Subtotal)
+	return nil
+}
+
+// run evaluates the rule against the cart and rewinds to the first step.
+func (s *Session) run() error {
+	if s.rule == nil {
+		return fmt.Errorf("no rule loaded, use load rule <file>")
+	}
+	if s.cart == nil {
+		return fmt.Errorf("no cart loaded, use load cart <file>")
+	}
+	// A rule being debugged is evaluated even when inactive
+	rule := *s.rule
+	rule.IsActive = true
+
+	s.recorder.Reset()
+	matched, err := s.cv.ValidateRule(rule, *s.cart)
+	s.steps = append([]validator.TraceStep{}, s.recorder.Steps()...)
+	s.next = 0
+	if err != nil {
+		fmt.Fprintf(s.out, "Evaluation failed after %d conditions: %v\n", len(s.steps), err)
+	} else if matched {
+		fmt.Fprint(s.out, "The rule matches the cart")
+		if discount, err := s.cv.CalculateDiscount(rule, *s.cart); err == nil {
+			fmt.Fprintf(s.out, ", giving a discount of %.2f", discount)
+		}
+		fmt.Fprintln(s.out)
+	} else {
+		fmt.Fprintln(s.out, "The rule does not match the cart")
+	}
+	if variant := rule.Variant(s.cart.Customer); variant != "" {
+		fmt.Fprintf(s.out, "Experiment variant %s\n", variant)
+	}
+	if !s.rule.IsActive {
+		fmt.Fprintln(s.out, "Note: the rule is inactive")
+	}
+	switch {
+	case len(s.steps) > 0:
+		fmt.Fprintf(s.out, "%d conditions evaluated, use step to walk through them\n", len(s.steps))
+	case matched:
+		fmt.Fprintln(s.out, "The rule has no conditions, so it matches every cart in its scope")
+	case err == nil:
+		fmt.Fprintln(s.out, "No conditions were evaluated: the rule is out of scope, dates or experiment variant for the cart")
+	}
+	return nil
+}
+
+// step shows the next n steps, evaluating first if the rule or cart changed.
+func (s *Session) step(n int) error {
+	if s.steps == nil {
+		if err := s.run(); err != nil {
+			return err
+		}
+	}
+	if s.next >= len(s.steps) {
+		fmt.Fprintln(s.out, "End of evaluation, use run to start over")
+		return nil
+	}
+	for ; n > 0 && s.next < len(s.steps); n-- {
+		s.printStep(s.next, true)
+		s.next++
+	}
+	return nil
+}
+
+// trace shows every step of the evaluation.
+func (s *Session) trace() error {
+	if s.steps == nil {
+		if err := s.run(); err != nil {
+			return err
+		}
+	}
+	for i := range s.steps {
+		s.printStep(i, false)
+	}
+	return nil
+}
+
+// printStep shows a step, with the value of its attribute when resolved.
+func (s *Session) printStep(i int, resolve bool) {
+	step := s.steps[i]
+	outcome := strconv.FormatBool(step.Result)
+	if step.Error != "" {
+		outcome = "error: " + step.Error
+	}
+	item := ""
+	if step.Item != "" {
+		item = " [" + strings.TrimSpace(step.ItemPath+" "+step.Item) + "]"
+	}
+	indent := strings.Repeat("  ", step.Depth)
+	fmt.Fprintf(s.out, "%3d %s%s%s => %s\n", i+1, indent, step.Description, item, outcome)
+	if !resolve || step.Condition.Attribute == "" {
+		return
+	}
+	value, err := s.resolve(step.Condition, s.findItem(step.ItemPath))
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "    %s%s = %s\n", indent, step.Condition.Attribute, formatValue(value))
+}
+
+// resolve returns the value a condition compares for the cart and item,
+// reading configurable items through their selected child as the validator does.
+func (s *Session) resolve(condition validator.Condition, item *validator.Item) (interface{}, error) {
+	return s.cv.ResolveCondition(condition, *s.cart, item)
+}
+
+// findItem returns the item at a position recorded in the trace, as the
+// conditions saw it, or nil if there is none.
+func (s *Session) findItem(path string) *validator.Item {
+	if path == "" {
+		return nil
+	}
+	item, err := s.cv.ItemAt(*s.cart, path)
+	if err != nil {
+		return nil
+	}
+	return &item
+}
+
+// inspect shows resolved attribute values.
+func (s *Session) inspect(args []string) error {
+	if s.cart == nil {
+		return fmt.Errorf("no cart loaded, use load cart <file>")
+	}
+	switch {
+	case len(args) == 0:
+		if s.steps == nil || s.next == 0 {
+			return fmt.Errorf("no condition shown yet, use step or name an attribute")
+		}
+		condition := s.steps[s.next-1].Condition
+		if condition.Attribute == "" {
+			return fmt.Errorf("the condition compares no attribute")
+		}
+		if condition.Type != validator.TypeProduct {
+			value, err := s.resolve(condition, nil)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(s.out, "%s = %s\n", condition.Attribute, formatValue(value))
+			return nil
+		}
+		return s.inspectItems(condition)
+	case args[0] == "item":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: inspect item <i>")
+		}
+		i, err := strconv.Atoi(args[1])
+		if err != nil || i < 0 || i >= len(s.cart.Items) {
+			return fmt.Errorf("no item %s, the cart has %d items", args[1], len(s.cart.Items))
+		}
+		return s.inspectItem(i)
+	default:
+		return s.inspectItems(validator.Condition{Type: validator.TypeProduct, Attribute: args[0]})
+	}
+}
+
+// inspectItems shows the attribute of a product condition for each item. A
+// condition without an operator shows the attributes of the items themselves.
+func (s *Session) inspectItems(condition validator.Condition) error {
+	for i := range s.cart.Items {
+		var value interface{}
+		var err error
+		if condition.Operator == "" {
+			value, err = s.cv.ResolveAttribute(condition.Type, condition.Attribute, *s.cart, &s.cart.Items[i])
+		} else {
+			value, err = s.resolve(condition, &s.cart.Items[i])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "item[%d] %s: %s = %s\n", i, s.cart.Items[i].SKU, condition.Attribute, formatValue(value))
+	}
+	return nil
+}
+
+// inspectItem shows the known and custom product attributes of an item.
+func (s *Session) inspectItem(i int) error {
+	item := &s.cart.Items[i]
+	attributes := validator.KnownAttributes(validator.TypeProduct)
+	known := make(map[string]bool, len(attributes))
+	for _, attribute := range attributes {
+		known[attribute] = true
+	}
+	custom := make([]string, 0, len(item.Attributes))
+	for attribute := range item.Attributes {
+		if !known[attribute] {
+			custom = append(custom, attribute)
+		}
+	}
+	sort.Strings(custom)
+
+	fmt.Fprintf(s.out, "item[%d] %s\n", i, item.SKU)
+	for _, attribute := range append(attributes, custom...) {
+		value, err := s.cv.ResolveAttribute(validator.TypeProduct, attribute, *s.cart, item)
+		if err != nil {
+			fmt.Fprintf(s.out, "  %s: error: %v\n", attribute, err)
+			continue
+		}
+		fmt.Fprintf(s.out, "  %s = %s\n", attribute, formatValue(value))
+	}
+	return nil
+}
+
+// set changes a cart field, invalidating the last evaluation.
+func (s *Session) set(path, value string) error {
+	if s.cart == nil {
+		return fmt.Errorf("no cart loaded, use load cart <file>")
+	}
+	subtotal := s.cart.Subtotal
+	if err := setCartField(s.cart, path, value); err != nil {
+		return err
+	}
+	s.steps = nil
+	fmt.Fprintf(s.out, "%s = %s\n", path, value)
+	if s.cart.Subtotal != subtotal {
+		fmt.Fprintf(s.out, "subtotal = %.2f\n", s.cart.Subtotal)
+	}
+	return nil
+}
+
+// show prints the rule conditions or the cart.
+func (s *Session) show(what string) error {
+	switch what {
+	case "rule":
+		if s.rule == nil {
+			return fmt.Errorf("no rule loaded, use load rule <file>")
+		}
+		fmt.Fprintln(s.out, validator.NewRenderer().Text(s.rule.Conditions))
+		if expression, err := validator.FormatExpression(s.rule.Conditions); err == nil {
+			fmt.Fprintln(s.out, expression)
+		} else {
+			fmt.Fprintf(s.out, "(no expression: %v)\n", err)
+		}
+		return nil
+	case "cart":
+		if s.cart == nil {
+			return fmt.Errorf("no cart loaded, use load cart <file>")
+		}
+		data, err := json.MarshalIndent(s.cart, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, string(data))
+		return nil
+	}
+	return fmt.Errorf("cannot show %s, only the rule or the cart", what)
+}
+
+// formatValue formats a resolved attribute value.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return strconv.Quote(v)
+	case time.Time:
+		if v.IsZero() {
+			return "(none)"
+		}
+		return v.Format(time.RFC3339)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = formatValue(element)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,255 @@
+package repl
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRule = `{
+	"id": 7,
+	"name": "Two tees",
+	"is_active": true,
+	"simple_action": "by_percent",
+	"discount_amount": 10,
+	"conditions": {
+		"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+		"aggregator": "all",
+		"value": "1",
+		"conditions": [
+			{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"aggregator": "all",
+				"value": "1",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "TEE"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "quote_item_qty", "operator": ">=", "value": "2"}
+				]
+			}
+		]
+	}
+}`
+
+const testCart = `{
+	"Items": [
+		{"SKU": "MUG", "Quantity": 1, "Price": 12},
+		{"SKU": "TEE", "Quantity": 1, "Price": 20, "Attributes": {"color": "red"}}
+	],
+	"Subtotal": 32
+}`
+
+// newTestSession returns a session with the test rule and cart loaded.
+func newTestSession(t *testing.T) (*Session, *bytes.Buffer) {
+	t.Helper()
+	dir := t.TempDir()
+	rule := filepath.Join(dir, "rule.json")
+	cart := filepath.Join(dir, "cart.json")
+	if err := os.WriteFile(rule, []byte(testRule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cart, []byte(testCart), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	session := NewSession(&out)
+	if err := session.Execute("load rule " + rule); err != nil {
+		t.Fatalf("load rule: %v", err)
+	}
+	if err := session.Execute("load cart " + cart); err != nil {
+		t.Fatalf("load cart: %v", err)
+	}
+	out.Reset()
+	return session, &out
+}
+
+func TestSessionStepAndRerun(t *testing.T) {
+	session, out := newTestSession(t)
+
+	if err := session.Execute("run"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(out.String(), "does not match") {
+		t.Errorf("run output = %q, want no match", out.String())
+	}
+
+	out.Reset()
+	for i := 0; i < 4; i++ {
+		if err := session.Execute("step"); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+	for _, want := range []string{
+		"[items[0] MUG] => false",
+		`sku = "MUG"`,
+		"[items[1] TEE] => true",
+		`sku = "TEE"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("step output = %q, want %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if err := session.Execute("inspect"); err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+	if want := `item[1] TEE: sku = "TEE"`; !strings.Contains(out.String(), want) {
+		t.Errorf("inspect output = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := session.Execute("set item[1].quantity 3"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if !strings.Contains(out.String(), "subtotal = 72.00") {
+		t.Errorf("set output = %q, want the adjusted subtotal", out.String())
+	}
+
+	out.Reset()
+	if err := session.Execute("run"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := "The rule matches the cart, giving a discount of"; !strings.Contains(out.String(), want) {
+		t.Errorf("run output after set = %q, want %q", out.String(), want)
+	}
+}
+
+func TestSessionStepItems(t *testing.T) {
+	session, out := newTestSession(t)
+	cart := filepath.Join(t.TempDir(), "cart.json")
+	data := `{"Items": [
+		{"SKU": "TEE", "Quantity": 1, "Price": 20},
+		{"SKU": "TEE-PARENT", "ProductType": "configurable", "Quantity": 3, "Price": 20, "Children": [{"SKU": "TEE"}]}
+	]}`
+	if err := os.WriteFile(cart, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Execute("load cart " + cart); err != nil {
+		t.Fatalf("load cart: %v", err)
+	}
+
+	out.Reset()
+	for i := 0; i < 6; i++ {
+		if err := session.Execute("step"); err != nil {
+			t.Fatalf("step: %v", err)
+		}
+	}
+	for _, want := range []string{
+		"[items[0] TEE] => false\n        quote_item_qty = 1",
+		"[items[1] TEE-PARENT] => true\n        sku = \"TEE\"",
+		"[items[1] TEE-PARENT] => true\n        quote_item_qty = 3",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("step output = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestSessionRuleWithoutConditions(t *testing.T) {
+	session, out := newTestSession(t)
+	rule := filepath.Join(t.TempDir(), "rule.json")
+	data := `{"id": 8, "name": "Everything", "is_active": true, "simple_action": "by_percent", "discount_amount": 10}`
+	if err := os.WriteFile(rule, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Execute("load rule " + rule); err != nil {
+		t.Fatalf("load rule: %v", err)
+	}
+	if strings.Contains(out.String(), "unknown condition type") {
+		t.Errorf("load rule output = %q, want no lint diagnostics", out.String())
+	}
+
+	out.Reset()
+	if err := session.Execute("run"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if want := "The rule matches the cart, giving a discount of 3.20"; !strings.Contains(out.String(), want) {
+		t.Errorf("run output = %q, want %q", out.String(), want)
+	}
+	if strings.Contains(out.String(), "out of scope") {
+		t.Errorf("run output = %q, want no out-of-scope note for a matching rule", out.String())
+	}
+}
+
+func TestSessionCommands(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string // Part of the output
+		wantErr bool
+	}{
+		{line: "inspect item 1", want: `color = "red"`},
+		{line: "inspect row_total", want: "item[1] TEE: row_total = 20"},
+		{line: "trace", want: "If ALL of these conditions are TRUE"},
+		{line: "show rule", want: "SKU is TEE"},
+		{line: "show cart", want: `"SKU": "MUG"`},
+		{line: "set customer.group_id 3", want: "customer.group_id = 3"},
+		{line: "help", want: "load rule <file>"},
+		{line: "inspect", wantErr: true},
+		{line: "inspect item 5", wantErr: true},
+		{line: "step none", wantErr: true},
+		{line: "set item[0].quantity many", wantErr: true},
+		{line: "show everything", wantErr: true},
+		{line: "dance", wantErr: true},
+	}
+	for _, tt := range tests {
+		session, out := newTestSession(t)
+		err := session.Execute(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Execute(%q) error = %v, want error %v", tt.line, err, tt.wantErr)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("Execute(%q) output = %q, want %q", tt.line, out.String(), tt.want)
+		}
+	}
+}
+
+func TestSessionRun(t *testing.T) {
+	session, out := newTestSession(t)
+	err := session.Run(strings.NewReader("step\nbogus\nquit\nstep\n"))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if strings.Contains(out.String(), "FOUND") || !strings.HasSuffix(out.String(), "> ") {
+		t.Errorf("Run() output = %q, want it to stop at quit", out.String())
+	}
+	if !strings.Contains(out.String(), "error: unknown command bogus") {
+		t.Errorf("Run() output = %q, want the error of the unknown command", out.String())
+	}
+	if err := session.Execute("exit"); !errors.Is(err, ErrQuit) {
+		t.Errorf("Execute(exit) = %v, want ErrQuit", err)
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	condition := `{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "50"}`
+	tests := []struct {
+		name    string
+		data    string
+		wantID  int
+		wantErr bool
+	}{
+		{name: "rule", data: testRule, wantID: 7},
+		{name: "condition", data: condition},
+		{name: "history", data: `[{"rule_id": 3, "version": 1, "rule": {"id": 3}}, {"rule_id": 3, "version": 2, "rule": {"id": 3, "name": "latest"}}]`, wantID: 3},
+		{name: "deleted", data: `[{"rule_id": 3, "version": 1, "rule": {"id": 3}, "deleted": true}]`, wantErr: true},
+		{name: "empty history", data: `[]`, wantErr: true},
+		{name: "invalid", data: `{"id": "seven"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseRule([]byte(tt.data), "rule.json")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRule() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && rule.ID != tt.wantID {
+				t.Errorf("parseRule() ID = %d, want %d", rule.ID, tt.wantID)
+			}
+		})
+	}
+	if rule, _ := parseRule([]byte(condition), "rule.json"); !rule.IsActive || rule.Conditions.Attribute != "base_subtotal" {
+		t.Errorf("parseRule() of a condition = %+v, want an active rule with the condition", rule)
+	}
+}
@@ -0,0 +1,217 @@
+package repl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+)
+
+// fieldAliases maps the short names accepted in set paths to cart fields.
+var fieldAliases = map[string]string{
+	"item":    "items",
+	"qty":     "quantity",
+	"address": "shippingaddress",
+	"billing": "billingaddress",
+}
+
+// setSegment is one dot-separated segment of a set path, such as item[0].
+type setSegment struct {
+	name  string
+	index int // -1 without an index
+}
+
+// parseSetPath splits a set path such as item[0].attributes.color into segments.
+func parseSetPath(path string) ([]setSegment, error) {
+	var segments []setSegment
+	for _, part := range strings.Split(path, ".") {
+		segment := setSegment{name: part, index: -1}
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("invalid path %s", path)
+			}
+			index, err := strconv.Atoi(part[open+1 : len(part)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid index in %s", part)
+			}
+			segment.name, segment.index = part[:open], index
+		}
+		if segment.name == "" {
+			return nil, fmt.Errorf("invalid path %s", path)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// setCartField sets the cart field at the path, named in snake_case or as
+// the Go field, from its text value. Indexing one past the end of a list
+// appends to it. Changing the price or quantity of an item, or adding one,
+// moves the subtotal by the change of its row total, as an update of the
+// cart would.
+func setCartField(cart *validator.Cart, path, value string) error {
+	segments, err := parseSetPath(path)
+	if err != nil {
+		return err
+	}
+	item := -1
+	if normalizeName(segments[0].name) == "items" && segments[0].index >= 0 && len(segments) > 1 {
+		item = segments[0].index
+	}
+	before := rowTotal(cart, item)
+
+	target := reflect.ValueOf(cart).Elem()
+	for i, segment := range segments {
+		if target.Kind() == reflect.Map {
+			if i != len(segments)-1 || segment.index >= 0 {
+				return fmt.Errorf("%s: only a value can be set in %s", path, segments[i-1].name)
+			}
+			return setMapValue(target, segment.name, value)
+		}
+		if target, err = structField(target, segment.name); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if segment.index >= 0 {
+			if target, err = listElement(target, segment); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+	if err := setValue(target, value); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	if item >= 0 {
+		cart.Subtotal += rowTotal(cart, item) - before
+	}
+	return nil
+}
+
+// rowTotal returns the price times the quantity of an item, 0 if there is no such item.
+func rowTotal(cart *validator.Cart, i int) float64 {
+	if i < 0 || i >= len(cart.Items) {
+		return 0
+	}
+	return cart.Items[i].Price * float64(cart.Items[i].Quantity)
+}
+
+// normalizeName lowercases a name and drops its underscores, resolving aliases.
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	if alias, ok := fieldAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// structField returns the named field of a struct.
+func structField(value reflect.Value, name string) (reflect.Value, error) {
+	if value.Kind() != reflect.Struct || value.Type() == reflect.TypeOf(time.Time{}) {
+		return reflect.Value{}, fmt.Errorf("%s is not a field of %s", name, value.Type())
+	}
+	want := normalizeName(name)
+	for i := 0; i < value.NumField(); i++ {
+		if strings.ToLower(value.Type().Field(i).Name) == want {
+			return value.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("%s has no field %s", value.Type().Name(), name)
+}
+
+// listElement returns an element of a list, appending a zero element when
+// the index is one past its end.
+func listElement(list reflect.Value, segment setSegment) (reflect.Value, error) {
+	if list.Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("%s is not a list", segment.name)
+	}
+	switch {
+	case segment.index < list.Len():
+	case segment.index == list.Len():
+		list.Set(reflect.Append(list, reflect.Zero(list.Type().Elem())))
+	default:
+		return reflect.Value{}, fmt.Errorf("%s has %d elements, no index %d", segment.name, list.Len(), segment.index)
+	}
+	return list.Index(segment.index), nil
+}
+
+// setMapValue sets a key of an attribute map, creating the map if needed.
+func setMapValue(attributes reflect.Value, key, text string) error {
+	if attributes.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot set %s in %s", key, attributes.Type())
+	}
+	if attributes.IsNil() {
+		attributes.Set(reflect.MakeMap(attributes.Type()))
+	}
+	element := reflect.New(attributes.Type().Elem()).Elem()
+	if err := setValue(element, text); err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	attributes.SetMapIndex(reflect.ValueOf(key), element)
+	return nil
+}
+
+// setValue parses text as a value of the kind of target and stores it.
+// Lists are comma-separated; untyped values become numbers, booleans or
+// strings, as they would when decoded from JSON.
+func setValue(target reflect.Value, text string) error {
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	if target.Type() == reflect.TypeOf(time.Time{}) {
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				target.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", text)
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(text)
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", text)
+		}
+		target.SetInt(n)
+	case reflect.Float64, reflect.Float32:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		target.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+		target.SetBool(b)
+	case reflect.Slice:
+		list := reflect.MakeSlice(target.Type(), 0, 0)
+		if strings.TrimSpace(text) != "" {
+			for _, part := range strings.Split(text, ",") {
+				element := reflect.New(target.Type().Elem()).Elem()
+				if err := setValue(element, strings.TrimSpace(part)); err != nil {
+					return err
+				}
+				list = reflect.Append(list, element)
+			}
+		}
+		target.Set(list)
+	case reflect.Interface:
+		var value interface{} = text
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			value = f
+		} else if b, err := strconv.ParseBool(text); err == nil {
+			value = b
+		}
+		target.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("cannot set a %s", target.Type())
+	}
+	return nil
+}
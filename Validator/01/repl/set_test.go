@@ -0,0 +1,53 @@
+package repl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+)
+
+func TestSetCartField(t *testing.T) {
+	tests := []struct {
+		path    string
+		value   string
+		check   func(validator.Cart) interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{path: "item[0].quantity", value: "3", check: func(c validator.Cart) interface{} { return c.Items[0].Quantity }, want: 3},
+		{path: "items[0].qty", value: "4", check: func(c validator.Cart) interface{} { return c.Subtotal }, want: 80.0},
+		{path: "item[0].price", value: "25", check: func(c validator.Cart) interface{} { return c.Subtotal }, want: 25.0},
+		{path: "item[0].name", value: `"Red tee"`, check: func(c validator.Cart) interface{} { return c.Items[0].Name }, want: "Red tee"},
+		{path: "item[0].category_ids", value: "3, 12", check: func(c validator.Cart) interface{} { return c.Items[0].CategoryIDs }, want: []int{3, 12}},
+		{path: "item[0].attributes.color", value: "blue", check: func(c validator.Cart) interface{} { return c.Items[0].Attributes["color"] }, want: "blue"},
+		{path: "item[0].attributes.size", value: "42", check: func(c validator.Cart) interface{} { return c.Items[0].Attributes["size"] }, want: 42.0},
+		{path: "item[1].sku", value: "MUG", check: func(c validator.Cart) interface{} { return len(c.Items) }, want: 2},
+		{path: "ShippingAddress.Country", value: "DE", check: func(c validator.Cart) interface{} { return c.ShippingAddress.Country }, want: "DE"},
+		{path: "customer.is_subscribed", value: "true", check: func(c validator.Cart) interface{} { return c.Customer.IsSubscribed }, want: true},
+		{path: "customer.addresses[0].city", value: "Austin", check: func(c validator.Cart) interface{} { return c.Customer.Addresses[0].City }, want: "Austin"},
+		{path: "created_at", value: "2024-05-01", check: func(c validator.Cart) interface{} { return c.CreatedAt }, want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{path: "subtotal", value: "99.5", check: func(c validator.Cart) interface{} { return c.Subtotal }, want: 99.5},
+		{path: "item[5].sku", value: "MUG", wantErr: true},
+		{path: "item[0].colour", value: "red", wantErr: true},
+		{path: "item[0].quantity", value: "three", wantErr: true},
+		{path: "item[0].attributes.color.shade", value: "dark", wantErr: true},
+		{path: "created_at", value: "yesterday", wantErr: true},
+		{path: "customer[0]", value: "1", wantErr: true},
+		{path: "item[].sku", value: "MUG", wantErr: true},
+	}
+	for _, tt := range tests {
+		cart := validator.Cart{Subtotal: 20, Items: []validator.Item{{SKU: "TEE", Quantity: 1, Price: 20}}}
+		err := setCartField(&cart, tt.path, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("setCartField(%s, %s) error = %v, want error %v", tt.path, tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil {
+			if got := tt.check(cart); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("setCartField(%s, %s) set %#v, want %#v", tt.path, tt.value, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,693 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrTooComplex is returned when a condition tree expands into more
+// alternatives than the Analyzer is willing to explore.
+var ErrTooComplex = errors.New("condition too complex to analyze")
+
+// Attribute codes that name the same value
+var attributeAliases = map[string]string{
+	"qty":                  "quantity",
+	"quote_item_qty":       "quantity",
+	"quote_item_price":     "price",
+	"base_row_total":       "row_total",
+	"quote_item_row_total": "row_total",
+	"subtotal":             "base_subtotal",
+	"country_id":           "country",
+}
+
+// Item attributes that a configurable child shares with its parent. Other item
+// attributes may match on the parent for one condition and on the child for
+// the next, so they do not constrain the item.
+var sharedItemAttributes = map[string]bool{
+	"quantity": true, "price": true, "final_price": true, "row_total": true,
+}
+
+// Operators with the opposite result
+var negatedOperators = map[string]string{
+	"==": "!=", "!=": "==",
+	">": "<=", "<=": ">",
+	"<": ">=", ">=": "<",
+	"()": "!()", "!()": "()",
+	"{}": "!{}", "!{}": "{}",
+	"like": "nlike", "nlike": "like",
+	"null": "notnull", "notnull": "null",
+}
+
+// atom is a single constraint of a conjunction. Number and string atoms
+// constrain a variable; opaque atoms are only compared with each other.
+type atom struct {
+	key      string
+	kind     AttributeKind // KindNumber, KindString, or "" for opaque
+	operator string
+	number   float64
+	numbers  []float64
+	text     string
+	texts    []string
+	negated  bool // For opaque atoms
+	minZero  bool // The variable is never below zero
+}
+
+// formula is a condition tree reduced to and/or over atoms.
+type formula struct {
+	op       string // "and", "or", "atom", "true" or "false"
+	children []formula
+	atom     atom
+}
+
+var (
+	formulaTrue  = formula{op: "true"}
+	formulaFalse = formula{op: "false"}
+)
+
+// Analyzer reasons about condition trees without a cart, using intervals for
+// numeric and date attributes and value sets for string attributes. It finds
+// rules that can never match, rules that match whenever another does, and
+// rules that can match the same cart.
+//
+// The analysis is conservative: conditions it cannot reason about, such as
+// "contains", are only matched against themselves, and item attributes such as
+// the SKU, which a configurable item may match through its parent or its
+// child, are ignored. A rule may be reported satisfiable or overlapping when
+// it is not, but never the other way round.
+type Analyzer struct {
+	cv       *ConditionValidator
+	maxTerms int
+}
+
+// NewAnalyzer creates a new Analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{cv: NewConditionValidator(), maxTerms: 4096}
+}
+
+// Satisfiable reports whether some cart can match the condition.
+func (a *Analyzer) Satisfiable(condition Condition) (bool, error) {
+	terms, err := a.terms(a.build(condition, false, false))
+	if err != nil {
+		return false, err
+	}
+	return len(terms) > 0, nil
+}
+
+// Implies reports whether every cart matching condition also matches other.
+func (a *Analyzer) Implies(condition, other Condition) (bool, error) {
+	terms, err := a.terms(a.build(condition, false, false))
+	if err != nil {
+		return false, err
+	}
+	counterTerms, err := a.terms(a.build(other, true, false))
+	if err != nil {
+		return false, err
+	}
+	return !a.anyConsistent(terms, counterTerms), nil
+}
+
+// Overlaps reports whether some cart can match both conditions.
+func (a *Analyzer) Overlaps(condition, other Condition) (bool, error) {
+	terms, err := a.terms(a.build(condition, false, false))
+	if err != nil {
+		return false, err
+	}
+	otherTerms, err := a.terms(a.build(other, false, false))
+	if err != nil {
+		return false, err
+	}
+	return a.anyConsistent(terms, otherTerms), nil
+}
+
+// RuleImplication records that a rule matches every cart another rule matches.
+type RuleImplication struct {
+	RuleID   int
+	ByRuleID int
+}
+
+// RuleOverlap records a pair of rules that can match the same cart.
+type RuleOverlap struct {
+	RuleID      int
+	OtherRuleID int
+}
+
+// RuleAnalysis is the result of analyzing a set of rules.
+type RuleAnalysis struct {
+	Unsatisfiable []int // Rules that can never match
+	Implied       []RuleImplication
+	Overlaps      []RuleOverlap
+	Skipped       []int // Rules too complex to analyze
+}
+
+// AnalyzeRules analyzes the conditions of active rules. Rules whose date ranges,
+// websites or customer groups do not intersect are never reported as
+// overlapping or implied.
+func (a *Analyzer) AnalyzeRules(rules []Rule) *RuleAnalysis {
+	analysis := &RuleAnalysis{}
+	terms := make([][][]atom, len(rules))
+	negated := make([][][]atom, len(rules))
+	var candidates []int
+	for i, rule := range rules {
+		if !rule.IsActive {
+			continue
+		}
+		var err error
+		if terms[i], err = a.terms(a.build(rule.Conditions, false, false)); err == nil {
+			negated[i], err = a.terms(a.build(rule.Conditions, true, false))
+		}
+		switch {
+		case err != nil:
+			analysis.Skipped = append(analysis.Skipped, rule.ID)
+		case len(terms[i]) == 0:
+			analysis.Unsatisfiable = append(analysis.Unsatisfiable, rule.ID)
+		default:
+			candidates = append(candidates, i)
+		}
+	}
+
+	for x, i := range candidates {
+		for _, j := range candidates[x+1:] {
+			if !a.schedulesOverlap(rules[i], rules[j]) || !scopesOverlap(rules[i], rules[j]) || !a.anyConsistent(terms[i], terms[j]) {
+				continue
+			}
+			analysis.Overlaps = append(analysis.Overlaps, RuleOverlap{RuleID: rules[i].ID, OtherRuleID: rules[j].ID})
+			if !a.anyConsistent(terms[j], negated[i]) {
+				analysis.Implied = append(analysis.Implied, RuleImplication{RuleID: rules[i].ID, ByRuleID: rules[j].ID})
+			}
+			if !a.anyConsistent(terms[i], negated[j]) {
+				analysis.Implied = append(analysis.Implied, RuleImplication{RuleID: rules[j].ID, ByRuleID: rules[i].ID})
+			}
+		}
+	}
+	return analysis
+}
+
+// anyConsistent reports whether a term of one list is consistent with a term of the other.
+func (a *Analyzer) anyConsistent(terms, others [][]atom) bool {
+	for _, term := range terms {
+		for _, other := range others {
+			if a.consistent(append(append([]atom{}, term...), other...)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// schedulesOverlap reports whether two rules are active on a common date.
+func (a *Analyzer) schedulesOverlap(rule, other Rule) bool {
+	before := func(to, from time.Time) bool {
+		return !to.IsZero() && !from.IsZero() && to.Before(from)
+	}
+	return !before(rule.ToDate, other.FromDate) && !before(other.ToDate, rule.FromDate)
+}
+
+// build reduces a condition to a formula, negated if requested. item is true
+// for conditions evaluated against a single item.
+func (a *Analyzer) build(condition Condition, negate, item bool) formula {
+	switch condition.Type {
+	case "":
+		if len(condition.Conditions) == 0 {
+			// Rules without conditions match every cart
+			return a.constant(!negate)
+		}
+		return a.opaque(condition, negate)
+	case TypeCombine, TypeProductCombine:
+		var op string
+		switch condition.Aggregator {
+		case "all", "":
+			op = "and"
+		case "any":
+			op = "or"
+		default:
+			return a.opaque(condition, negate)
+		}
+		if len(condition.Conditions) == 0 {
+			return a.constant(!negate)
+		}
+		if negate {
+			// De Morgan
+			op = map[string]string{"and": "or", "or": "and"}[op]
+		}
+		childNegate := negate != !isTrueValue(condition.Value)
+		children := make([]formula, len(condition.Conditions))
+		for i, subCondition := range condition.Conditions {
+			children[i] = a.build(subCondition, childNegate, item)
+		}
+		return formula{op: op, children: children}
+	case TypeProductFound:
+		if item {
+			return a.opaque(condition, negate)
+		}
+		return a.found(condition.Aggregator, condition.Conditions, negate != !isTrueValue(condition.Value))
+	case TypeProduct:
+		if item {
+			return a.leaf("item", condition, negate)
+		}
+		// A product condition on the cart holds when some item matches it
+		return a.found("all", []Condition{condition}, negate)
+	case TypeSubselect:
+		if item {
+			return a.opaque(condition, negate)
+		}
+		return a.subselect(condition, negate)
+	case TypeAddress:
+		if item {
+			return a.opaque(condition, negate)
+		}
+		return a.leaf("address", condition, negate)
+	case TypeCustomer:
+		if item {
+			return a.opaque(condition, negate)
+		}
+		return a.leaf("customer", condition, negate)
+	default:
+		return a.opaque(condition, negate)
+	}
+}
+
+// found builds "an item matching the conditions is in the cart", negated for NOT FOUND.
+func (a *Analyzer) found(aggregator string, conditions []Condition, negate bool) formula {
+	if aggregator == "" {
+		aggregator = "all"
+	}
+	filter := Condition{Type: TypeProductCombine, Aggregator: aggregator, Value: "1", Conditions: conditions}
+	if satisfiable, err := a.itemsSatisfiable(filter); err == nil && !satisfiable {
+		return a.constant(negate)
+	}
+	return formula{op: "atom", atom: atom{key: "found:" + a.canonical(filter), negated: negate}}
+}
+
+// subselect builds a comparison on the total of the items matching the subconditions.
+func (a *Analyzer) subselect(condition Condition, negate bool) formula {
+	attribute := strings.ToLower(condition.Attribute)
+	if alias, ok := attributeAliases[attribute]; ok {
+		attribute = alias
+	}
+	aggregator := condition.Aggregator
+	if aggregator == "" {
+		aggregator = "all"
+	}
+	filter := Condition{Type: TypeProductCombine, Aggregator: aggregator, Value: "1", Conditions: condition.Conditions}
+	value, err := a.cv.toFloat64(condition.Value)
+	operator := condition.Operator
+	if err != nil || (attribute != "quantity" && attribute != "row_total") {
+		return a.opaque(condition, negate)
+	}
+	if negate {
+		operator = negatedOperators[operator]
+	}
+	if satisfiable, err := a.itemsSatisfiable(filter); err == nil && !satisfiable {
+		// Nothing is selected, so the total is zero
+		return a.constant(compareFloats(0, operator, value))
+	}
+	switch operator {
+	case "==", "!=", ">", ">=", "<", "<=":
+		return formula{op: "atom", atom: atom{
+			key:      "total:" + attribute + ":" + a.canonical(filter),
+			kind:     KindNumber,
+			operator: operator,
+			number:   value,
+			minZero:  true,
+		}}
+	}
+	return a.opaque(condition, negate)
+}
+
+// itemsSatisfiable reports whether some item can match an item-level condition.
+func (a *Analyzer) itemsSatisfiable(condition Condition) (bool, error) {
+	terms, err := a.terms(a.build(condition, false, true))
+	if err != nil {
+		return false, err
+	}
+	return len(terms) > 0, nil
+}
+
+// leaf builds an attribute comparison on a variable of the given scope.
+func (a *Analyzer) leaf(scope string, condition Condition, negate bool) formula {
+	attribute := strings.ToLower(condition.Attribute)
+	prefix := ""
+	if scope == "address" {
+		var billing bool
+		if billing, attribute = splitAddressAttribute(attribute); billing {
+			prefix = "billing."
+		}
+	}
+	if alias, ok := attributeAliases[attribute]; ok {
+		attribute = alias
+	}
+	if scope == "item" && !sharedItemAttributes[attribute] {
+		// A configurable item may match through its parent here and its child
+		// elsewhere, so even a condition and its negation can both hold
+		return formulaTrue
+	}
+	if isAttributePath(attribute) {
+		// Quantified paths do not negate like plain comparisons
+		return a.opaque(condition, negate)
+	}
+	if cartAddressAttributes[attribute] {
+		// Totals are the same whichever address the condition names
+		prefix = ""
+	}
+	conditionType := map[string]string{"item": TypeProduct, "address": TypeAddress, "customer": TypeCustomer}[scope]
+	kind, ok := LookupAttribute(conditionType, attribute)
+	if !ok {
+		kind = a.guessKind(condition.Value)
+	}
+	operator := condition.Operator
+	if negate {
+		operator = negatedOperators[operator]
+	}
+	result := atom{key: scope + "." + prefix + attribute, operator: operator, minZero: nonNegativeAttributes[attribute]}
+
+	switch kind {
+	case KindNumber, KindDate, KindBool:
+		result.kind = KindNumber
+		toNumber := a.cv.toFloat64
+		if kind == KindDate {
+			toNumber = func(v interface{}) (float64, error) {
+				t, err := a.cv.toTime(v)
+				return float64(t.Unix()), err
+			}
+		}
+		switch operator {
+		case "null", "notnull":
+			// Missing values are zero
+			result.operator = map[string]string{"null": "==", "notnull": "!="}[operator]
+			if kind == KindDate {
+				result.number = float64(time.Time{}.Unix())
+			}
+			return formula{op: "atom", atom: result}
+		case "==", "!=", ">", ">=", "<", "<=":
+			number, err := toNumber(condition.Value)
+			if err != nil {
+				return a.opaque(condition, negate)
+			}
+			result.number = number
+			return formula{op: "atom", atom: result}
+		case "()", "!()":
+			values, ok := condition.Value.([]interface{})
+			if !ok {
+				return a.opaque(condition, negate)
+			}
+			for _, value := range values {
+				number, err := toNumber(value)
+				if err != nil {
+					return a.opaque(condition, negate)
+				}
+				result.numbers = append(result.numbers, number)
+			}
+			return formula{op: "atom", atom: result}
+		}
+	case KindString:
+		result.kind = KindString
+		switch operator {
+		case "null", "notnull":
+			result.operator = map[string]string{"null": "==", "notnull": "!="}[operator]
+			return formula{op: "atom", atom: result}
+		case "==", "!=":
+			text, ok := condition.Value.(string)
+			if !ok {
+				return a.opaque(condition, negate)
+			}
+			result.text = text
+			return formula{op: "atom", atom: result}
+		case "()", "!()":
+			values, ok := condition.Value.([]interface{})
+			if !ok {
+				return a.opaque(condition, negate)
+			}
+			for _, value := range values {
+				text, ok := value.(string)
+				if !ok {
+					return a.opaque(condition, negate)
+				}
+				result.texts = append(result.texts, text)
+			}
+			return formula{op: "atom", atom: result}
+		}
+	}
+	return a.opaque(condition, negate)
+}
+
+// guessKind guesses the kind of a custom attribute from the compared value.
+func (a *Analyzer) guessKind(value interface{}) AttributeKind {
+	values, ok := value.([]interface{})
+	if !ok {
+		values = []interface{}{value}
+	}
+	for _, v := range values {
+		if _, err := a.cv.toFloat64(v); err != nil {
+			return KindString
+		}
+	}
+	return KindNumber
+}
+
+// opaque builds an atom that is only known to contradict its own negation.
+func (a *Analyzer) opaque(condition Condition, negate bool) formula {
+	switch condition.Operator {
+	case "!{}", "nlike", "!=", "!()", "notnull":
+		condition.Operator = negatedOperators[condition.Operator]
+		negate = !negate
+	}
+	return formula{op: "atom", atom: atom{key: "opaque:" + a.canonical(condition), negated: negate}}
+}
+
+func (a *Analyzer) constant(value bool) formula {
+	if value {
+		return formulaTrue
+	}
+	return formulaFalse
+}
+
+// canonical returns a key identifying a condition tree.
+func (a *Analyzer) canonical(condition Condition) string {
+	data, err := json.Marshal(condition)
+	if err != nil {
+		return fmt.Sprintf("%#v", condition)
+	}
+	return string(data)
+}
+
+// terms expands a formula into its consistent conjunctions (disjunctive normal form).
+func (a *Analyzer) terms(f formula) ([][]atom, error) {
+	switch f.op {
+	case "true":
+		return [][]atom{{}}, nil
+	case "false":
+		return nil, nil
+	case "atom":
+		if !a.consistent([]atom{f.atom}) {
+			return nil, nil
+		}
+		return [][]atom{{f.atom}}, nil
+	case "or":
+		var result [][]atom
+		for _, child := range f.children {
+			terms, err := a.terms(child)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, terms...)
+			if len(result) > a.maxTerms {
+				return nil, ErrTooComplex
+			}
+		}
+		return result, nil
+	default:
+		result := [][]atom{{}}
+		for _, child := range f.children {
+			terms, err := a.terms(child)
+			if err != nil {
+				return nil, err
+			}
+			var product [][]atom
+			for _, left := range result {
+				for _, right := range terms {
+					term := append(append([]atom{}, left...), right...)
+					if a.consistent(term) {
+						product = append(product, term)
+					}
+				}
+				if len(product) > a.maxTerms {
+					return nil, ErrTooComplex
+				}
+			}
+			if result = product; len(result) == 0 {
+				return nil, nil
+			}
+		}
+		return result, nil
+	}
+}
+
+// consistent reports whether all atoms of a conjunction can hold at once.
+func (a *Analyzer) consistent(term []atom) bool {
+	numbers := map[string]*numberDomain{}
+	texts := map[string]*textDomain{}
+	opaque := map[string]bool{}
+	for _, at := range term {
+		switch at.kind {
+		case KindNumber:
+			domain, ok := numbers[at.key]
+			if !ok {
+				domain = &numberDomain{}
+				if at.minZero {
+					domain.apply(">=", 0, nil)
+				}
+				numbers[at.key] = domain
+			}
+			domain.apply(at.operator, at.number, at.numbers)
+			if domain.empty() {
+				return false
+			}
+		case KindString:
+			domain, ok := texts[at.key]
+			if !ok {
+				domain = &textDomain{excluded: map[string]bool{}}
+				texts[at.key] = domain
+			}
+			domain.apply(at.operator, at.text, at.texts)
+			if domain.empty() {
+				return false
+			}
+		default:
+			if negated, ok := opaque[at.key]; ok && negated != at.negated {
+				return false
+			}
+			opaque[at.key] = at.negated
+		}
+	}
+	return true
+}
+
+// numberDomain is the set of values a numeric variable can take: an interval,
+// optionally restricted to a set of values, minus excluded values.
+type numberDomain struct {
+	min, max         float64
+	hasMin, hasMax   bool
+	minOpen, maxOpen bool
+	set              []float64
+	hasSet           bool
+	excluded         []float64
+}
+
+func (d *numberDomain) apply(operator string, value float64, values []float64) {
+	switch operator {
+	case "==":
+		d.restrict([]float64{value})
+	case "!=":
+		d.excluded = append(d.excluded, value)
+	case ">", ">=":
+		if !d.hasMin || value > d.min || (value == d.min && operator == ">") {
+			d.min, d.hasMin, d.minOpen = value, true, operator == ">"
+		}
+	case "<", "<=":
+		if !d.hasMax || value < d.max || (value == d.max && operator == "<") {
+			d.max, d.hasMax, d.maxOpen = value, true, operator == "<"
+		}
+	case "()":
+		d.restrict(values)
+	case "!()":
+		d.excluded = append(d.excluded, values...)
+	}
+}
+
+// restrict intersects the allowed set with values.
+func (d *numberDomain) restrict(values []float64) {
+	if !d.hasSet {
+		d.set, d.hasSet = append([]float64{}, values...), true
+		return
+	}
+	var set []float64
+	for _, v := range d.set {
+		for _, value := range values {
+			if v == value {
+				set = append(set, v)
+				break
+			}
+		}
+	}
+	d.set = set
+}
+
+func (d *numberDomain) contains(value float64) bool {
+	if d.hasMin && (value < d.min || (value == d.min && d.minOpen)) {
+		return false
+	}
+	if d.hasMax && (value > d.max || (value == d.max && d.maxOpen)) {
+		return false
+	}
+	for _, excluded := range d.excluded {
+		if value == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *numberDomain) empty() bool {
+	if d.hasSet {
+		for _, v := range d.set {
+			if d.contains(v) {
+				return false
+			}
+		}
+		return true
+	}
+	if !d.hasMin || !d.hasMax {
+		return false
+	}
+	if d.min != d.max {
+		return d.min > d.max
+	}
+	return !d.contains(d.min)
+}
+
+// textDomain is the set of values a string variable can take.
+type textDomain struct {
+	set      map[string]bool // nil when any value is allowed
+	excluded map[string]bool
+}
+
+func (d *textDomain) apply(operator, value string, values []string) {
+	switch operator {
+	case "==":
+		d.restrict([]string{value})
+	case "!=":
+		d.excluded[value] = true
+	case "()":
+		d.restrict(values)
+	case "!()":
+		for _, v := range values {
+			d.excluded[v] = true
+		}
+	}
+}
+
+// restrict intersects the allowed set with values.
+func (d *textDomain) restrict(values []string) {
+	set := map[string]bool{}
+	for _, v := range values {
+		if d.set == nil || d.set[v] {
+			set[v] = true
+		}
+	}
+	d.set = set
+}
+
+func (d *textDomain) empty() bool {
+	if d.set == nil {
+		return false
+	}
+	for v := range d.set {
+		if !d.excluded[v] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,234 @@
+package validator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnalyzer(t *testing.T) {
+	all := func(conditions ...Condition) Condition {
+		return Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: conditions}
+	}
+	anyOf := func(conditions ...Condition) Condition {
+		return Condition{Type: TypeCombine, Aggregator: "any", Value: "1", Conditions: conditions}
+	}
+	subtotal := func(operator string, value interface{}) Condition {
+		return Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: operator, Value: value}
+	}
+	country := func(operator string, value interface{}) Condition {
+		return Condition{Type: TypeAddress, Attribute: "country_id", Operator: operator, Value: value}
+	}
+	sku := Condition{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "1012096"}
+
+	tests := []struct {
+		name        string
+		condition   Condition
+		other       Condition
+		satisfiable bool
+		implies     bool
+		overlaps    bool
+	}{
+		{
+			name:        "Disjoint subtotal ranges",
+			condition:   all(subtotal(">", "100"), subtotal("<", "50")),
+			other:       subtotal(">=", "0"),
+			satisfiable: false,
+			implies:     true,
+			overlaps:    false,
+		},
+		{
+			name:        "Narrower range implies wider",
+			condition:   all(subtotal(">=", "200"), country("==", "US")),
+			other:       anyOf(subtotal(">", "100"), country("()", []interface{}{"CA"})),
+			satisfiable: true,
+			implies:     true,
+			overlaps:    true,
+		},
+		{
+			name:        "Countries exclude each other",
+			condition:   country("()", []interface{}{"US", "CA"}),
+			other:       all(country("!=", "US"), country("!=", "CA")),
+			satisfiable: true,
+			implies:     false,
+			overlaps:    false,
+		},
+		{
+			name:        "False combine negates its subconditions",
+			condition:   Condition{Type: TypeCombine, Aggregator: "any", Value: "0", Conditions: []Condition{subtotal("<", "100")}},
+			other:       subtotal(">=", "100"),
+			satisfiable: true,
+			implies:     true,
+			overlaps:    true,
+		},
+		{
+			name:        "Subtotal cannot be negative",
+			condition:   subtotal("<", "0"),
+			other:       all(),
+			satisfiable: false,
+			implies:     true,
+			overlaps:    false,
+		},
+		{
+			name: "Found and not found the same item",
+			condition: all(
+				Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{sku}},
+				Condition{Type: TypeProductFound, Aggregator: "all", Value: "0", Conditions: []Condition{sku}},
+			),
+			other:       all(),
+			satisfiable: false,
+			implies:     true,
+			overlaps:    false,
+		},
+		{
+			name:        "Product condition on the cart is a found item",
+			condition:   all(sku),
+			other:       Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{sku}},
+			satisfiable: true,
+			implies:     true,
+			overlaps:    true,
+		},
+		{
+			name: "Subselect totals",
+			condition: Condition{Type: TypeSubselect, Attribute: "qty", Operator: ">=", Value: "5", Aggregator: "all",
+				Conditions: []Condition{sku}},
+			other: Condition{Type: TypeSubselect, Attribute: "quantity", Operator: ">", Value: "2", Aggregator: "all",
+				Conditions: []Condition{sku}},
+			satisfiable: true,
+			implies:     true,
+			overlaps:    true,
+		},
+		{
+			name: "Item quantity out of range",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "qty", Operator: ">", Value: "10"},
+				{Type: TypeProduct, Attribute: "quote_item_qty", Operator: "<=", Value: "3"},
+			}},
+			other:       all(),
+			satisfiable: false,
+			implies:     true,
+			overlaps:    false,
+		},
+		{
+			name: "Configurable parent and child match different SKUs",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SHIRT-RED"},
+				{Type: TypeProduct, Attribute: "sku", Operator: "!=", Value: "SHIRT-RED"},
+			}},
+			other:       all(),
+			satisfiable: true,
+			implies:     true,
+			overlaps:    true,
+		},
+		{
+			name:        "Customer dates",
+			condition:   Condition{Type: TypeCustomer, Attribute: "created_at", Operator: ">=", Value: "2024-01-01"},
+			other:       Condition{Type: TypeCustomer, Attribute: "created_at", Operator: "<", Value: "2023-06-01 00:00:00"},
+			satisfiable: true,
+			implies:     false,
+			overlaps:    false,
+		},
+		{
+			name:        "Unknown comparisons only contradict themselves",
+			condition:   Condition{Type: TypeCustomer, Attribute: "email", Operator: "like", Value: "%@example.com"},
+			other:       Condition{Type: TypeCustomer, Attribute: "email", Operator: "{}", Value: "example"},
+			satisfiable: true,
+			implies:     false,
+			overlaps:    true,
+		},
+	}
+
+	analyzer := NewAnalyzer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			satisfiable, err := analyzer.Satisfiable(tt.condition)
+			if err != nil || satisfiable != tt.satisfiable {
+				t.Errorf("Satisfiable() = %v, %v, want %v", satisfiable, err, tt.satisfiable)
+			}
+			implies, err := analyzer.Implies(tt.condition, tt.other)
+			if err != nil || implies != tt.implies {
+				t.Errorf("Implies() = %v, %v, want %v", implies, err, tt.implies)
+			}
+			overlaps, err := analyzer.Overlaps(tt.condition, tt.other)
+			if err != nil || overlaps != tt.overlaps {
+				t.Errorf("Overlaps() = %v, %v, want %v", overlaps, err, tt.overlaps)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRules(t *testing.T) {
+	parse := func(s string) Condition {
+		var condition Condition
+		if err := json.Unmarshal([]byte(s), &condition); err != nil {
+			t.Fatalf("Failed to unmarshal condition: %v", err)
+		}
+		return condition
+	}
+
+	rules := []Rule{
+		{ID: 1, IsActive: true, Conditions: parse(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+			"aggregator": "all",
+			"value": "1",
+			"conditions": [
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">", "value": "100"},
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": "<", "value": "50"}
+			]
+		}`)},
+		{ID: 2, IsActive: true, Conditions: parse(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+			"aggregator": "all",
+			"value": "1",
+			"conditions": [
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "50"}
+			]
+		}`)},
+		{ID: 3, IsActive: true, Conditions: parse(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+			"aggregator": "all",
+			"value": "1",
+			"conditions": [
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "150"},
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "group_id", "operator": "==", "value": "1"}
+			]
+		}`)},
+		{ID: 4, IsActive: true, Conditions: parse(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+			"aggregator": "all",
+			"value": "1",
+			"conditions": [
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": "<", "value": "50"}
+			]
+		}`)},
+		{ID: 5, IsActive: true, Conditions: subtotalAtLeast50(),
+			FromDate: time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), ToDate: time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC)},
+		{ID: 6, IsActive: true, Conditions: subtotalAtLeast50(),
+			FromDate: time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: 7, IsActive: false},
+	}
+
+	got := NewAnalyzer().AnalyzeRules(rules)
+	want := &RuleAnalysis{
+		Unsatisfiable: []int{1},
+		Overlaps: []RuleOverlap{
+			{RuleID: 2, OtherRuleID: 3}, {RuleID: 2, OtherRuleID: 5}, {RuleID: 2, OtherRuleID: 6},
+			{RuleID: 3, OtherRuleID: 5}, {RuleID: 3, OtherRuleID: 6},
+		},
+		Implied: []RuleImplication{
+			{RuleID: 2, ByRuleID: 3}, {RuleID: 2, ByRuleID: 5}, {RuleID: 5, ByRuleID: 2}, {RuleID: 2, ByRuleID: 6}, {RuleID: 6, ByRuleID: 2},
+			{RuleID: 5, ByRuleID: 3}, {RuleID: 6, ByRuleID: 3},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeRules() = %+v, want %+v", got, want)
+	}
+}
+
+// subtotalAtLeast50 is "subtotal >= 50" written as "none of: subtotal < 50".
+func subtotalAtLeast50() Condition {
+	return Condition{Type: TypeCombine, Aggregator: "any", Value: "0", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "subtotal", Operator: "<", Value: "50"},
+	}}
+}
@@ -0,0 +1,309 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// getItemAttribute retrieves an attribute value from a cart item
+func (cv *ConditionValidator) getItemAttribute(item Item, attribute string) (interface{}, error) {
+	switch strings.ToLower(attribute) {
+	case "sku":
+		return item.SKU, nil
+	case "price":
+		return item.Price, nil
+	case "final_price":
+		return item.FinalPrice, nil
+	case "quantity", "qty", "quote_item_qty":
+		return item.Quantity, nil
+	case "quote_item_price":
+		return item.Price, nil
+	case "row_total", "base_row_total", "quote_item_row_total":
+		return item.Price * float64(item.Quantity), nil
+	case "product_type":
+		return item.ProductType, nil
+	case "name":
+		return item.Name, nil
+	case "weight":
+		return item.Weight, nil
+	case "category_ids":
+		return item.CategoryIDs, nil
+	case "created_at":
+		return item.CreatedAt, nil
+	case "updated_at":
+		return item.UpdatedAt, nil
+	default:
+		// Check custom attributes
+		if val, ok := item.Attributes[attribute]; ok {
+			return val, nil
+		}
+		// Use reflection for other fields
+		return cv.getStructField(item, attribute)
+	}
+}
+
+// ResolveAttribute returns the value a condition of the given type compares
+// for an attribute: that of the item for Product conditions, of the cart and
+// its address for Address conditions and of the customer for Customer
+// conditions. Attribute paths with list quantifiers resolve to the list of
+// values they reach. Configurable items are not resolved through their
+// children; ResolveCondition is.
+func (cv *ConditionValidator) ResolveAttribute(conditionType, attribute string, cart Cart, item *Item) (interface{}, error) {
+	switch conditionType {
+	case TypeProduct:
+		if item == nil {
+			return nil, fmt.Errorf("product attribute %s needs an item", attribute)
+		}
+		if isAttributePath(attribute) {
+			return cv.resolvePath(*item, trimEntityPrefix(attribute, "item", "product"))
+		}
+		return cv.getItemAttribute(*item, attribute)
+	case TypeAddress:
+		billing, attribute := splitAddressAttribute(attribute)
+		address := cart.ShippingAddress
+		if billing {
+			address = cart.BillingAddress
+		}
+		if isAttributePath(attribute) {
+			return cv.resolvePath(address, attribute)
+		}
+		return cv.getQuoteAddressAttribute(cart, address, attribute)
+	case TypeCustomer:
+		if isAttributePath(attribute) {
+			return cv.resolvePath(cart.Customer, trimEntityPrefix(attribute, "customer"))
+		}
+		return cv.getCustomerAttribute(cart.Customer, attribute)
+	default:
+		return nil, fmt.Errorf("%s conditions have no attributes to resolve", shortType(conditionType))
+	}
+}
+
+// ResolveCondition returns the value the validator compares for the
+// condition. Like ResolveAttribute, but a configurable item whose own value
+// does not match is resolved through its selected child, as Product
+// conditions fall back to it.
+func (cv *ConditionValidator) ResolveCondition(condition Condition, cart Cart, item *Item) (interface{}, error) {
+	if condition.Type == TypeProduct && item != nil && item.ProductType == ProductTypeConfigurable && len(item.Children) > 0 {
+		if valid, _ := cv.matchItemAttribute(condition, *item); !valid {
+			child := cv.childItem(*item, item.Children[0])
+			item = &child
+		}
+	}
+	return cv.ResolveAttribute(condition.Type, condition.Attribute, cart, item)
+}
+
+// getAddressAttribute retrieves an attribute value from an address
+func (cv *ConditionValidator) getAddressAttribute(address Address, attribute string) (interface{}, error) {
+	switch strings.ToLower(attribute) {
+	case "country", "country_id":
+		return address.Country, nil
+	case "region":
+		return address.Region, nil
+	case "region_id":
+		return address.RegionID, nil
+	case "city":
+		return address.City, nil
+	case "postcode":
+		return address.PostalCode, nil
+	case "street":
+		return address.Street, nil
+	case "telephone":
+		return address.Telephone, nil
+	case "company":
+		return address.Company, nil
+	case "firstname":
+		return address.FirstName, nil
+	case "lastname":
+		return address.LastName, nil
+	case "email":
+		return address.Email, nil
+	default:
+		// Use reflection for other fields
+		return cv.getStructField(address, attribute)
+	}
+}
+
+// getQuoteAddressAttribute retrieves an attribute of Magento's Address condition,
+// which also covers the totals of the cart shipped to that address
+func (cv *ConditionValidator) getQuoteAddressAttribute(cart Cart, address Address, attribute string) (interface{}, error) {
+	switch strings.ToLower(attribute) {
+	case "base_subtotal", "subtotal":
+		return cart.Subtotal, nil
+	case "total_qty":
+		var total int
+		for _, item := range cart.Items {
+			total += item.Quantity
+		}
+		return total, nil
+	case "weight":
+		var total float64
+		for _, item := range cart.Items {
+			total += item.Weight * float64(item.Quantity)
+		}
+		return total, nil
+	default:
+		return cv.getAddressAttribute(address, attribute)
+	}
+}
+
+// getCustomerAttribute retrieves an attribute value from a customer
+func (cv *ConditionValidator) getCustomerAttribute(customer Customer, attribute string) (interface{}, error) {
+	switch strings.ToLower(attribute) {
+	case "id":
+		return customer.ID, nil
+	case "group_id":
+		return customer.GroupID, nil
+	case "email":
+		return customer.Email, nil
+	case "firstname":
+		return customer.FirstName, nil
+	case "lastname":
+		return customer.LastName, nil
+	case "gender":
+		return customer.Gender, nil
+	case "dob":
+		return customer.DateOfBirth, nil
+	case "created_at":
+		return customer.CreatedAt, nil
+	case "last_login_at":
+		return customer.LastLoginAt, nil
+	case "orders_count":
+		return customer.Orders, nil
+	case "total_spent":
+		return customer.TotalSpent, nil
+	case "average_order_amount":
+		return customer.AverageOrderAmount, nil
+	case "is_subscribed":
+		return customer.IsSubscribed, nil
+	default:
+		// Check custom attributes
+		if val, ok := customer.Attributes[attribute]; ok {
+			return val, nil
+		}
+		// Use reflection for other fields
+		return cv.getStructField(customer, attribute)
+	}
+}
+
+// getCartAttribute retrieves an attribute value from the cart
+func (cv *ConditionValidator) getCartAttribute(cart Cart, attribute string) (interface{}, error) {
+	switch strings.ToLower(attribute) {
+	case "subtotal":
+		return cart.Subtotal, nil
+	case "grand_total":
+		return cart.GrandTotal, nil
+	case "coupon_code":
+		return cart.CouponCode, nil
+	case "created_at":
+		return cart.CreatedAt, nil
+	case "items_count":
+		return len(cart.Items), nil
+	case "total_quantity":
+		var total int
+		for _, item := range cart.Items {
+			total += item.Quantity
+		}
+		return total, nil
+	default:
+		// Use reflection for other fields
+		return cv.getStructField(cart, attribute)
+	}
+}
+
+// getStructField is a helper function to get a field value using reflection.
+// Snake-case names match the Go field, e.g. last_login_at reads LastLoginAt.
+func (cv *ConditionValidator) getStructField(obj interface{}, field string) (interface{}, error) {
+	r := reflect.Indirect(reflect.ValueOf(obj))
+	if r.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("field not found: %s", field)
+	}
+	name := strings.ReplaceAll(field, "_", "")
+	f := r.FieldByNameFunc(func(candidate string) bool { return strings.EqualFold(candidate, name) })
+	if !f.IsValid() || !f.CanInterface() {
+		return nil, fmt.Errorf("field not found: %s", field)
+	}
+	return f.Interface(), nil
+}
+
+// formatValue formats the value based on its type for comparison
+func (cv *ConditionValidator) formatValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case []int:
+		strSlice := make([]string, len(v))
+		for i, num := range v {
+			strSlice[i] = fmt.Sprintf("%d", num)
+		}
+		return strings.Join(strSlice, ",")
+	default:
+		return v
+	}
+}
+
+// AttributeKind describes the values of a built-in attribute
+type AttributeKind string
+
+// Attribute kinds
+const (
+	KindString AttributeKind = "string"
+	KindNumber AttributeKind = "number"
+	KindDate   AttributeKind = "date"
+	KindIDs    AttributeKind = "ids"  // A list of IDs, such as category_ids
+	KindBool   AttributeKind = "bool" // Stored as 0/1 in Magento
+	KindList   AttributeKind = "list" // A list of strings, such as street lines
+)
+
+// Built-in attributes of each condition type, as resolved by the attribute lookups above
+var attributeKinds = map[string]map[string]AttributeKind{
+	TypeProduct: {
+		"sku": KindString, "name": KindString, "product_type": KindString,
+		"price": KindNumber, "final_price": KindNumber, "weight": KindNumber,
+		"quantity": KindNumber, "qty": KindNumber, "quote_item_qty": KindNumber, "quote_item_price": KindNumber,
+		"row_total": KindNumber, "base_row_total": KindNumber, "quote_item_row_total": KindNumber,
+		"category_ids": KindIDs,
+		"created_at":   KindDate, "updated_at": KindDate,
+	},
+	TypeAddress: {
+		"base_subtotal": KindNumber, "subtotal": KindNumber, "total_qty": KindNumber, "weight": KindNumber,
+		"country": KindString, "country_id": KindString, "region": KindString, "region_id": KindNumber,
+		"city": KindString, "postcode": KindString, "street": KindList, "telephone": KindString,
+		"company": KindString, "firstname": KindString, "lastname": KindString, "email": KindString,
+	},
+	TypeCustomer: {
+		"id": KindNumber, "group_id": KindNumber, "email": KindString,
+		"firstname": KindString, "lastname": KindString, "gender": KindString,
+		"dob": KindDate, "created_at": KindDate, "last_login_at": KindDate,
+		"orders_count": KindNumber, "total_spent": KindNumber, "average_order_amount": KindNumber,
+		"is_subscribed": KindBool,
+	},
+}
+
+// nonNegativeAttributes are numeric attributes that can never be below zero
+var nonNegativeAttributes = map[string]bool{
+	"price": true, "final_price": true, "weight": true,
+	"quantity": true, "qty": true, "quote_item_qty": true, "quote_item_price": true,
+	"row_total": true, "base_row_total": true, "quote_item_row_total": true,
+	"base_subtotal": true, "subtotal": true, "total_qty": true,
+	"orders_count": true, "total_spent": true, "average_order_amount": true,
+	"id": true, "group_id": true, "region_id": true,
+}
+
+// LookupAttribute returns the kind of a built-in attribute of a condition type.
+func LookupAttribute(conditionType, attribute string) (AttributeKind, bool) {
+	kind, ok := attributeKinds[conditionType][strings.ToLower(attribute)]
+	return kind, ok
+}
+
+// KnownAttributes returns the built-in attributes of a condition type, sorted.
+func KnownAttributes(conditionType string) []string {
+	attributes := make([]string, 0, len(attributeKinds[conditionType]))
+	for attribute := range attributeKinds[conditionType] {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+	return attributes
+}
@@ -0,0 +1,346 @@
+package validator
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OrderReader reads historical orders as carts. Read returns io.EOF after the last order.
+type OrderReader interface {
+	Read() (Cart, error)
+}
+
+// JSONLOrderReader reads one JSON-encoded Cart per line, with the field names of Cart.
+type JSONLOrderReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewJSONLOrderReader creates a JSONLOrderReader reading from r.
+func NewJSONLOrderReader(r io.Reader) *JSONLOrderReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &JSONLOrderReader{scanner: scanner}
+}
+
+// Read returns the next order, skipping blank lines.
+func (r *JSONLOrderReader) Read() (Cart, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var cart Cart
+		if err := json.Unmarshal([]byte(line), &cart); err != nil {
+			return Cart{}, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		return cart, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Cart{}, err
+	}
+	return Cart{}, io.EOF
+}
+
+// CSVOrderReader reads orders from CSV with one row per order line. The header
+// names the columns, in any order: order_id is required, and created_at,
+// website_id, store_id, customer_id, customer_email, customer_group_id,
+// country, subtotal, sku, name, qty, price and category_ids (separated by "|")
+// are optional. Consecutive rows with the same order_id form one order, whose
+// subtotal defaults to the sum of its lines. Orders without a website_id are
+// in website 0, which rules limited to websites do not apply to.
+type CSVOrderReader struct {
+	cv      *ConditionValidator
+	reader  *csv.Reader
+	columns map[string]int
+	pending []string
+	line    int
+}
+
+// NewCSVOrderReader creates a CSVOrderReader reading from r.
+func NewCSVOrderReader(r io.Reader) *CSVOrderReader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	return &CSVOrderReader{cv: NewConditionValidator(), reader: reader}
+}
+
+// Read returns the next order.
+func (r *CSVOrderReader) Read() (Cart, error) {
+	if r.columns == nil {
+		header, err := r.reader.Read()
+		if err != nil {
+			return Cart{}, err
+		}
+		r.line++
+		r.columns = make(map[string]int, len(header))
+		for i, name := range header {
+			r.columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := r.columns["order_id"]; !ok {
+			return Cart{}, fmt.Errorf("line 1: missing order_id column")
+		}
+	}
+
+	var cart Cart
+	var orderID string
+	subtotalSet := false
+	for {
+		row := r.pending
+		r.pending = nil
+		if row == nil {
+			var err error
+			row, err = r.reader.Read()
+			if err == io.EOF && orderID != "" {
+				break
+			}
+			if err != nil {
+				return Cart{}, err
+			}
+			r.line++
+		}
+		if orderID != "" && r.field(row, "order_id") != orderID {
+			r.pending = row
+			break
+		}
+		if orderID == "" {
+			orderID = r.field(row, "order_id")
+			if err := r.readOrder(row, &cart, &subtotalSet); err != nil {
+				return Cart{}, fmt.Errorf("line %d: %v", r.line, err)
+			}
+		}
+		if r.field(row, "sku") != "" {
+			item, err := r.readItem(row)
+			if err != nil {
+				return Cart{}, fmt.Errorf("line %d: %v", r.line, err)
+			}
+			cart.Items = append(cart.Items, item)
+		}
+	}
+
+	if !subtotalSet {
+		for _, item := range cart.Items {
+			cart.Subtotal += item.Price * float64(item.Quantity)
+		}
+	}
+	cart.GrandTotal = cart.Subtotal
+	return cart, nil
+}
+
+// field returns a column of the row, or "" when the column is missing.
+func (r *CSVOrderReader) field(row []string, column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// readOrder reads the order columns of the first row of an order.
+func (r *CSVOrderReader) readOrder(row []string, cart *Cart, subtotalSet *bool) error {
+	var err error
+	if value := r.field(row, "created_at"); value != "" {
+		if cart.CreatedAt, err = r.cv.toTime(value); err != nil {
+			return err
+		}
+	}
+	if value := r.field(row, "website_id"); value != "" {
+		if cart.WebsiteID, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid website_id: %v", err)
+		}
+	}
+	if value := r.field(row, "store_id"); value != "" {
+		if cart.StoreID, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid store_id: %v", err)
+		}
+	}
+	if value := r.field(row, "customer_id"); value != "" {
+		if cart.Customer.ID, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid customer_id: %v", err)
+		}
+	}
+	if value := r.field(row, "customer_group_id"); value != "" {
+		if cart.Customer.GroupID, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid customer_group_id: %v", err)
+		}
+	}
+	if value := r.field(row, "subtotal"); value != "" {
+		if cart.Subtotal, err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("invalid subtotal: %v", err)
+		}
+		*subtotalSet = true
+	}
+	cart.Customer.Email = r.field(row, "customer_email")
+	cart.ShippingAddress.Country = r.field(row, "country")
+	cart.BillingAddress.Country = cart.ShippingAddress.Country
+	return nil
+}
+
+// readItem reads the order line of a row.
+func (r *CSVOrderReader) readItem(row []string) (Item, error) {
+	item := Item{SKU: r.field(row, "sku"), Name: r.field(row, "name"), ProductType: ProductTypeSimple, Quantity: 1}
+	var err error
+	if value := r.field(row, "qty"); value != "" {
+		var quantity float64
+		if quantity, err = strconv.ParseFloat(value, 64); err != nil {
+			return Item{}, fmt.Errorf("invalid qty: %v", err)
+		}
+		item.Quantity = int(quantity)
+	}
+	if value := r.field(row, "price"); value != "" {
+		if item.Price, err = strconv.ParseFloat(value, 64); err != nil {
+			return Item{}, fmt.Errorf("invalid price: %v", err)
+		}
+		item.FinalPrice = item.Price
+	}
+	if value := r.field(row, "category_ids"); value != "" {
+		for _, part := range strings.Split(value, "|") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return Item{}, fmt.Errorf("invalid category_ids: %v", err)
+			}
+			item.CategoryIDs = append(item.CategoryIDs, id)
+		}
+	}
+	return item, nil
+}
+
+// BacktestDay is the outcome of a backtest for the orders of one day.
+type BacktestDay struct {
+	Date     string // 2006-01-02, or empty for orders without a date
+	Orders   int
+	Matches  int
+	Discount float64
+}
+
+// BacktestReport estimates what a rule would have cost over historical orders.
+type BacktestReport struct {
+	RuleID        int
+	Orders        int
+	Matches       int
+	Errors        int
+	TotalDiscount float64
+	Customers     int           // Distinct customers, by ID or email, with a matching order
+	Days          []BacktestDay // Sorted by date
+}
+
+// Backtester replays historical orders through a candidate rule.
+type Backtester struct {
+	evaluator *BatchEvaluator
+}
+
+// NewBacktester creates a Backtester evaluating orders with the given number of workers.
+func NewBacktester(validator *ConditionValidator, workers int) *Backtester {
+	return &Backtester{evaluator: NewBatchEvaluator(validator, workers)}
+}
+
+// Run evaluates the rule, including its discount, against every order read
+// from orders. The rule is treated as active over the whole history, since a
+// candidate rule is usually scheduled for the future.
+func (b *Backtester) Run(ctx context.Context, rule Rule, orders OrderReader) (*BacktestReport, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rule.IsActive = true
+	rule.FromDate, rule.ToDate = time.Time{}, time.Time{}
+
+	type order struct {
+		day      string
+		customer string
+	}
+	var mu sync.Mutex
+	// Orders waiting for their result, by position in the stream
+	infos := make(map[int]order)
+	index := 0
+	var readErr error
+
+	carts := make(chan Cart)
+	go func() {
+		defer close(carts)
+		for {
+			cart, err := orders.Read()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					mu.Lock()
+					readErr = err
+					mu.Unlock()
+					cancel()
+				}
+				return
+			}
+			info := order{customer: strings.ToLower(cart.Customer.Email)}
+			if cart.Customer.ID != 0 {
+				info.customer = strconv.Itoa(cart.Customer.ID)
+			}
+			if !cart.CreatedAt.IsZero() {
+				info.day = cart.CreatedAt.Format("2006-01-02")
+			}
+			mu.Lock()
+			infos[index] = info
+			index++
+			mu.Unlock()
+			select {
+			case carts <- cart:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	report := &BacktestReport{RuleID: rule.ID}
+	days := make(map[string]*BacktestDay)
+	customers := make(map[string]bool)
+	_, err := b.evaluator.Run(ctx, []Rule{rule}, carts, func(result BatchResult) {
+		mu.Lock()
+		info := infos[result.CartIndex]
+		delete(infos, result.CartIndex)
+		mu.Unlock()
+
+		day, ok := days[info.day]
+		if !ok {
+			day = &BacktestDay{Date: info.day}
+			days[info.day] = day
+		}
+		report.Orders++
+		day.Orders++
+		if result.Err != nil {
+			report.Errors++
+			return
+		}
+		if result.Matched {
+			report.Matches++
+			report.TotalDiscount += result.Discount
+			day.Matches++
+			day.Discount += result.Discount
+			if info.customer != "" {
+				customers[info.customer] = true
+			}
+		}
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	if readErr != nil {
+		return nil, fmt.Errorf("reading orders: %v", readErr)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	report.Customers = len(customers)
+	for _, day := range days {
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Date < report.Days[j].Date })
+	return report, nil
+}
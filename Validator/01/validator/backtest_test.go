@@ -0,0 +1,99 @@
+package validator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBacktester(t *testing.T) {
+	rule := Rule{
+		ID: 7,
+		Conditions: Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"},
+		}},
+		SimpleAction:   ActionByPercent,
+		DiscountAmount: 10,
+	}
+
+	csvOrders := `order_id,created_at,customer_id,customer_email,sku,qty,price,category_ids
+1001,2024-03-01 09:15:00,12,ann@example.com,SHOE-1,1,80,7
+1001,2024-03-01 09:15:00,12,ann@example.com,SOCK-1,2,15,7|9
+1002,2024-03-01 17:40:00,0,guest@example.com,SHOE-2,1,60,7
+1003,2024-03-02 11:05:00,12,ann@example.com,BAG-1,1,120,
+1004,2024-03-02 12:00:00,0,Bob@Example.com,SHOE-3,2,55,7
+`
+	jsonlOrders := `{"Items": [{"SKU": "SHOE-1", "Quantity": 1, "Price": 80}, {"SKU": "SOCK-1", "Quantity": 2, "Price": 15}], "Subtotal": 110, "Customer": {"ID": 12}, "CreatedAt": "2024-03-01T09:15:00Z"}
+{"Items": [{"SKU": "SHOE-2", "Quantity": 1, "Price": 60}], "Subtotal": 60, "Customer": {"Email": "guest@example.com"}, "CreatedAt": "2024-03-01T17:40:00Z"}
+
+{"Items": [{"SKU": "BAG-1", "Quantity": 1, "Price": 120}], "Subtotal": 120, "Customer": {"ID": 12}, "CreatedAt": "2024-03-02T11:05:00Z"}
+{"Items": [{"SKU": "SHOE-3", "Quantity": 2, "Price": 55}], "Subtotal": 110, "Customer": {"Email": "Bob@Example.com"}, "CreatedAt": "2024-03-02T12:00:00Z"}
+`
+	want := &BacktestReport{
+		RuleID:        7,
+		Orders:        4,
+		Matches:       3,
+		TotalDiscount: 34,
+		Customers:     2,
+		Days: []BacktestDay{
+			{Date: "2024-03-01", Orders: 2, Matches: 1, Discount: 11},
+			{Date: "2024-03-02", Orders: 2, Matches: 2, Discount: 23},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		orders OrderReader
+	}{
+		{"CSV", NewCSVOrderReader(strings.NewReader(csvOrders))},
+		{"JSONL", NewJSONLOrderReader(strings.NewReader(jsonlOrders))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewBacktester(NewConditionValidator(), 3).Run(context.Background(), rule, tt.orders)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Run() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	t.Run("CSV websites and stores", func(t *testing.T) {
+		orders := `order_id,website_id,store_id,customer_group_id,sku,qty,price
+2001,1,1,1,SHOE-1,2,60
+2002,2,3,1,SHOE-2,2,60
+2003,,,1,SHOE-3,2,60
+`
+		reader := NewCSVOrderReader(strings.NewReader(orders))
+		cart, err := reader.Read()
+		if err != nil || cart.WebsiteID != 1 || cart.StoreID != 1 || cart.Customer.GroupID != 1 {
+			t.Fatalf("Read() = website %d, store %d, group %d, %v, want 1, 1, 1", cart.WebsiteID, cart.StoreID, cart.Customer.GroupID, err)
+		}
+
+		scoped := rule
+		scoped.WebsiteIDs = []int{2}
+		got, err := NewBacktester(NewConditionValidator(), 2).Run(context.Background(), scoped, NewCSVOrderReader(strings.NewReader(orders)))
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if got.Orders != 3 || got.Matches != 1 {
+			t.Errorf("Run() = %d orders, %d matches, want 3 orders and only website 2 matching", got.Orders, got.Matches)
+		}
+
+		_, err = NewCSVOrderReader(strings.NewReader("order_id,store_id\n1,main\n")).Read()
+		if err == nil || !strings.Contains(err.Error(), "invalid store_id") {
+			t.Errorf("Read() error = %v, want an invalid store_id", err)
+		}
+	})
+
+	t.Run("Malformed order", func(t *testing.T) {
+		orders := NewJSONLOrderReader(strings.NewReader("{\"Subtotal\": 10}\n{\"Subtotal\": \"ten\"}\n"))
+		_, err := NewBacktester(NewConditionValidator(), 1).Run(context.Background(), rule, orders)
+		if err == nil || !strings.Contains(err.Error(), "line 2") {
+			t.Errorf("Run() error = %v, want an error on line 2", err)
+		}
+	})
+}
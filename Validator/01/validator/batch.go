@@ -0,0 +1,236 @@
+package validator
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchResult is the outcome of evaluating one rule against one cart.
+type BatchResult struct {
+	RuleIndex int // Position of the rule in the evaluated rule set
+	CartIndex int // Position of the cart in the input stream
+	RuleID    int
+	Matched   bool
+	Discount  float64
+	Pruned    bool   // Excluded by the rule index without full evaluation
+	Variant   string // Experiment variant of the cart's customer, if the rule has an experiment
+	Err       error
+}
+
+// RuleStats aggregates the batch results of a single rule.
+type RuleStats struct {
+	RuleID        int
+	Evaluated     int
+	Matched       int
+	Errors        int
+	Pruned        int
+	TotalDiscount float64
+	Variants      map[string]*VariantStats // By experiment variant, nil for rules without an experiment
+}
+
+// VariantStats aggregates the batch results of one experiment variant of a rule.
+type VariantStats struct {
+	Carts         int // Carts whose customer was assigned the variant
+	Matched       int
+	TotalDiscount float64
+}
+
+// MatchRate returns the share of evaluated carts the rule matched.
+func (s RuleStats) MatchRate() float64 {
+	if s.Evaluated == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(s.Evaluated)
+}
+
+// BatchStats aggregates batch results over all rules.
+type BatchStats struct {
+	Carts         int
+	Evaluations   int
+	Matches       int
+	Errors        int
+	Pruned        int
+	TotalDiscount float64
+	Rules         []RuleStats // Indexed like the evaluated rule set
+}
+
+// NewBatchStats creates empty statistics for the given rule set.
+func NewBatchStats(rules []Rule) *BatchStats {
+	stats := &BatchStats{Rules: make([]RuleStats, len(rules))}
+	for i, rule := range rules {
+		stats.Rules[i].RuleID = rule.ID
+	}
+	return stats
+}
+
+// Add records a single result.
+func (s *BatchStats) Add(result BatchResult) {
+	rule := &s.Rules[result.RuleIndex]
+	s.Evaluations++
+	rule.Evaluated++
+	if result.Pruned {
+		s.Pruned++
+		rule.Pruned++
+	}
+	if result.Err != nil {
+		s.Errors++
+		rule.Errors++
+		return
+	}
+	var variant *VariantStats
+	if result.Variant != "" {
+		if rule.Variants == nil {
+			rule.Variants = make(map[string]*VariantStats)
+		}
+		if variant = rule.Variants[result.Variant]; variant == nil {
+			variant = &VariantStats{}
+			rule.Variants[result.Variant] = variant
+		}
+		variant.Carts++
+	}
+	if result.Matched {
+		s.Matches++
+		s.TotalDiscount += result.Discount
+		rule.Matched++
+		rule.TotalDiscount += result.Discount
+		if variant != nil {
+			variant.Matched++
+			variant.TotalDiscount += result.Discount
+		}
+	}
+}
+
+// BatchEvaluator evaluates many rules against a stream of carts with a bounded pool of workers.
+type BatchEvaluator struct {
+	validator *ConditionValidator
+	workers   int
+	prefilter bool
+}
+
+// NewBatchEvaluator creates a BatchEvaluator using the given number of workers (at least one).
+func NewBatchEvaluator(validator *ConditionValidator, workers int) *BatchEvaluator {
+	if workers < 1 {
+		workers = 1
+	}
+	return &BatchEvaluator{validator: validator, workers: workers}
+}
+
+// WithPrefilter makes the evaluator build a RuleIndex over the rule set and
+// skip full evaluation of rules that cannot match a cart. Skipped rules are
+// still reported, as non-matching results with Pruned set.
+func (be *BatchEvaluator) WithPrefilter() *BatchEvaluator {
+	be.prefilter = true
+	return be
+}
+
+// Evaluate evaluates every rule against every cart received on carts and
+// streams the results. The result channel is closed once carts is closed and
+// drained, or as soon as ctx is cancelled. Results arrive in no particular order.
+func (be *BatchEvaluator) Evaluate(ctx context.Context, rules []Rule, carts <-chan Cart) <-chan BatchResult {
+	type job struct {
+		index int
+		cart  Cart
+	}
+
+	jobs := make(chan job)
+	results := make(chan BatchResult, be.workers)
+
+	var prefilter *RuleIndex
+	if be.prefilter {
+		prefilter = NewRuleIndex(rules)
+	}
+
+	go func() {
+		defer close(jobs)
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case cart, ok := <-carts:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{index: index, cart: cart}:
+					index++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < be.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				var candidates map[int]bool
+				if prefilter != nil {
+					candidates = make(map[int]bool)
+					for _, i := range prefilter.Candidates(j.cart) {
+						candidates[i] = true
+					}
+				}
+				for ruleIndex, rule := range rules {
+					if ctx.Err() != nil {
+						return
+					}
+					var result BatchResult
+					if candidates != nil && !candidates[ruleIndex] {
+						result = BatchResult{RuleID: rule.ID, Pruned: true, Variant: rule.Variant(j.cart.Customer)}
+					} else {
+						result = be.evaluate(ctx, rule, j.cart)
+					}
+					result.RuleIndex = ruleIndex
+					result.CartIndex = j.index
+					select {
+					case results <- result:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// Run evaluates the batch and returns aggregate statistics. If onResult is
+// not nil it is called for every result from the calling goroutine. The
+// returned error is the context error when the run was cancelled; the
+// statistics then cover the results received so far.
+func (be *BatchEvaluator) Run(ctx context.Context, rules []Rule, carts <-chan Cart, onResult func(BatchResult)) (*BatchStats, error) {
+	stats := NewBatchStats(rules)
+	seen := make(map[int]bool)
+	for result := range be.Evaluate(ctx, rules, carts) {
+		if !seen[result.CartIndex] {
+			seen[result.CartIndex] = true
+			stats.Carts++
+		}
+		stats.Add(result)
+		if onResult != nil {
+			onResult(result)
+		}
+	}
+	return stats, ctx.Err()
+}
+
+// evaluate validates a single rule against a cart and simulates its discount.
+func (be *BatchEvaluator) evaluate(ctx context.Context, rule Rule, cart Cart) BatchResult {
+	result := BatchResult{RuleID: rule.ID, Variant: rule.Variant(cart.Customer)}
+	result.Matched, result.Err = be.validator.ValidateRuleContext(ctx, rule, cart)
+	if result.Err != nil || !result.Matched {
+		return result
+	}
+	result.Discount, result.Err = be.validator.CalculateDiscount(rule, cart)
+	return result
+}
@@ -0,0 +1,104 @@
+package validator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestBatchEvaluator(t *testing.T) {
+	rules := []Rule{
+		{
+			ID: 1, IsActive: true, SimpleAction: ActionByPercent, DiscountAmount: 10,
+			Conditions: Condition{
+				Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SKU001",
+			},
+		},
+		{
+			ID: 2, IsActive: true, SimpleAction: ActionCartFixed, DiscountAmount: 5,
+			Conditions: Condition{
+				Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2",
+			},
+		},
+	}
+
+	createCarts := func(n int) <-chan Cart {
+		carts := make(chan Cart)
+		go func() {
+			defer close(carts)
+			for i := 0; i < n; i++ {
+				sku := "SKU001"
+				if i%2 == 1 {
+					sku = "SKU002"
+				}
+				carts <- Cart{
+					Items:    []Item{{SKU: sku, Quantity: 2, Price: 10.0}},
+					Subtotal: 20.0,
+					Customer: Customer{GroupID: 2},
+				}
+			}
+		}()
+		return carts
+	}
+
+	t.Run("Aggregates match rate and discount per rule", func(t *testing.T) {
+		evaluator := NewBatchEvaluator(NewConditionValidator(), 4)
+		var streamed int
+		stats, err := evaluator.Run(context.Background(), rules, createCarts(10), func(BatchResult) { streamed++ })
+		if err != nil {
+			t.Fatalf("BatchEvaluator.Run() error = %v", err)
+		}
+		if stats.Carts != 10 || stats.Evaluations != 20 || streamed != 20 {
+			t.Errorf("got %d carts, %d evaluations, %d streamed; want 10, 20, 20", stats.Carts, stats.Evaluations, streamed)
+		}
+		if rate := stats.Rules[0].MatchRate(); rate != 0.5 {
+			t.Errorf("rule 1 match rate = %v, want 0.5", rate)
+		}
+		if rate := stats.Rules[1].MatchRate(); rate != 1 {
+			t.Errorf("rule 2 match rate = %v, want 1", rate)
+		}
+		// Rule 1: 5 carts x 10% of 20, rule 2: 10 carts x 5
+		if math.Abs(stats.TotalDiscount-60) > 1e-9 {
+			t.Errorf("total discount = %v, want 60", stats.TotalDiscount)
+		}
+	})
+
+	t.Run("Stops when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		evaluator := NewBatchEvaluator(NewConditionValidator(), 2)
+		_, err := evaluator.Run(ctx, rules, createCarts(1000), nil)
+		if err != context.Canceled {
+			t.Errorf("BatchEvaluator.Run() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestItemDiscounts(t *testing.T) {
+	validator := NewConditionValidator()
+	cart := Cart{Items: []Item{{SKU: "SKU001", Quantity: 5, Price: 10.0}}}
+
+	tests := []struct {
+		name string
+		rule Rule
+		want float64
+	}{
+		{name: "Percent", rule: Rule{SimpleAction: ActionByPercent, DiscountAmount: 20}, want: 10},
+		{name: "Percent limited by discount qty", rule: Rule{SimpleAction: ActionByPercent, DiscountAmount: 20, DiscountQty: 2}, want: 4},
+		{name: "Fixed per item", rule: Rule{SimpleAction: ActionByFixed, DiscountAmount: 3}, want: 15},
+		{name: "Fixed for whole cart", rule: Rule{SimpleAction: ActionCartFixed, DiscountAmount: 80}, want: 50},
+		{name: "Buy 2 get 1", rule: Rule{SimpleAction: ActionBuyXGetY, DiscountStep: 2, DiscountAmount: 1}, want: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := validator.CalculateDiscount(tt.rule, cart)
+			if err != nil {
+				t.Fatalf("Validator.CalculateDiscount() error = %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Validator.CalculateDiscount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
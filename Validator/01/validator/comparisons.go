@@ -1,4 +1,4 @@
-package main
+package validator
 
 import (
 	"fmt"
@@ -10,6 +10,10 @@ import (
 )
 
 func (cv *ConditionValidator) compareNumericOrString(a interface{}, operator string, b interface{}) (bool, error) {
+	if _, ok := a.(time.Time); ok {
+		return cv.compareDate(a, operator, b)
+	}
+
 	aFloat, aErr := cv.toFloat64(a)
 	bFloat, bErr := cv.toFloat64(b)
 
@@ -88,6 +92,10 @@ func (cv *ConditionValidator) compareInSet(a interface{}, operator string, b int
 			if reflect.DeepEqual(a, v) {
 				return operator == "()" || operator == "in", nil
 			}
+			// Set elements come from JSON, so match 1 against "1" the way == does
+			if equal, err := cv.compareNumericOrString(a, "==", v); err == nil && equal {
+				return operator == "()" || operator == "in", nil
+			}
 		}
 	}
 
@@ -105,8 +113,12 @@ func (cv *ConditionValidator) compareLike(a interface{}, operator string, b inte
 	if !aOk || !bOk {
 		return false, fmt.Errorf("like operator requires string values")
 	}
-	pattern := strings.ReplaceAll(bStr, "%", ".*")
-	matched, err := regexp.MatchString("(?i)"+pattern, aStr)
+	// Only % is a wildcard; everything else in the pattern is matched literally
+	parts := strings.Split(bStr, "%")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	matched, err := regexp.MatchString("(?i)"+strings.Join(parts, ".*"), aStr)
 	if err != nil {
 		return false, err
 	}
@@ -123,6 +135,12 @@ func (cv *ConditionValidator) toFloat64(v interface{}) (float64, error) {
 		return float64(v), nil
 	case int64:
 		return float64(v), nil
+	case bool:
+		// Magento stores flags as 0/1
+		if v {
+			return 1, nil
+		}
+		return 0, nil
 	case string:
 		return strconv.ParseFloat(v, 64)
 	case time.Time:
@@ -163,7 +181,13 @@ func (cv *ConditionValidator) toTime(v interface{}) (time.Time, error) {
 	case time.Time:
 		return v, nil
 	case string:
-		return time.Parse(time.RFC3339, v)
+		// Magento stores dates as "2006-01-02" or "2006-01-02 15:04:05"
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unable to parse date: %s", v)
 	case int64:
 		return time.Unix(v, 0), nil
 	case float64:
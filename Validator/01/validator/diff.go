@@ -0,0 +1,194 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// DiffKind says how a condition changed between two versions of a tree.
+type DiffKind string
+
+// Diff kinds
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// ConditionChange is one difference between two condition trees. Added and
+// changed conditions carry their path in the new tree, removed ones their path
+// in the old tree.
+type ConditionChange struct {
+	Path      string      `json:"path"`
+	Kind      DiffKind    `json:"kind"`
+	Field     string      `json:"field,omitempty"` // The changed field: type, attribute, operator, value or aggregator
+	Old       interface{} `json:"old,omitempty"`
+	New       interface{} `json:"new,omitempty"`
+	Condition *Condition  `json:"condition,omitempty"` // The added or removed subtree
+}
+
+// FieldChange is a changed rule setting other than the conditions and actions.
+type FieldChange struct {
+	Field string      `json:"field"` // JSON name of the setting
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RuleDiff is the difference between two versions of a rule.
+type RuleDiff struct {
+	Fields     []FieldChange     `json:"fields,omitempty"`
+	Conditions []ConditionChange `json:"conditions,omitempty"`
+	Actions    []ConditionChange `json:"actions,omitempty"`
+}
+
+// Empty reports whether the versions are the same.
+func (d RuleDiff) Empty() bool {
+	return len(d.Fields) == 0 && len(d.Conditions) == 0 && len(d.Actions) == 0
+}
+
+// DiffConditions returns the changes that turn the old condition tree into the
+// new one. Children left unchanged are matched even when others were inserted
+// or removed around them; the remaining children are compared by position.
+func DiffConditions(old, new Condition) []ConditionChange {
+	var changes []ConditionChange
+	diffCondition(RootPath, RootPath, old, new, &changes)
+	return changes
+}
+
+// diffCondition appends the changes between two conditions at the given paths.
+func diffCondition(oldPath, newPath string, old, new Condition, changes *[]ConditionChange) {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"type", old.Type, new.Type},
+		{"attribute", old.Attribute, new.Attribute},
+		{"operator", old.Operator, new.Operator},
+		{"value", old.Value, new.Value},
+		{"aggregator", old.Aggregator, new.Aggregator},
+	}
+	for _, field := range fields {
+		if jsonKey(field.old) != jsonKey(field.new) {
+			*changes = append(*changes, ConditionChange{Path: newPath, Kind: DiffChanged, Field: field.name, Old: field.old, New: field.new})
+		}
+	}
+
+	// Match unchanged children by their longest common subsequence
+	oldKeys := make([]string, len(old.Conditions))
+	for i, child := range old.Conditions {
+		oldKeys[i] = jsonKey(child)
+	}
+	newKeys := make([]string, len(new.Conditions))
+	for i, child := range new.Conditions {
+		newKeys[i] = jsonKey(child)
+	}
+	lcs := make([][]int, len(oldKeys)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newKeys)+1)
+	}
+	for i := len(oldKeys) - 1; i >= 0; i-- {
+		for j := len(newKeys) - 1; j >= 0; j-- {
+			switch {
+			case oldKeys[i] == newKeys[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Children between two matches are compared pairwise, the rest added or removed
+	var removed, added []int
+	flush := func() {
+		for len(removed) > 0 && len(added) > 0 {
+			i, j := removed[0], added[0]
+			diffCondition(ChildPath(oldPath, i), ChildPath(newPath, j), old.Conditions[i], new.Conditions[j], changes)
+			removed, added = removed[1:], added[1:]
+		}
+		for _, i := range removed {
+			*changes = append(*changes, ConditionChange{Path: ChildPath(oldPath, i), Kind: DiffRemoved, Condition: &old.Conditions[i]})
+		}
+		for _, j := range added {
+			*changes = append(*changes, ConditionChange{Path: ChildPath(newPath, j), Kind: DiffAdded, Condition: &new.Conditions[j]})
+		}
+		removed, added = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(oldKeys) || j < len(newKeys) {
+		switch {
+		case i < len(oldKeys) && j < len(newKeys) && oldKeys[i] == newKeys[j]:
+			flush()
+			i++
+			j++
+		case j >= len(newKeys) || (i < len(oldKeys) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, i)
+			i++
+		default:
+			added = append(added, j)
+			j++
+		}
+	}
+	flush()
+}
+
+// DiffRules returns the changes between two versions of a rule.
+func DiffRules(old, new Rule) (RuleDiff, error) {
+	diff := RuleDiff{
+		Conditions: DiffConditions(old.Conditions, new.Conditions),
+		Actions:    DiffConditions(old.Actions, new.Actions),
+	}
+	oldFields, err := ruleFields(old)
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	newFields, err := ruleFields(new)
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	names := make(map[string]bool)
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		if jsonKey(oldFields[name]) != jsonKey(newFields[name]) {
+			diff.Fields = append(diff.Fields, FieldChange{Field: name, Old: oldFields[name], New: newFields[name]})
+		}
+	}
+	return diff, nil
+}
+
+// ruleFields returns the JSON settings of a rule without its condition trees.
+func ruleFields(rule Rule) (map[string]interface{}, error) {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rule %d: %v", rule.ID, err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode rule %d: %v", rule.ID, err)
+	}
+	delete(fields, "conditions")
+	delete(fields, "actions")
+	return fields, nil
+}
+
+// jsonKey returns the JSON encoding of a value, so that values decoded from
+// JSON compare equal to the values they were encoded from.
+func jsonKey(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%#v", value)
+	}
+	return string(data)
+}
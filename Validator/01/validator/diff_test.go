@@ -0,0 +1,82 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConditions(t *testing.T) {
+	subtotal := Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"}
+	country := Condition{Type: TypeAddress, Attribute: "country_id", Operator: "==", Value: "US"}
+	group := Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "1"}
+	combine := func(aggregator string, conditions ...Condition) Condition {
+		return Condition{Type: TypeCombine, Aggregator: aggregator, Value: "1", Conditions: conditions}
+	}
+	raised := subtotal
+	raised.Value = 150.0
+
+	tests := []struct {
+		name     string
+		old, new Condition
+		want     []ConditionChange
+	}{
+		{
+			name: "Same tree",
+			old:  combine("all", subtotal, country),
+			new:  combine("all", subtotal, country),
+			want: nil,
+		},
+		{
+			name: "Changed value and aggregator",
+			old:  combine("all", subtotal, country),
+			new:  combine("any", raised, country),
+			want: []ConditionChange{
+				{Path: "$", Kind: DiffChanged, Field: "aggregator", Old: "all", New: "any"},
+				{Path: "$.conditions[0]", Kind: DiffChanged, Field: "value", Old: "100", New: 150.0},
+			},
+		},
+		{
+			name: "Inserted condition keeps the others matched",
+			old:  combine("all", subtotal, country),
+			new:  combine("all", group, subtotal, country),
+			want: []ConditionChange{{Path: "$.conditions[0]", Kind: DiffAdded, Condition: &group}},
+		},
+		{
+			name: "Removed condition",
+			old:  combine("all", subtotal, group, country),
+			new:  combine("all", subtotal, country),
+			want: []ConditionChange{{Path: "$.conditions[1]", Kind: DiffRemoved, Condition: &group}},
+		},
+		{
+			name: "Nested change",
+			old:  combine("all", combine("any", subtotal, country)),
+			new:  combine("all", combine("any", raised, country)),
+			want: []ConditionChange{{Path: "$.conditions[0].conditions[0]", Kind: DiffChanged, Field: "value", Old: "100", New: 150.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffConditions(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffConditions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRules(t *testing.T) {
+	old := Rule{ID: 1, Name: "Spring sale", IsActive: true, DiscountAmount: 10, Conditions: Condition{Type: TypeCombine, Aggregator: "all", Value: "1"}}
+	new := old
+	new.DiscountAmount = 15
+	new.WebsiteIDs = []int{1}
+	diff, err := DiffRules(old, new)
+	if err != nil {
+		t.Fatalf("DiffRules() error = %v", err)
+	}
+	want := []FieldChange{{Field: "discount_amount", Old: 10.0, New: 15.0}, {Field: "website_ids", Old: nil, New: []interface{}{1.0}}}
+	if !reflect.DeepEqual(diff.Fields, want) || len(diff.Conditions) != 0 || len(diff.Actions) != 0 {
+		t.Errorf("DiffRules() = %+v, want fields %+v", diff, want)
+	}
+	if same, _ := DiffRules(old, old); !same.Empty() {
+		t.Errorf("DiffRules() of a rule with itself = %+v, want no changes", same)
+	}
+}
@@ -0,0 +1,130 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// ItemDiscount is the discount a rule gives to a single cart item.
+type ItemDiscount struct {
+	Item   Item
+	Path   string // Position of the item in the cart, such as items[0]
+	Amount float64
+}
+
+// ValidateRule checks whether a rule applies to the cart: the rule must be
+// active, the cart must fall within the rule dates, the rule's website and
+// customer group scope and its experiment variants, and the conditions must match.
+func (cv *ConditionValidator) ValidateRule(rule Rule, cart Cart) (bool, error) {
+	return cv.ValidateRuleContext(context.Background(), rule, cart)
+}
+
+// ValidateRuleContext is like ValidateRule but stops evaluating once ctx is done.
+func (cv *ConditionValidator) ValidateRuleContext(ctx context.Context, rule Rule, cart Cart) (bool, error) {
+	if err := rule.checkExperiment(); err != nil {
+		return false, err
+	}
+	if !cv.ruleApplies(rule, cart) {
+		return false, nil
+	}
+	if IsEmptyCondition(rule.Conditions) {
+		return true, nil
+	}
+	return cv.ValidateContext(ctx, rule.Conditions, cart)
+}
+
+// ruleApplies reports whether the rule is active on the day the cart was
+// created and covers the cart's website, customer group and experiment variant.
+func (cv *ConditionValidator) ruleApplies(rule Rule, cart Cart) bool {
+	if !rule.IsActive || !rule.InScope(cart) || !rule.inVariant(cart.Customer) {
+		return false
+	}
+	if !cart.CreatedAt.IsZero() {
+		if !rule.FromDate.IsZero() && cart.CreatedAt.Before(rule.FromDate) {
+			return false
+		}
+		if !rule.ToDate.IsZero() && cart.CreatedAt.After(rule.ToDate) {
+			return false
+		}
+	}
+	return true
+}
+
+// CalculateDiscount returns the total discount the rule actions give to the cart.
+// It does not check the rule conditions; use ValidateRule for that.
+func (cv *ConditionValidator) CalculateDiscount(rule Rule, cart Cart) (float64, error) {
+	discounts, err := cv.ItemDiscounts(rule, cart)
+	if err != nil {
+		return 0, err
+	}
+	var total float64
+	for _, discount := range discounts {
+		total += discount.Amount
+	}
+	return total, nil
+}
+
+// ItemDiscounts returns the discount of every item selected by the rule actions,
+// following Magento's simple actions (by_percent, by_fixed, cart_fixed, buy_x_get_y).
+func (cv *ConditionValidator) ItemDiscounts(rule Rule, cart Cart) ([]ItemDiscount, error) {
+	lines, err := cv.actionLines(context.Background(), rule.Actions, cart)
+	if err != nil {
+		return nil, err
+	}
+
+	discounts := make([]ItemDiscount, 0, len(lines))
+	switch rule.SimpleAction {
+	case ActionByPercent, ActionByFixed, ActionBuyXGetY:
+		for _, line := range lines {
+			discounts = append(discounts, ItemDiscount{Item: line.item, Path: line.path, Amount: cv.itemDiscount(rule, line.item)})
+		}
+	case ActionCartFixed:
+		// The fixed amount is spread over the items in proportion to their row totals
+		var total float64
+		for _, line := range lines {
+			total += line.item.Price * float64(line.item.Quantity)
+		}
+		amount := math.Min(rule.DiscountAmount, total)
+		for _, line := range lines {
+			share := 0.0
+			if total > 0 {
+				share = amount * line.item.Price * float64(line.item.Quantity) / total
+			}
+			discounts = append(discounts, ItemDiscount{Item: line.item, Path: line.path, Amount: share})
+		}
+	default:
+		return nil, fmt.Errorf("unknown discount action: %s", rule.SimpleAction)
+	}
+	return discounts, nil
+}
+
+// itemDiscount calculates a per-item discount, limited to DiscountQty units when set.
+func (cv *ConditionValidator) itemDiscount(rule Rule, item Item) float64 {
+	qty := float64(item.Quantity)
+	if rule.DiscountQty > 0 && qty > rule.DiscountQty {
+		qty = rule.DiscountQty
+	}
+
+	switch rule.SimpleAction {
+	case ActionByPercent:
+		return item.Price * qty * math.Min(rule.DiscountAmount, 100) / 100
+	case ActionByFixed:
+		return math.Min(rule.DiscountAmount, item.Price) * qty
+	case ActionBuyXGetY:
+		// Buy DiscountStep (X) items and get DiscountAmount (Y) items free
+		x := float64(rule.DiscountStep)
+		y := rule.DiscountAmount
+		if x <= 0 || y <= 0 {
+			return 0
+		}
+		periods := math.Floor(qty / (x + y))
+		freeQty := periods * y
+		if rest := qty - periods*(x+y); rest > x {
+			freeQty += rest - x
+		}
+		return item.Price * freeQty
+	default:
+		return 0
+	}
+}
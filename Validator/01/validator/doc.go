@@ -0,0 +1,18 @@
+// Package validator evaluates Magento sales rule conditions against shopping carts.
+//
+// Conditions are decoded from the JSON Magento stores for a rule into a
+// Condition tree and evaluated by a ConditionValidator:
+//
+//	var condition validator.Condition
+//	if err := json.Unmarshal(data, &condition); err != nil {
+//		return err
+//	}
+//	valid, err := validator.NewConditionValidator().Validate(condition, cart)
+//
+// The package is organised by concern: models.go holds the cart and rule
+// models, validator.go the evaluation engine, comparisons.go the operators and
+// attributes.go the attribute lookups for items, addresses and customers.
+package validator
+
+// Version is the version of the validator library.
+const Version = "1.0.0"
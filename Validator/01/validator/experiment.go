@@ -0,0 +1,161 @@
+package validator
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Experiment splits customers into weighted variants for A/B testing a
+// promotion. A customer always lands in the same variant: the bucket is a hash
+// of the salt and the customer ID, or the email for guests. Changing the salt
+// reshuffles customers; changing weights moves only the customers near the
+// variant boundaries.
+type Experiment struct {
+	Name     string    `json:"name"`
+	Salt     string    `json:"salt,omitempty"` // Defaults to the name
+	Variants []Variant `json:"variants"`
+}
+
+// Variant is one arm of an experiment, receiving a share of customers
+// proportional to its weight, e.g. 20 and 80 for a 20% experiment.
+type Variant struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+}
+
+// Validate checks that the experiment has named variants with positive total weight.
+func (e Experiment) Validate() error {
+	if len(e.Variants) == 0 {
+		return fmt.Errorf("experiment %s has no variants", e.Name)
+	}
+	var total float64
+	seen := make(map[string]bool)
+	for _, variant := range e.Variants {
+		if variant.Name == "" {
+			return fmt.Errorf("experiment %s has a variant without a name", e.Name)
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("experiment %s has duplicate variant %s", e.Name, variant.Name)
+		}
+		if variant.Weight < 0 {
+			return fmt.Errorf("experiment %s: variant %s has negative weight %v", e.Name, variant.Name, variant.Weight)
+		}
+		seen[variant.Name] = true
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return fmt.Errorf("experiment %s: variant weights add up to zero", e.Name)
+	}
+	return nil
+}
+
+// Assign returns the variant of the customer. Customers with neither an ID nor
+// an email cannot be bucketed and get no variant.
+func (e Experiment) Assign(customer Customer) (string, error) {
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
+	return e.assign(customer), nil
+}
+
+// assign returns the variant of the customer in a valid experiment.
+func (e Experiment) assign(customer Customer) string {
+	key := bucketKey(customer)
+	if key == "" {
+		return ""
+	}
+	salt := e.Salt
+	if salt == "" {
+		salt = e.Name
+	}
+	sum := sha256.Sum256([]byte(salt + "\x00" + key))
+	// The top 53 bits give a uniform point in [0, 1)
+	point := float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+
+	var total float64
+	for _, variant := range e.Variants {
+		total += variant.Weight
+	}
+	point *= total
+	for _, variant := range e.Variants {
+		if point < variant.Weight {
+			return variant.Name
+		}
+		point -= variant.Weight
+	}
+	// Rounding can leave the point just past the last variant with weight
+	for i := len(e.Variants) - 1; i >= 0; i-- {
+		if e.Variants[i].Weight > 0 {
+			return e.Variants[i].Name
+		}
+	}
+	return ""
+}
+
+// bucketKey returns the identity a customer is bucketed by.
+func bucketKey(customer Customer) string {
+	if customer.ID != 0 {
+		return "id:" + strconv.Itoa(customer.ID)
+	}
+	if email := strings.ToLower(strings.TrimSpace(customer.Email)); email != "" {
+		return "email:" + email
+	}
+	return ""
+}
+
+// Variant returns the experiment variant of the customer, or "" when the rule
+// has no experiment, the experiment is invalid or the customer cannot be bucketed.
+func (r Rule) Variant(customer Customer) string {
+	if r.Experiment == nil || r.Experiment.Validate() != nil {
+		return ""
+	}
+	return r.Experiment.assign(customer)
+}
+
+// inVariant reports whether the rule applies to the customer's experiment
+// variant. Rules without an experiment apply to every customer.
+func (r Rule) inVariant(customer Customer) bool {
+	if r.Experiment == nil {
+		return true
+	}
+	variant := r.Variant(customer)
+	if variant == "" {
+		return false
+	}
+	if len(r.Variants) == 0 {
+		return true
+	}
+	for _, name := range r.Variants {
+		if name == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExperiment validates the rule's experiment and that the variants the
+// rule applies to belong to it.
+func (r Rule) checkExperiment() error {
+	if r.Experiment == nil {
+		if len(r.Variants) > 0 {
+			return fmt.Errorf("rule %d lists variants without an experiment", r.ID)
+		}
+		return nil
+	}
+	if err := r.Experiment.Validate(); err != nil {
+		return err
+	}
+	for _, name := range r.Variants {
+		found := false
+		for _, variant := range r.Experiment.Variants {
+			found = found || variant.Name == name
+		}
+		if !found {
+			return fmt.Errorf("experiment %s has no variant %s", r.Experiment.Name, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package validator
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestExperimentAssign(t *testing.T) {
+	experiment := Experiment{Name: "spring-promo", Salt: "2024-03", Variants: []Variant{{Name: "promo", Weight: 20}, {Name: "control", Weight: 80}}}
+
+	t.Run("Deterministic", func(t *testing.T) {
+		for id := 1; id <= 100; id++ {
+			first, _ := experiment.Assign(Customer{ID: id})
+			second, _ := experiment.Assign(Customer{ID: id, Email: "other@example.com"})
+			if first != second {
+				t.Fatalf("Assign() for customer %d = %q then %q", id, first, second)
+			}
+		}
+		guest, _ := experiment.Assign(Customer{Email: " Ann@Example.com"})
+		if same, _ := experiment.Assign(Customer{Email: "ann@example.com"}); guest != same || guest == "" {
+			t.Errorf("Assign() for a guest = %q and %q, want the same variant", guest, same)
+		}
+		if variant, _ := experiment.Assign(Customer{}); variant != "" {
+			t.Errorf("Assign() without an identity = %q, want no variant", variant)
+		}
+	})
+
+	t.Run("Weighted split", func(t *testing.T) {
+		counts := make(map[string]int)
+		for id := 1; id <= 20000; id++ {
+			variant, err := experiment.Assign(Customer{ID: id})
+			if err != nil {
+				t.Fatal(err)
+			}
+			counts[variant]++
+		}
+		if share := float64(counts["promo"]) / 20000; math.Abs(share-0.2) > 0.01 {
+			t.Errorf("promo share = %.3f, want 0.2", share)
+		}
+		if counts["promo"]+counts["control"] != 20000 {
+			t.Errorf("counts = %v, want only promo and control", counts)
+		}
+	})
+
+	t.Run("Salt reshuffles customers", func(t *testing.T) {
+		resalted := experiment
+		resalted.Salt = "2024-04"
+		moved := 0
+		for id := 1; id <= 1000; id++ {
+			before, _ := experiment.Assign(Customer{ID: id})
+			after, _ := resalted.Assign(Customer{ID: id})
+			if before != after {
+				moved++
+			}
+		}
+		if moved == 0 {
+			t.Error("changing the salt moved no customer")
+		}
+	})
+
+	invalid := []Experiment{
+		{Name: "empty"},
+		{Name: "zero", Variants: []Variant{{Name: "a"}, {Name: "b"}}},
+		{Name: "negative", Variants: []Variant{{Name: "a", Weight: -1}, {Name: "b", Weight: 2}}},
+		{Name: "duplicate", Variants: []Variant{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}}},
+		{Name: "unnamed", Variants: []Variant{{Weight: 1}}},
+	}
+	for _, e := range invalid {
+		if _, err := e.Assign(Customer{ID: 1}); err == nil {
+			t.Errorf("Assign() for experiment %s error = nil, want an error", e.Name)
+		}
+	}
+}
+
+func TestExperimentRules(t *testing.T) {
+	experiment := &Experiment{Name: "spring-promo", Variants: []Variant{{Name: "promo", Weight: 20}, {Name: "control", Weight: 80}}}
+	rule := Rule{ID: 1, IsActive: true, SimpleAction: ActionByPercent, DiscountAmount: 10, Experiment: experiment, Variants: []string{"promo"}}
+	cart := func(id int) Cart {
+		return Cart{Items: []Item{{SKU: "TEE", Quantity: 1, Price: 20}}, Subtotal: 20, Customer: Customer{ID: id}}
+	}
+	cv := NewConditionValidator()
+
+	t.Run("Applies only to its variant", func(t *testing.T) {
+		for id := 1; id <= 50; id++ {
+			matched, err := cv.ValidateRule(rule, cart(id))
+			if err != nil {
+				t.Fatalf("ValidateRule() error = %v", err)
+			}
+			if want := rule.Variant(Customer{ID: id}) == "promo"; matched != want {
+				t.Errorf("ValidateRule() for customer %d = %v, want %v", id, matched, want)
+			}
+		}
+		if matched, _ := cv.ValidateRule(rule, Cart{Subtotal: 20}); matched {
+			t.Error("ValidateRule() matched a customer without an identity")
+		}
+	})
+
+	t.Run("Invalid experiment", func(t *testing.T) {
+		broken := rule
+		broken.Variants = []string{"treatment"}
+		if _, err := cv.ValidateRule(broken, cart(1)); err == nil {
+			t.Error("ValidateRule() error = nil, want an error for an unknown variant")
+		}
+	})
+
+	t.Run("Batch statistics by variant", func(t *testing.T) {
+		carts := make(chan Cart)
+		go func() {
+			defer close(carts)
+			for id := 1; id <= 1000; id++ {
+				carts <- cart(id)
+			}
+		}()
+		stats, err := NewBatchEvaluator(cv, 4).Run(context.Background(), []Rule{rule}, carts, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		variants := stats.Rules[0].Variants
+		promo, control := variants["promo"], variants["control"]
+		if promo == nil || control == nil || promo.Carts+control.Carts != 1000 {
+			t.Fatalf("Variants = %v, want promo and control covering 1000 carts", variants)
+		}
+		if promo.Matched != promo.Carts || control.Matched != 0 || promo.Matched != stats.Rules[0].Matched {
+			t.Errorf("promo matched %d of %d, control matched %d", promo.Matched, promo.Carts, control.Matched)
+		}
+		if math.Abs(promo.TotalDiscount-float64(promo.Matched)*2) > 1e-9 {
+			t.Errorf("promo discount = %v, want %v", promo.TotalDiscount, float64(promo.Matched)*2)
+		}
+	})
+
+	t.Run("Resolution reports the variant", func(t *testing.T) {
+		id := 1
+		for rule.Variant(Customer{ID: id}) != "promo" {
+			id++
+		}
+		resolution, err := NewConflictResolver(cv, ResolutionPolicy{}).Resolve([]Rule{rule}, cart(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resolution.Applied) != 1 || resolution.Applied[0].Variant != "promo" {
+			t.Errorf("Applied = %+v, want rule 1 in variant promo", resolution.Applied)
+		}
+	})
+
+	t.Run("Different variants never overlap", func(t *testing.T) {
+		control := rule
+		control.ID, control.Variants = 2, []string{"control"}
+		everyone := rule
+		everyone.ID, everyone.Variants = 3, nil
+		analysis := NewAnalyzer().AnalyzeRules([]Rule{rule, control, everyone})
+		want := []RuleOverlap{{RuleID: 1, OtherRuleID: 3}, {RuleID: 2, OtherRuleID: 3}}
+		if !reflect.DeepEqual(analysis.Overlaps, want) {
+			t.Errorf("Overlaps = %v, want %v", analysis.Overlaps, want)
+		}
+	})
+}
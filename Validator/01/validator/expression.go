@@ -0,0 +1,808 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The rule expression language is a compact textual form of condition trees:
+//
+//	all(subtotal >= 100, customer.group_id in (1, 2), found item(sku == "1012096"))
+//
+// Cart-level expressions are:
+//
+//	all(...), any(...)                    Combine, conditions expected TRUE
+//	all false(...), any false(...)        Combine, conditions expected FALSE
+//	found item(...), found any item(...)  Product\Found (ALL or ANY item conditions)
+//	not found item(...)                   Product\Found, NOT FOUND
+//	total qty of items(...) >= 2          Product\Subselect ("of any items" for ANY)
+//	customer.<attribute> <op> <value>     Customer
+//	product.<attribute> <op> <value>      Product, matched by any item in the cart
+//	<attribute> <op> <value>              Address (address.<attribute> is also accepted)
+//	billing_address.<attribute> ...       Address, reading the billing address
+//	segment in ("vip", "lapsed")          Segment, with ==, !=, in and not in
+//
+// Inside item(...) and items(...), all(...) and any(...) are Product\Combine
+// conditions and bare attributes are Product attributes.
+//
+// Attributes may be paths, such as customer.addresses[*].country or, inside
+// item(...), attributes.color. A list segment takes an index, * or any to
+// match some element, or all to match every element.
+//
+// Operators are ==, !=, >, >=, <, <=, contains, not contains, in, not in,
+// like, not like, is null and is not null. Values are numbers, quoted strings,
+// null or parenthesised lists. Numbers are kept as their literal text, the way
+// Magento stores condition values.
+
+// SyntaxError reports an invalid rule expression and where it was found.
+type SyntaxError struct {
+	Offset int // Byte offset in the source
+	Line   int // 1-based line
+	Column int // 1-based column, in characters
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Expression operators and their condition operators
+var (
+	expressionOperators = map[string]string{
+		"==":           "==",
+		"!=":           "!=",
+		">":            ">",
+		">=":           ">=",
+		"<":            "<",
+		"<=":           "<=",
+		"contains":     "{}",
+		"not contains": "!{}",
+		"in":           "()",
+		"not in":       "!()",
+		"like":         "like",
+		"not like":     "nlike",
+		"is null":      "null",
+		"is not null":  "notnull",
+	}
+	expressionKeywords = map[string]bool{
+		"all": true, "any": true, "found": true, "not": true, "total": true,
+		"customer": true, "address": true, "product": true, "segment": true,
+	}
+	// Entities that qualify attributes in cart context only
+	cartEntities = map[string]bool{
+		"customer": true, "address": true, "billing_address": true, "shipping_address": true,
+	}
+	// Operators of segment conditions
+	segmentOperators   = map[string]bool{"==": true, "!=": true, "()": true, "!()": true}
+	conditionOperators = func() map[string]string {
+		operators := make(map[string]string, len(expressionOperators))
+		for expression, operator := range expressionOperators {
+			operators[operator] = expression
+		}
+		return operators
+	}()
+)
+
+// Token kinds of the rule expression language
+const (
+	tokenEOF = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+	tokenPunct
+)
+
+type token struct {
+	kind   int
+	text   string
+	offset int
+}
+
+// ParseExpression parses a cart-level rule expression into a condition tree.
+func ParseExpression(src string) (Condition, error) {
+	return parseExpression(src, false)
+}
+
+// ParseActionExpression parses an item-level expression, such as the
+// actions of a rule, into a condition tree of Product conditions.
+func ParseActionExpression(src string) (Condition, error) {
+	return parseExpression(src, true)
+}
+
+// parseExpression parses a whole expression in cart or item context.
+func parseExpression(src string, item bool) (Condition, error) {
+	p := &expressionParser{src: src}
+	if err := p.tokenize(); err != nil {
+		return Condition{}, err
+	}
+	var condition Condition
+	var err error
+	if item {
+		condition, err = p.parseItem()
+	} else {
+		condition, err = p.parseCart()
+	}
+	if err != nil {
+		return Condition{}, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return Condition{}, p.errorAt(tok, "unexpected %s after expression", p.describe(tok))
+	}
+	return condition, nil
+}
+
+// expressionParser is a recursive descent parser over the tokens of an expression.
+type expressionParser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+// tokenize splits the source into tokens.
+func (p *expressionParser) tokenize() error {
+	i := 0
+	for i < len(p.src) {
+		c := rune(p.src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case isIdentByte(p.src[i], true):
+			start := i
+			for i < len(p.src) && isIdentByte(p.src[i], false) {
+				i++
+			}
+			p.tokens = append(p.tokens, token{kind: tokenIdent, text: p.src[start:i], offset: start})
+		case isDigitByte(p.src[i]) || (c == '-' && i+1 < len(p.src) && isDigitByte(p.src[i+1])):
+			start := i
+			i++
+			for i < len(p.src) && (isDigitByte(p.src[i]) || p.src[i] == '.') {
+				i++
+			}
+			text := p.src[start:i]
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return p.errorAtOffset(start, "invalid number %q", text)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenNumber, text: text, offset: start})
+		case c == '"':
+			start := i
+			i++
+			for i < len(p.src) && p.src[i] != '"' {
+				if p.src[i] == '\\' {
+					i++
+				}
+				if i < len(p.src) && p.src[i] == '\n' {
+					break
+				}
+				i++
+			}
+			if i >= len(p.src) || p.src[i] != '"' {
+				return p.errorAtOffset(start, "unterminated string")
+			}
+			i++
+			text, err := strconv.Unquote(p.src[start:i])
+			if err != nil {
+				return p.errorAtOffset(start, "invalid string %s", p.src[start:i])
+			}
+			p.tokens = append(p.tokens, token{kind: tokenString, text: text, offset: start})
+		case strings.ContainsRune("=!<>", c):
+			start := i
+			i++
+			if i < len(p.src) && p.src[i] == '=' {
+				i++
+			}
+			text := p.src[start:i]
+			if _, ok := expressionOperators[text]; !ok {
+				return p.errorAtOffset(start, "invalid operator %q", text)
+			}
+			p.tokens = append(p.tokens, token{kind: tokenOperator, text: text, offset: start})
+		case strings.ContainsRune("(),.[]*", c):
+			p.tokens = append(p.tokens, token{kind: tokenPunct, text: string(c), offset: i})
+			i++
+		default:
+			r, _ := utf8.DecodeRuneInString(p.src[i:])
+			return p.errorAtOffset(i, "unexpected character %q", r)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokenEOF, offset: len(p.src)})
+	return nil
+}
+
+// parseCart parses a cart-level condition.
+func (p *expressionParser) parseCart() (Condition, error) {
+	tok := p.peek()
+	if tok.kind == tokenIdent {
+		switch {
+		case p.isCombine():
+			return p.parseCombine(TypeCombine, p.parseCart)
+		case tok.text == "found" && p.peekAt(1).kind == tokenIdent:
+			return p.parseFound(true)
+		case tok.text == "not" && p.peekAt(1).text == "found":
+			p.next()
+			return p.parseFound(false)
+		case tok.text == "total" && p.peekAt(1).kind == tokenIdent:
+			return p.parseSubselect()
+		case tok.text == "segment" && p.peekAt(1).text != "." && p.peekAt(1).text != "[":
+			return p.parseSegment()
+		}
+	}
+	return p.parseComparison(false)
+}
+
+// parseItem parses an item-level condition.
+func (p *expressionParser) parseItem() (Condition, error) {
+	if p.isCombine() {
+		return p.parseCombine(TypeProductCombine, p.parseItem)
+	}
+	return p.parseComparison(true)
+}
+
+// isCombine reports whether the next tokens start an all(...) or any(...) group.
+func (p *expressionParser) isCombine() bool {
+	tok := p.peek()
+	if tok.kind != tokenIdent || (tok.text != "all" && tok.text != "any") {
+		return false
+	}
+	next := p.peekAt(1)
+	return next.text == "(" || ((next.text == "false" || next.text == "true") && p.peekAt(2).text == "(")
+}
+
+// parseCombine parses "all|any [true|false] (conditions)".
+func (p *expressionParser) parseCombine(conditionType string, parseChild func() (Condition, error)) (Condition, error) {
+	condition := Condition{Type: conditionType, Aggregator: p.next().text, Value: "1"}
+	if tok := p.peek(); tok.text == "false" || tok.text == "true" {
+		p.next()
+		if tok.text == "false" {
+			condition.Value = "0"
+		}
+	}
+	conditions, err := p.parseList(parseChild)
+	if err != nil {
+		return Condition{}, err
+	}
+	condition.Conditions = conditions
+	return condition, nil
+}
+
+// parseFound parses "found [all|any] item(conditions)", after any leading "not".
+func (p *expressionParser) parseFound(found bool) (Condition, error) {
+	if _, err := p.expect("found"); err != nil {
+		return Condition{}, err
+	}
+	condition := Condition{Type: TypeProductFound, Aggregator: "all", Value: "1"}
+	if !found {
+		condition.Value = "0"
+	}
+	if tok := p.peek(); tok.text == "all" || tok.text == "any" {
+		condition.Aggregator = p.next().text
+	}
+	if _, err := p.expect("item"); err != nil {
+		return Condition{}, err
+	}
+	conditions, err := p.parseList(p.parseItem)
+	if err != nil {
+		return Condition{}, err
+	}
+	condition.Conditions = conditions
+	return condition, nil
+}
+
+// parseSubselect parses "total <attribute> of [all|any] items(conditions) <op> <value>".
+func (p *expressionParser) parseSubselect() (Condition, error) {
+	p.next()
+	attribute := p.next()
+	condition := Condition{Type: TypeSubselect, Attribute: attribute.text, Aggregator: "all"}
+	if _, err := p.expect("of"); err != nil {
+		return Condition{}, err
+	}
+	if tok := p.peek(); tok.text == "all" || tok.text == "any" {
+		condition.Aggregator = p.next().text
+	}
+	if _, err := p.expect("items"); err != nil {
+		return Condition{}, err
+	}
+	conditions, err := p.parseList(p.parseItem)
+	if err != nil {
+		return Condition{}, err
+	}
+	condition.Conditions = conditions
+	if err := p.parseOperatorAndValue(&condition); err != nil {
+		return Condition{}, err
+	}
+	return condition, nil
+}
+
+// parseSegment parses "segment <op> <value>".
+func (p *expressionParser) parseSegment() (Condition, error) {
+	p.next()
+	condition := Condition{Type: TypeSegment}
+	operator := p.peek()
+	if err := p.parseOperatorAndValue(&condition); err != nil {
+		return Condition{}, err
+	}
+	if !segmentOperators[condition.Operator] {
+		return Condition{}, p.errorAt(operator, "operator %s cannot be used on customer segments", p.describe(operator))
+	}
+	return condition, nil
+}
+
+// parseComparison parses "[entity.]attribute <op> [value]".
+func (p *expressionParser) parseComparison(item bool) (Condition, error) {
+	tok := p.peek()
+	if tok.kind != tokenIdent {
+		return Condition{}, p.errorAt(tok, "expected a condition, found %s", p.describe(tok))
+	}
+
+	condition := Condition{Type: TypeAddress}
+	if item {
+		condition.Type = TypeProduct
+	}
+	prefix := ""
+	if p.peekAt(1).text == "." {
+		entity := true
+		switch {
+		case tok.text == "customer" && !item:
+			condition.Type = TypeCustomer
+		case tok.text == "address" && !item:
+			condition.Type = TypeAddress
+		case (tok.text == "billing_address" || tok.text == "shipping_address") && !item:
+			condition.Type = TypeAddress
+			prefix = tok.text + "."
+		case tok.text == "product":
+			condition.Type = TypeProduct
+		case item && !cartEntities[tok.text]:
+			// A path into the item, such as attributes.color
+			entity = false
+		default:
+			return Condition{}, p.errorAt(tok, "unknown entity %q", tok.text)
+		}
+		if entity {
+			p.next()
+			p.next()
+		}
+	}
+
+	attribute, err := p.parseAttribute()
+	if err != nil {
+		return Condition{}, err
+	}
+	condition.Attribute = prefix + attribute
+
+	if err := p.parseOperatorAndValue(&condition); err != nil {
+		return Condition{}, err
+	}
+	return condition, nil
+}
+
+// parseAttribute parses an attribute code or a path such as addresses[*].country.
+func (p *expressionParser) parseAttribute() (string, error) {
+	start := p.peek()
+	var sb strings.Builder
+	for {
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return "", p.errorAt(tok, "expected an attribute name, found %s", p.describe(tok))
+		}
+		sb.WriteString(tok.text)
+		if next := p.peek(); next.text == "[" && next.kind == tokenPunct {
+			p.next()
+			selector := p.next()
+			if !isExpressionSelector(selector.text) || selector.kind == tokenString {
+				return "", p.errorAt(selector, "expected an index, *, any or all, found %s", p.describe(selector))
+			}
+			if _, err := p.expect("]"); err != nil {
+				return "", err
+			}
+			sb.WriteString("[" + selector.text + "]")
+		}
+		if next := p.peek(); next.text != "." || next.kind != tokenPunct {
+			break
+		}
+		p.next()
+		sb.WriteString(".")
+	}
+	attribute := sb.String()
+	if isAttributePath(attribute) {
+		if _, err := parseAttributePath(attribute); err != nil {
+			return "", p.errorAt(start, "%v", err)
+		}
+	}
+	return attribute, nil
+}
+
+// parseOperatorAndValue parses a comparison operator and, unless it is a null check, its value.
+func (p *expressionParser) parseOperatorAndValue(condition *Condition) error {
+	tok := p.next()
+	operator := tok.text
+	switch {
+	case tok.kind == tokenOperator:
+	case tok.kind == tokenIdent && tok.text == "not":
+		next := p.next()
+		operator = "not " + next.text
+	case tok.kind == tokenIdent && tok.text == "is":
+		next := p.next()
+		if next.text == "not" {
+			next = p.next()
+			operator = "is not " + next.text
+		} else {
+			operator = "is " + next.text
+		}
+	}
+	conditionOperator, ok := expressionOperators[operator]
+	if !ok || (tok.kind != tokenOperator && tok.kind != tokenIdent) {
+		return p.errorAt(tok, "expected an operator, found %s", p.describe(tok))
+	}
+	condition.Operator = conditionOperator
+	if conditionOperator == "null" || conditionOperator == "notnull" {
+		return nil
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	condition.Value = value
+	return nil
+}
+
+// parseValue parses a number, string, null or parenthesised list of them.
+func (p *expressionParser) parseValue() (interface{}, error) {
+	tok := p.peek()
+	if tok.text == "(" && tok.kind == tokenPunct {
+		p.next()
+		values := []interface{}{}
+		if p.peek().text == ")" {
+			p.next()
+			return values, nil
+		}
+		for {
+			value, err := p.parseScalar()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			tok := p.next()
+			if tok.text == ")" {
+				return values, nil
+			}
+			if tok.text != "," {
+				return nil, p.errorAt(tok, "expected ',' or ')' in list, found %s", p.describe(tok))
+			}
+		}
+	}
+	return p.parseScalar()
+}
+
+// parseScalar parses a number, string or null.
+func (p *expressionParser) parseScalar() (interface{}, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenNumber, tok.kind == tokenString:
+		return tok.text, nil
+	case tok.kind == tokenIdent && tok.text == "null":
+		return nil, nil
+	default:
+		return nil, p.errorAt(tok, "expected a value, found %s", p.describe(tok))
+	}
+}
+
+// parseList parses "(condition, ...)".
+func (p *expressionParser) parseList(parseChild func() (Condition, error)) ([]Condition, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	conditions := []Condition{}
+	if p.peek().text == ")" {
+		p.next()
+		return conditions, nil
+	}
+	for {
+		condition, err := parseChild()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		tok := p.next()
+		if tok.text == ")" && tok.kind == tokenPunct {
+			return conditions, nil
+		}
+		if tok.text != "," || tok.kind != tokenPunct {
+			return nil, p.errorAt(tok, "expected ',' or ')', found %s", p.describe(tok))
+		}
+	}
+}
+
+// expect consumes a token with the given text.
+func (p *expressionParser) expect(text string) (token, error) {
+	tok := p.next()
+	if tok.text != text || tok.kind == tokenString {
+		return tok, p.errorAt(tok, "expected '%s', found %s", text, p.describe(tok))
+	}
+	return tok, nil
+}
+
+func (p *expressionParser) peek() token {
+	return p.peekAt(0)
+}
+
+func (p *expressionParser) peekAt(n int) token {
+	if p.pos+n >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+n]
+}
+
+func (p *expressionParser) next() token {
+	tok := p.peek()
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return tok
+}
+
+// describe names a token in error messages.
+func (p *expressionParser) describe(tok token) string {
+	switch tok.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(tok.text)
+	default:
+		return "'" + tok.text + "'"
+	}
+}
+
+func (p *expressionParser) errorAt(tok token, format string, args ...interface{}) *SyntaxError {
+	return p.errorAtOffset(tok.offset, format, args...)
+}
+
+// errorAtOffset builds a SyntaxError, translating the offset to a line and column.
+func (p *expressionParser) errorAtOffset(offset int, format string, args ...interface{}) *SyntaxError {
+	line, column := 1, 1
+	for _, c := range p.src[:offset] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &SyntaxError{Offset: offset, Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// FormatExpression prints a condition tree in the rule expression language.
+// Parsing the result gives back an equivalent tree.
+func FormatExpression(condition Condition) (string, error) {
+	var sb strings.Builder
+	if err := formatExpression(&sb, condition, condition.Type == TypeProduct || condition.Type == TypeProductCombine); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// formatExpression writes a condition in cart or item context.
+func formatExpression(sb *strings.Builder, condition Condition, item bool) error {
+	aggregator := condition.Aggregator
+	if aggregator == "" {
+		aggregator = "all"
+	}
+	if aggregator != "all" && aggregator != "any" {
+		return fmt.Errorf("unknown aggregator: %s", condition.Aggregator)
+	}
+
+	switch condition.Type {
+	case TypeCombine, TypeProductCombine:
+		sb.WriteString(aggregator)
+		if !isTrueValue(condition.Value) {
+			sb.WriteString(" false")
+		}
+		return formatExpressionList(sb, condition.Conditions, condition.Type == TypeProductCombine)
+	case TypeProductFound:
+		if !isTrueValue(condition.Value) {
+			sb.WriteString("not ")
+		}
+		sb.WriteString("found ")
+		if aggregator == "any" {
+			sb.WriteString("any ")
+		}
+		sb.WriteString("item")
+		return formatExpressionList(sb, condition.Conditions, true)
+	case TypeSubselect:
+		if !isExpressionIdent(condition.Attribute) {
+			return fmt.Errorf("attribute %q cannot be written as an expression", condition.Attribute)
+		}
+		sb.WriteString("total " + condition.Attribute + " of ")
+		if aggregator == "any" {
+			sb.WriteString("any ")
+		}
+		sb.WriteString("items")
+		if err := formatExpressionList(sb, condition.Conditions, true); err != nil {
+			return err
+		}
+		return formatExpressionComparison(sb, condition)
+	case TypeProduct:
+		// Inside items, paths starting with an entity name would be read back as that entity
+		first, _, dotted := strings.Cut(condition.Attribute, ".")
+		if !item || (dotted && (cartEntities[first] || first == "product")) {
+			sb.WriteString("product.")
+		}
+	case TypeCustomer:
+		sb.WriteString("customer.")
+	case TypeAddress:
+		// Keywords and dotted names would be read back as something else
+		billing, _ := splitAddressAttribute(condition.Attribute)
+		prefix := shippingAddressPrefix
+		if billing {
+			prefix = billingAddressPrefix
+		}
+		if strings.HasPrefix(condition.Attribute, prefix) && condition.Attribute != prefix {
+			break
+		}
+		if strings.Contains(condition.Attribute, ".") || expressionKeywords[condition.Attribute] {
+			sb.WriteString("address.")
+		}
+	case TypeSegment:
+		segment := Condition{Operator: condition.Operator, Value: condition.Value}
+		if segment.Operator == "" {
+			segment.Operator = "=="
+		}
+		if !segmentOperators[segment.Operator] {
+			return fmt.Errorf("operator %s cannot be used on customer segments", condition.Operator)
+		}
+		sb.WriteString("segment")
+		return formatExpressionComparison(sb, segment)
+	default:
+		return fmt.Errorf("condition type %s cannot be written as an expression", condition.Type)
+	}
+
+	if !isExpressionAttribute(condition.Attribute) {
+		return fmt.Errorf("attribute %q cannot be written as an expression", condition.Attribute)
+	}
+	sb.WriteString(condition.Attribute)
+	return formatExpressionComparison(sb, condition)
+}
+
+// formatExpressionList writes "(condition, ...)".
+func formatExpressionList(sb *strings.Builder, conditions []Condition, item bool) error {
+	sb.WriteString("(")
+	for i, condition := range conditions {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if err := formatExpression(sb, condition, item); err != nil {
+			return err
+		}
+	}
+	sb.WriteString(")")
+	return nil
+}
+
+// formatExpressionComparison writes " <op> <value>".
+func formatExpressionComparison(sb *strings.Builder, condition Condition) error {
+	operator, ok := conditionOperators[condition.Operator]
+	if !ok {
+		return fmt.Errorf("unknown operator: %s", condition.Operator)
+	}
+	sb.WriteString(" " + operator)
+	if condition.Operator == "null" || condition.Operator == "notnull" {
+		return nil
+	}
+	sb.WriteString(" ")
+
+	if values, ok := condition.Value.([]interface{}); ok {
+		sb.WriteString("(")
+		for i, value := range values {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			if err := formatExpressionValue(sb, value); err != nil {
+				return err
+			}
+		}
+		sb.WriteString(")")
+		return nil
+	}
+	return formatExpressionValue(sb, condition.Value)
+}
+
+// formatExpressionValue writes a scalar value, leaving numbers unquoted.
+func formatExpressionValue(sb *strings.Builder, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		sb.WriteString("null")
+	case string:
+		if isExpressionNumber(v) {
+			sb.WriteString(v)
+		} else {
+			sb.WriteString(strconv.Quote(v))
+		}
+	case float64:
+		sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		if v {
+			sb.WriteString("1")
+		} else {
+			sb.WriteString("0")
+		}
+	default:
+		return fmt.Errorf("value %v cannot be written as an expression", value)
+	}
+	return nil
+}
+
+// isExpressionIdent reports whether s is a valid identifier in the expression language.
+func isExpressionIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentByte(s[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// isExpressionAttribute reports whether an attribute code or path can be
+// written in the expression language: identifiers separated by dots, each
+// optionally followed by a list selector.
+func isExpressionAttribute(attribute string) bool {
+	for _, part := range strings.Split(attribute, ".") {
+		name, selector, indexed := strings.Cut(part, "[")
+		if !isExpressionIdent(name) {
+			return false
+		}
+		if indexed && (!strings.HasSuffix(selector, "]") || !isExpressionSelector(strings.TrimSuffix(selector, "]"))) {
+			return false
+		}
+	}
+	if isAttributePath(attribute) {
+		_, err := parseAttributePath(attribute)
+		return err == nil
+	}
+	return true
+}
+
+// isExpressionSelector reports whether s can select list elements in an
+// attribute path: an index, * or an identifier such as any or all.
+func isExpressionSelector(s string) bool {
+	if s == "*" || isExpressionIdent(s) {
+		return true
+	}
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigitByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentByte reports whether b may appear in an identifier, or start one.
+func isIdentByte(b byte, first bool) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (!first && isDigitByte(b))
+}
+
+func isDigitByte(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// isExpressionNumber reports whether s is written as a number literal in the expression language.
+func isExpressionNumber(s string) bool {
+	digits := strings.TrimPrefix(s, "-")
+	if digits == "" || !isDigitByte(digits[0]) || !isDigitByte(digits[len(digits)-1]) {
+		return false
+	}
+	for i := 0; i < len(digits); i++ {
+		if !isDigitByte(digits[i]) && digits[i] != '.' {
+			return false
+		}
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
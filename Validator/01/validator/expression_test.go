@@ -0,0 +1,176 @@
+package validator
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseExpression(t *testing.T) {
+	got, err := ParseExpression(`all(subtotal >= 100, customer.group_id in (1,2), found item(sku == "1012096"))`)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	want := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "subtotal", Operator: ">=", Value: "100"},
+		{Type: TypeCustomer, Attribute: "group_id", Operator: "()", Value: []interface{}{"1", "2"}},
+		{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "1012096"},
+		}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExpression() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFormatExpressionRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{
+			name:       "Nested combine, found and subselect",
+			expression: `any false(not found any item(sku like "SHIRT-%", all(price > 9.5, name not contains "gift")), total qty of items(category_ids in (3, 4)) >= 2)`,
+		},
+		{
+			name:       "Cart-level product and null checks",
+			expression: `all(product.sku != "A\"B", customer.email is not null, address.total == -1, coupon_code is null)`,
+		},
+		{
+			name:       "Empty combine",
+			expression: `all()`,
+		},
+		{
+			name:       "Attribute paths",
+			expression: `all(found item(attributes.color == "red", children[0].sku == "A"), customer.addresses[*].country == "US", customer.addresses[all].city != "Paris", product.attributes.size in ("S", "M"))`,
+		},
+		{
+			name:       "Billing and shipping addresses",
+			expression: `any(billing_address.country_id == "DE", shipping_address.postcode like "10%", billing_address.street[1] is null, address.segment == 1)`,
+		},
+		{
+			name:       "Segments",
+			expression: `all(segment in ("vip", "loyal"), segment != "lapsed")`,
+		},
+		{
+			name:       "Item paths named like entities",
+			expression: `found item(product.customer.name == "x", product.product.sku == "y")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, err := ParseExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseExpression() error = %v", err)
+			}
+			got, err := FormatExpression(condition)
+			if err != nil {
+				t.Fatalf("FormatExpression() error = %v", err)
+			}
+			if got != tt.expression {
+				t.Errorf("FormatExpression() = %s, want %s", got, tt.expression)
+			}
+		})
+	}
+
+	t.Run("Magento JSON", func(t *testing.T) {
+		var condition Condition
+		err := json.Unmarshal([]byte(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+			"value": "1",
+			"aggregator": "all",
+			"conditions": [
+				{
+					"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect",
+					"attribute": "qty",
+					"operator": "==",
+					"value": "1",
+					"aggregator": "all",
+					"conditions": [
+						{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "1012096"}
+					]
+				},
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "country_id", "operator": "==", "value": "US"}
+			]
+		}`), &condition)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal condition: %v", err)
+		}
+		expression, err := FormatExpression(condition)
+		if err != nil {
+			t.Fatalf("FormatExpression() error = %v", err)
+		}
+		if want := `all(total qty of items(sku == 1012096) == 1, country_id == "US")`; expression != want {
+			t.Errorf("FormatExpression() = %s, want %s", expression, want)
+		}
+		parsed, err := ParseExpression(expression)
+		if err != nil {
+			t.Fatalf("ParseExpression() error = %v", err)
+		}
+		if !reflect.DeepEqual(parsed, condition) {
+			t.Errorf("ParseExpression() = %+v, want %+v", parsed, condition)
+		}
+	})
+}
+
+func TestParseExpressionPaths(t *testing.T) {
+	got, err := ParseExpression(`all(found item(attributes.color == "red"), billing_address.country_id == "DE", segment in ("vip"))`)
+	if err != nil {
+		t.Fatalf("ParseExpression() error = %v", err)
+	}
+	want := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "attributes.color", Operator: "==", Value: "red"},
+		}},
+		{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"},
+		{Type: TypeSegment, Operator: "()", Value: []interface{}{"vip"}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseExpression() = %+v, want %+v", got, want)
+	}
+
+	// Segments without an operator default to ==
+	expression, err := FormatExpression(Condition{Type: TypeSegment, Value: "vip"})
+	if err != nil || expression != `segment == "vip"` {
+		t.Errorf("FormatExpression() = %s, %v, want segment == \"vip\"", expression, err)
+	}
+	if _, err := FormatExpression(Condition{Type: TypeCustomer, Attribute: "addresses[x].city", Operator: "==", Value: "a"}); err == nil {
+		t.Error("FormatExpression() of an invalid path succeeded")
+	}
+}
+
+func TestParseExpressionSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		line       int
+		column     int
+	}{
+		{name: "Missing closing parenthesis", expression: `all(subtotal >= 100`, line: 1, column: 20},
+		{name: "Missing value", expression: "any(\n  sku == )", line: 2, column: 10},
+		{name: "Unknown entity", expression: `all(order.id == 1)`, line: 1, column: 5},
+		{name: "Unterminated string", expression: `sku == "abc`, line: 1, column: 8},
+		{name: "Bad operator", expression: `all(sku = "a")`, line: 1, column: 9},
+		{name: "Found needs item", expression: `found items(sku == "a")`, line: 1, column: 7},
+		{name: "Trailing input", expression: `all() any()`, line: 1, column: 7},
+		{name: "Bad list selector", expression: `customer.addresses[some].city == "x"`, line: 1, column: 10},
+		{name: "Unclosed list selector", expression: `customer.addresses[0.city == "x"`, line: 1, column: 20},
+		{name: "Segment operator", expression: `segment like "vip%"`, line: 1, column: 9},
+		{name: "Cart entity in item", expression: `found item(customer.group_id == 1)`, line: 1, column: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExpression(tt.expression)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("ParseExpression() error = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.line || syntaxErr.Column != tt.column {
+				t.Errorf("ParseExpression() error at %d:%d (%v), want %d:%d", syntaxErr.Line, syntaxErr.Column, err, tt.line, tt.column)
+			}
+		})
+	}
+}
@@ -0,0 +1,577 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Numeric attributes stored as integers
+var integerAttributes = map[string]bool{
+	"quantity": true, "qty": true, "quote_item_qty": true, "total_qty": true,
+	"region_id": true, "id": true, "group_id": true, "orders_count": true,
+}
+
+// CartFixture is a generated cart and the result the condition gives for it.
+type CartFixture struct {
+	Name string // The condition the cart was built to make true or false, e.g. "$.conditions[1] is false"
+	Cart Cart
+	Want bool // Result of the whole condition tree on the cart
+}
+
+// CartGenerator builds test carts from a condition tree, so rule configs can be
+// unit-tested like the validator itself. Comparisons are set to their boundary
+// values: a cart failing "Subtotal >= 100" has a subtotal of 99.99.
+type CartGenerator struct {
+	cv *ConditionValidator
+}
+
+// NewCartGenerator creates a new CartGenerator.
+func NewCartGenerator() *CartGenerator {
+	return &CartGenerator{cv: NewConditionValidator()}
+}
+
+// Generate returns a cart satisfying the condition, a cart narrowly failing it,
+// and for every subcondition a cart where it is true and one where it is false
+// while the rest of the tree is satisfied where possible. Combine branches are
+// flipped one at a time. Duplicate carts and subconditions that cannot be
+// forced, such as a pattern that matches everything, are left out.
+func (g *CartGenerator) Generate(condition Condition) ([]CartFixture, error) {
+	if err := g.cv.CheckLimits(condition); err != nil {
+		return nil, err
+	}
+
+	var fixtures []CartFixture
+	var add func(path string, node Condition) error
+	add = func(path string, node Condition) error {
+		for _, value := range []bool{true, false} {
+			cart, ok := g.solve(condition, map[string]bool{path: value})
+			if !ok || g.contains(fixtures, cart) {
+				continue
+			}
+			want, err := g.cv.Validate(condition, cart)
+			if err != nil {
+				return fmt.Errorf("generated cart for %s: %v", path, err)
+			}
+			fixtures = append(fixtures, CartFixture{Name: fmt.Sprintf("%s is %v", path, value), Cart: cart, Want: want})
+		}
+		for i, subCondition := range node.Conditions {
+			if err := add(ChildPath(path, i), subCondition); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := add(RootPath, condition); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+func (g *CartGenerator) contains(fixtures []CartFixture, cart Cart) bool {
+	for _, fixture := range fixtures {
+		if reflect.DeepEqual(fixture.Cart, cart) {
+			return true
+		}
+	}
+	return false
+}
+
+// solve builds a cart satisfying the condition, with the conditions at the
+// overridden paths forced to the given results.
+func (g *CartGenerator) solve(condition Condition, overrides map[string]bool) (Cart, bool) {
+	s := &cartSolver{cv: g.cv, overrides: overrides, ok: true}
+	var cart Cart
+	s.cart(&cart, RootPath, condition, true)
+	if !s.subtotalSet {
+		for _, item := range cart.Items {
+			cart.Subtotal += item.Price * float64(item.Quantity)
+		}
+	}
+	cart.GrandTotal = cart.Subtotal
+	return cart, s.ok
+}
+
+// cartSolver makes conditions true or false by editing a cart. Siblings are
+// solved in order, so a later edit may undo an earlier one; Generate reports
+// the actual result of each cart.
+type cartSolver struct {
+	cv          *ConditionValidator
+	overrides   map[string]bool
+	items       int
+	subtotalSet bool
+	ok          bool
+}
+
+// want returns the result the condition at path must have.
+func (s *cartSolver) want(path string, want bool) bool {
+	if value, ok := s.overrides[path]; ok {
+		return value
+	}
+	return want
+}
+
+// cart makes a cart-level condition evaluate to want.
+func (s *cartSolver) cart(cart *Cart, path string, condition Condition, want bool) {
+	want = s.want(path, want)
+	switch condition.Type {
+	case TypeCombine:
+		s.aggregate(path, condition, isTrueValue(condition.Value), want, func(childPath string, child Condition, childWant bool) {
+			s.cart(cart, childPath, child, childWant)
+		})
+	case TypeProductFound:
+		match := func(item *Item, matchWant bool) {
+			s.aggregate(path, condition, true, matchWant, func(childPath string, child Condition, childWant bool) {
+				s.item(item, childPath, child, childWant)
+			})
+		}
+		matches := func(item Item) bool {
+			valid, err := s.cv.validateItemConditions(context.Background(), path, condition, item)
+			return err == nil && valid
+		}
+		s.found(cart, match, matches, want == isTrueValue(condition.Value))
+	case TypeProduct:
+		match := func(item *Item, matchWant bool) {
+			s.itemAttribute(item, condition, matchWant)
+		}
+		matches := func(item Item) bool {
+			valid, err := s.cv.validateProductItem(condition, item)
+			return err == nil && valid
+		}
+		s.found(cart, match, matches, want)
+	case TypeSubselect:
+		s.subselect(cart, path, condition, want)
+	case TypeAddress:
+		value, ok := s.value(TypeAddress, condition, want)
+		s.ok = s.ok && ok && s.setAddress(cart, condition.Attribute, value)
+	case TypeCustomer:
+		value, ok := s.value(TypeCustomer, condition, want)
+		s.ok = s.ok && ok && s.setCustomer(&cart.Customer, condition.Attribute, value)
+	default:
+		s.ok = false
+	}
+}
+
+// item makes an item-level condition evaluate to want for the item.
+func (s *cartSolver) item(item *Item, path string, condition Condition, want bool) {
+	want = s.want(path, want)
+	switch condition.Type {
+	case TypeProductCombine:
+		s.aggregate(path, condition, isTrueValue(condition.Value), want, func(childPath string, child Condition, childWant bool) {
+			s.item(item, childPath, child, childWant)
+		})
+	case TypeProduct:
+		s.itemAttribute(item, condition, want)
+	default:
+		s.ok = false
+	}
+}
+
+// itemAttribute makes a product condition evaluate to want for the item.
+func (s *cartSolver) itemAttribute(item *Item, condition Condition, want bool) {
+	value, ok := s.value(TypeProduct, condition, want)
+	s.ok = s.ok && ok && s.setItem(item, condition.Attribute, value)
+}
+
+// aggregate decides the result of each subcondition of a Combine-style
+// condition. A narrowly failing "all" has only its first subcondition wrong and
+// a narrowly passing "any" only its first subcondition right.
+func (s *cartSolver) aggregate(path string, condition Condition, expected, want bool, solve func(string, Condition, bool)) {
+	if len(condition.Conditions) == 0 {
+		// Empty combines are always true
+		s.ok = s.ok && want
+		return
+	}
+	var first, rest bool
+	switch condition.Aggregator {
+	case "all", "":
+		first, rest = expected, expected
+		if !want {
+			first = !expected
+		}
+	case "any":
+		first, rest = expected, !expected
+		if !want {
+			first = !expected
+		}
+	default:
+		s.ok = false
+		return
+	}
+	for i, subCondition := range condition.Conditions {
+		childWant := rest
+		if i == 0 {
+			childWant = first
+		}
+		solve(ChildPath(path, i), subCondition, childWant)
+	}
+}
+
+// found adds an item for which match holds, or makes match fail for every item.
+func (s *cartSolver) found(cart *Cart, match func(*Item, bool), matches func(Item) bool, exists bool) {
+	if exists {
+		item := s.newItem()
+		match(&item, true)
+		cart.Items = append(cart.Items, item)
+		return
+	}
+	for i := range cart.Items {
+		if matches(cart.Items[i]) {
+			match(&cart.Items[i], false)
+		}
+	}
+}
+
+// subselect sets the total of the matching items to the boundary value.
+func (s *cartSolver) subselect(cart *Cart, path string, condition Condition, want bool) {
+	attribute := strings.ToLower(condition.Attribute)
+	if attribute != "qty" && attribute != "quantity" && attribute != "base_row_total" && attribute != "row_total" {
+		s.ok = false
+		return
+	}
+	match := func(item *Item, matchWant bool) {
+		s.aggregate(path, condition, true, matchWant, func(childPath string, child Condition, childWant bool) {
+			s.item(item, childPath, child, childWant)
+		})
+	}
+	matches := func(item Item) bool {
+		valid, err := s.cv.validateItemConditions(context.Background(), path, condition, item)
+		return err == nil && valid
+	}
+	total := func() float64 {
+		var total float64
+		for _, item := range cart.Items {
+			if matches(item) {
+				value, _ := s.cv.itemNumericAttribute(item, attribute)
+				total += value
+			}
+		}
+		return total
+	}
+
+	numbers, ok := s.numbers(condition.Value)
+	if !ok {
+		s.ok = false
+		return
+	}
+	target, ok := s.pick(s.numberCandidates(numbers, 0.01, true, false), condition, want)
+	if !ok {
+		s.ok = false
+		return
+	}
+	targetTotal := target.(float64)
+	if targetTotal < total() {
+		s.found(cart, match, matches, false)
+	}
+	missing := targetTotal - total()
+	if missing <= 0 {
+		return
+	}
+	item := s.newItem()
+	match(&item, true)
+	if attribute == "qty" || attribute == "quantity" {
+		if missing != math.Trunc(missing) {
+			s.ok = false
+			return
+		}
+		item.Quantity = int(missing)
+	} else {
+		item.Price = missing / float64(item.Quantity)
+		item.FinalPrice = item.Price
+	}
+	cart.Items = append(cart.Items, item)
+}
+
+// newItem returns a simple item with a generated SKU.
+func (s *cartSolver) newItem() Item {
+	s.items++
+	return Item{
+		SKU:         fmt.Sprintf("GENERATED-%d", s.items),
+		Name:        fmt.Sprintf("Generated item %d", s.items),
+		ProductType: ProductTypeSimple,
+		Quantity:    1,
+		Price:       10,
+		FinalPrice:  10,
+	}
+}
+
+// value returns the attribute value closest to the condition's boundary for
+// which the comparison gives want.
+func (s *cartSolver) value(conditionType string, condition Condition, want bool) (interface{}, bool) {
+	attribute := strings.ToLower(condition.Attribute)
+	if conditionType == TypeAddress {
+		_, attribute = splitAddressAttribute(attribute)
+	}
+	kind, known := LookupAttribute(conditionType, attribute)
+	integer := integerAttributes[attribute]
+	step := 0.01
+	if integer {
+		step = 1
+	}
+
+	var candidates []interface{}
+	switch {
+	case kind == KindNumber || !known:
+		if numbers, ok := s.numbers(condition.Value); ok {
+			candidates = s.numberCandidates(numbers, step, nonNegativeAttributes[attribute], integer)
+		}
+		if !known {
+			// Custom attributes may hold numbers or strings
+			candidates = append(candidates, s.stringCandidates(condition.Value)...)
+		}
+	case kind == KindBool:
+		candidates = []interface{}{true, false}
+	case kind == KindString:
+		candidates = s.stringCandidates(condition.Value)
+	case kind == KindDate:
+		for _, value := range s.values(condition.Value) {
+			if t, err := s.cv.toTime(value); err == nil {
+				candidates = append(candidates, t, t.Add(-24*time.Hour), t.Add(24*time.Hour))
+			}
+		}
+		candidates = append(candidates, time.Time{})
+	case kind == KindIDs:
+		maxID := 0
+		for _, value := range s.values(condition.Value) {
+			if id, err := strconv.Atoi(fmt.Sprint(value)); err == nil {
+				candidates = append(candidates, []int{id})
+				if id > maxID {
+					maxID = id
+				}
+			}
+		}
+		candidates = append(candidates, []int{maxID + 1}, []int(nil))
+	case kind == KindList:
+		candidates = []interface{}{[]string{"Generated street"}, []string(nil)}
+	}
+	return s.pick(candidates, condition, want)
+}
+
+// pick returns the first candidate for which the comparison gives want.
+func (s *cartSolver) pick(candidates []interface{}, condition Condition, want bool) (interface{}, bool) {
+	for _, candidate := range candidates {
+		if valid, err := s.cv.compareValues(candidate, condition.Operator, condition.Value); err == nil && valid == want {
+			return candidate, true
+		}
+	}
+	return nil, false
+}
+
+// values returns the compared values of a condition.
+func (s *cartSolver) values(value interface{}) []interface{} {
+	if values, ok := value.([]interface{}); ok {
+		return values
+	}
+	return []interface{}{value}
+}
+
+// numbers returns the compared values as numbers.
+func (s *cartSolver) numbers(value interface{}) ([]float64, bool) {
+	var numbers []float64
+	for _, v := range s.values(value) {
+		number, err := s.cv.toFloat64(v)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, true
+}
+
+// numberCandidates returns each value and its neighbours one step away, then zero.
+func (s *cartSolver) numberCandidates(numbers []float64, step float64, nonNegative, integer bool) []interface{} {
+	var candidates []interface{}
+	maxNumber := 0.0
+	for _, number := range numbers {
+		for _, candidate := range []float64{number, number - step, number + step} {
+			scale := math.Round(1 / step)
+			candidate = math.Round(candidate*scale) / scale
+			if nonNegative && candidate < 0 {
+				continue
+			}
+			if integer {
+				candidates = append(candidates, int(candidate))
+			} else {
+				candidates = append(candidates, candidate)
+			}
+		}
+		maxNumber = math.Max(maxNumber, number)
+	}
+	if integer {
+		return append(candidates, 0, int(maxNumber)+1)
+	}
+	return append(candidates, 0.0, maxNumber+step)
+}
+
+// stringCandidates returns each value, the value with its wildcards removed,
+// values that differ from it, and the empty string.
+func (s *cartSolver) stringCandidates(value interface{}) []interface{} {
+	var candidates []interface{}
+	for _, v := range s.values(value) {
+		text := fmt.Sprint(v)
+		candidates = append(candidates, text, strings.ReplaceAll(text, "%", ""), "not-"+text)
+	}
+	return append(candidates, "", "~")
+}
+
+// setItem sets an item attribute to a value returned by value.
+func (s *cartSolver) setItem(item *Item, attribute string, value interface{}) bool {
+	switch strings.ToLower(attribute) {
+	case "sku":
+		item.SKU = value.(string)
+	case "name":
+		item.Name = value.(string)
+	case "product_type":
+		item.ProductType = value.(string)
+	case "price", "quote_item_price":
+		item.Price = value.(float64)
+	case "final_price":
+		item.FinalPrice = value.(float64)
+	case "weight":
+		item.Weight = value.(float64)
+	case "quantity", "qty", "quote_item_qty":
+		item.Quantity = value.(int)
+	case "row_total", "base_row_total", "quote_item_row_total":
+		if item.Quantity == 0 {
+			return false
+		}
+		item.Price = value.(float64) / float64(item.Quantity)
+	case "category_ids":
+		item.CategoryIDs = value.([]int)
+	case "created_at":
+		item.CreatedAt = value.(time.Time)
+	case "updated_at":
+		item.UpdatedAt = value.(time.Time)
+	default:
+		key, ok := customAttributeKey(trimEntityPrefix(attribute, "item", "product"))
+		if !ok {
+			return false
+		}
+		if item.Attributes == nil {
+			item.Attributes = map[string]interface{}{}
+		}
+		item.Attributes[key] = value
+	}
+	return true
+}
+
+// setAddress sets an attribute of the shipping address or the totals shipped to it.
+func (s *cartSolver) setAddress(cart *Cart, attribute string, value interface{}) bool {
+	address := &cart.ShippingAddress
+	billing, attribute := splitAddressAttribute(attribute)
+	if billing {
+		address = &cart.BillingAddress
+	}
+	switch strings.ToLower(attribute) {
+	case "base_subtotal", "subtotal":
+		cart.Subtotal = value.(float64)
+		s.subtotalSet = true
+	case "total_qty":
+		var total int
+		for _, item := range cart.Items {
+			total += item.Quantity
+		}
+		if value.(int) < total {
+			return false
+		}
+		if value.(int) > total {
+			item := s.newItem()
+			item.Quantity = value.(int) - total
+			cart.Items = append(cart.Items, item)
+		}
+	case "weight":
+		var total float64
+		for _, item := range cart.Items {
+			total += item.Weight * float64(item.Quantity)
+		}
+		if value.(float64) < total {
+			return false
+		}
+		if value.(float64) > total {
+			item := s.newItem()
+			item.Weight = value.(float64) - total
+			cart.Items = append(cart.Items, item)
+		}
+	case "country", "country_id":
+		address.Country = value.(string)
+	case "region":
+		address.Region = value.(string)
+	case "region_id":
+		address.RegionID = value.(int)
+	case "city":
+		address.City = value.(string)
+	case "postcode":
+		address.PostalCode = value.(string)
+	case "street":
+		address.Street = value.([]string)
+	case "telephone":
+		address.Telephone = value.(string)
+	case "company":
+		address.Company = value.(string)
+	case "firstname":
+		address.FirstName = value.(string)
+	case "lastname":
+		address.LastName = value.(string)
+	case "email":
+		address.Email = value.(string)
+	default:
+		return false
+	}
+	return true
+}
+
+// setCustomer sets a customer attribute.
+func (s *cartSolver) setCustomer(customer *Customer, attribute string, value interface{}) bool {
+	switch strings.ToLower(attribute) {
+	case "id":
+		customer.ID = value.(int)
+	case "group_id":
+		customer.GroupID = value.(int)
+	case "email":
+		customer.Email = value.(string)
+	case "firstname":
+		customer.FirstName = value.(string)
+	case "lastname":
+		customer.LastName = value.(string)
+	case "gender":
+		customer.Gender = value.(string)
+	case "dob":
+		customer.DateOfBirth = value.(time.Time)
+	case "created_at":
+		customer.CreatedAt = value.(time.Time)
+	case "last_login_at":
+		customer.LastLoginAt = value.(time.Time)
+	case "orders_count":
+		customer.Orders = value.(int)
+	case "total_spent":
+		customer.TotalSpent = value.(float64)
+	case "average_order_amount":
+		customer.AverageOrderAmount = value.(float64)
+	case "is_subscribed":
+		customer.IsSubscribed = value.(bool)
+	default:
+		key, ok := customAttributeKey(trimEntityPrefix(attribute, "customer"))
+		if !ok {
+			return false
+		}
+		if customer.Attributes == nil {
+			customer.Attributes = map[string]interface{}{}
+		}
+		customer.Attributes[key] = value
+	}
+	return true
+}
+
+// customAttributeKey returns the key of a custom attribute, given as a plain
+// code or as attributes.<code>. Other paths cannot be set.
+func customAttributeKey(attribute string) (string, bool) {
+	if !isAttributePath(attribute) {
+		return attribute, true
+	}
+	key := trimEntityPrefix(attribute, "attributes")
+	return key, key != attribute && !isAttributePath(key)
+}
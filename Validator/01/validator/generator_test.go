@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCartGenerator(t *testing.T) {
+	var condition Condition
+	err := json.Unmarshal([]byte(`{
+		"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+		"value": "1",
+		"aggregator": "all",
+		"conditions": [
+			{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "100"},
+			{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "1012096"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "qty", "operator": ">", "value": "1"}
+				]
+			},
+			{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"value": "0",
+				"aggregator": "any",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "group_id", "operator": "()", "value": ["0", "4"]},
+					{
+						"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect",
+						"attribute": "base_row_total",
+						"operator": ">=",
+						"value": "500",
+						"aggregator": "all",
+						"conditions": [
+							{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "category_ids", "operator": "()", "value": ["7"]}
+						]
+					}
+				]
+			}
+		]
+	}`), &condition)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal condition: %v", err)
+	}
+
+	fixtures, err := NewCartGenerator().Generate(condition)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	cv := NewConditionValidator()
+	got := map[string]CartFixture{}
+	for _, fixture := range fixtures {
+		valid, err := cv.Validate(condition, fixture.Cart)
+		if err != nil || valid != fixture.Want {
+			t.Errorf("%s: Validate() = %v, %v, want %v", fixture.Name, valid, err, fixture.Want)
+		}
+		got[fixture.Name] = fixture
+	}
+
+	tests := []struct {
+		name  string
+		want  bool
+		check func(cart Cart) bool
+	}{
+		{"$ is true", true, func(cart Cart) bool { return cart.Subtotal == 100 }},
+		{"$ is false", false, func(cart Cart) bool { return cart.Subtotal == 99.99 }},
+		{"$.conditions[1] is false", false, func(cart Cart) bool { return len(cart.Items) == 1 }},
+		{"$.conditions[1].conditions[0] is false", false, func(cart Cart) bool { return cart.Items[0].SKU != "1012096" }},
+		{"$.conditions[1].conditions[1] is false", false, func(cart Cart) bool { return cart.Items[0].Quantity == 1 }},
+		{"$.conditions[2] is false", false, func(cart Cart) bool { return cart.Customer.GroupID == 0 }},
+		{"$.conditions[2].conditions[1] is false", true, func(cart Cart) bool { return cart.Items[1].Price == 499.99 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixture, ok := got[tt.name]
+			if !ok {
+				t.Fatalf("fixture %q not generated", tt.name)
+			}
+			if fixture.Want != tt.want {
+				t.Errorf("Want = %v, want %v", fixture.Want, tt.want)
+			}
+			if !tt.check(fixture.Cart) {
+				t.Errorf("unexpected cart %+v", fixture.Cart)
+			}
+		})
+	}
+}
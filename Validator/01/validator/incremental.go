@@ -0,0 +1,396 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ChangeKind identifies the kind of a CartChange.
+type ChangeKind int
+
+const (
+	ItemAdded ChangeKind = iota
+	ItemRemoved
+	ItemQuantityChanged
+	ShippingAddressChanged
+	CustomerChanged
+	BillingAddressChanged
+)
+
+// CartChange is a single change to the cart held by an IncrementalEvaluator.
+// Index identifies the item for ItemRemoved and ItemQuantityChanged.
+type CartChange struct {
+	Kind     ChangeKind
+	Index    int
+	Item     Item     // ItemAdded
+	Quantity int      // ItemQuantityChanged
+	Address  Address  // ShippingAddressChanged and BillingAddressChanged
+	Customer Customer // CustomerChanged
+}
+
+// RuleFlip reports a rule whose outcome changed with a cart change.
+type RuleFlip struct {
+	RuleIndex int // Position of the rule in the evaluated rule set
+	RuleID    int
+	Matched   bool  // The new outcome
+	Err       error // Set when the rule can no longer be evaluated, which counts as not matching
+}
+
+// dependency is a set of the parts of the cart a condition node reads.
+type dependency int
+
+const (
+	dependsOnItems dependency = 1 << iota
+	dependsOnAddress
+	dependsOnCustomer
+	dependsOnBillingAddress
+)
+
+// dependencies returns the parts of the cart a change touches.
+func (c CartChange) dependencies() dependency {
+	switch c.Kind {
+	case ItemAdded, ItemRemoved, ItemQuantityChanged:
+		return dependsOnItems
+	case ShippingAddressChanged:
+		return dependsOnAddress
+	case BillingAddressChanged:
+		return dependsOnBillingAddress
+	default:
+		return dependsOnCustomer
+	}
+}
+
+// incrementalNode caches the outcome of a cart-level condition. Nodes that
+// read the items also cache their result for each item, aligned with the
+// cart items, so an item change only re-evaluates that item.
+type incrementalNode struct {
+	path      string
+	condition Condition
+	deps      dependency
+	children  []*incrementalNode
+	matches   []bool    // Product and Found: whether each item matches
+	totals    []float64 // Subselect: what each item adds to the total
+	value     bool
+}
+
+// incrementalRule is the cached evaluation of one rule.
+type incrementalRule struct {
+	root    *incrementalNode
+	applies bool
+	matched bool
+	err     error
+}
+
+// IncrementalEvaluator keeps the outcome of a rule set for a cart that changes
+// one step at a time, such as a cart being filled on a storefront. It records
+// which parts of the cart each condition node depends on and re-evaluates only
+// the nodes a change affects. It is not safe for concurrent use.
+type IncrementalEvaluator struct {
+	cv    *ConditionValidator
+	rules []Rule
+	state []incrementalRule
+	cart  Cart
+}
+
+// NewIncrementalEvaluator creates an IncrementalEvaluator for the rules. Call
+// Load before applying changes.
+func NewIncrementalEvaluator(validator *ConditionValidator, rules []Rule) *IncrementalEvaluator {
+	return &IncrementalEvaluator{cv: validator, rules: rules, state: make([]incrementalRule, len(rules))}
+}
+
+// Load evaluates every rule against the cart from scratch. Rules that cannot
+// be evaluated count as not matching and are reported by Errors.
+func (e *IncrementalEvaluator) Load(cart Cart) {
+	cart.Items = append([]Item(nil), cart.Items...)
+	e.cart = cart
+	for i := range e.rules {
+		e.evaluateRule(i)
+	}
+}
+
+// Cart returns the cart with every applied change.
+func (e *IncrementalEvaluator) Cart() Cart {
+	cart := e.cart
+	cart.Items = append([]Item(nil), e.cart.Items...)
+	return cart
+}
+
+// Matched reports, for each rule, whether it applies to the current cart.
+func (e *IncrementalEvaluator) Matched() []bool {
+	matched := make([]bool, len(e.state))
+	for i, state := range e.state {
+		matched[i] = state.matched
+	}
+	return matched
+}
+
+// Errors returns the evaluation error of each rule, nil for rules evaluated successfully.
+func (e *IncrementalEvaluator) Errors() []error {
+	errs := make([]error, len(e.state))
+	for i, state := range e.state {
+		errs[i] = state.err
+	}
+	return errs
+}
+
+// Apply applies a change to the cart and returns the rules whose outcome
+// changed, in rule order. Item changes keep the cart subtotal in step with
+// the row totals of the changed items. A change that does not fit the cart
+// returns an error and leaves the evaluator unchanged.
+func (e *IncrementalEvaluator) Apply(change CartChange) ([]RuleFlip, error) {
+	switch change.Kind {
+	case ItemAdded:
+		e.cart.Items = append(e.cart.Items, change.Item)
+		e.cart.Subtotal += change.Item.Price * float64(change.Item.Quantity)
+	case ItemRemoved, ItemQuantityChanged:
+		if change.Index < 0 || change.Index >= len(e.cart.Items) {
+			return nil, fmt.Errorf("item index %d out of range for %d items", change.Index, len(e.cart.Items))
+		}
+		item := e.cart.Items[change.Index]
+		if change.Kind == ItemRemoved {
+			e.cart.Items = append(e.cart.Items[:change.Index:change.Index], e.cart.Items[change.Index+1:]...)
+			e.cart.Subtotal -= item.Price * float64(item.Quantity)
+			break
+		}
+		if change.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity %d for item %d", change.Quantity, change.Index)
+		}
+		e.cart.Subtotal += item.Price * float64(change.Quantity-item.Quantity)
+		item.Quantity = change.Quantity
+		e.cart.Items[change.Index] = item
+	case ShippingAddressChanged:
+		e.cart.ShippingAddress = change.Address
+	case BillingAddressChanged:
+		e.cart.BillingAddress = change.Address
+	case CustomerChanged:
+		e.cart.Customer = change.Customer
+	default:
+		return nil, fmt.Errorf("unknown change kind: %d", change.Kind)
+	}
+
+	var flips []RuleFlip
+	deps := change.dependencies()
+	for i := range e.rules {
+		state := &e.state[i]
+		previous, previousErr := state.matched, state.err
+		if change.Kind == CustomerChanged {
+			// The customer group decides whether the rule is in scope
+			state.applies = e.cv.ruleApplies(e.rules[i], e.cart)
+		}
+		if state.err != nil || state.root == nil {
+			// A rule that failed has no reliable cache to update, and one
+			// without conditions has none at all
+			e.evaluateRule(i)
+		} else {
+			state.err = e.update(state.root, change, deps)
+			state.matched = state.err == nil && state.applies && state.root.value
+		}
+		if state.matched != previous || (state.err != nil) != (previousErr != nil) {
+			flips = append(flips, RuleFlip{RuleIndex: i, RuleID: e.rules[i].ID, Matched: state.matched, Err: state.err})
+		}
+	}
+	return flips, nil
+}
+
+// evaluateRule evaluates a rule against the current cart from scratch.
+func (e *IncrementalEvaluator) evaluateRule(i int) {
+	rule := e.rules[i]
+	state := &e.state[i]
+	*state = incrementalRule{applies: e.cv.ruleApplies(rule, e.cart)}
+	if err := rule.checkExperiment(); err != nil {
+		state.err = err
+		return
+	}
+	if IsEmptyCondition(rule.Conditions) {
+		// Rules without conditions match every cart they apply to
+		state.matched = state.applies
+		return
+	}
+	if err := e.cv.CheckLimits(rule.Conditions); err != nil {
+		state.err = err
+		return
+	}
+	root := e.build(RootPath, rule.Conditions)
+	if err := e.evaluate(root); err != nil {
+		state.err = err
+		return
+	}
+	state.root = root
+	state.matched = state.applies && root.value
+}
+
+// build creates the node tree of a condition and records what each node depends on.
+func (e *IncrementalEvaluator) build(path string, condition Condition) *incrementalNode {
+	node := &incrementalNode{path: path, condition: condition}
+	switch condition.Type {
+	case TypeCombine:
+		for i, subCondition := range condition.Conditions {
+			child := e.build(ChildPath(path, i), subCondition)
+			node.children = append(node.children, child)
+			node.deps |= child.deps
+		}
+	case TypeAddress:
+		billing, attribute := splitAddressAttribute(condition.Attribute)
+		switch {
+		case cartAddressAttributes[strings.ToLower(attribute)]:
+			node.deps = dependsOnItems
+		case billing:
+			node.deps = dependsOnBillingAddress
+		default:
+			node.deps = dependsOnAddress
+		}
+	case TypeCustomer, TypeSegment:
+		node.deps = dependsOnCustomer
+	default:
+		node.deps = dependsOnItems
+	}
+	return node
+}
+
+// evaluate computes a node and its children from scratch.
+func (e *IncrementalEvaluator) evaluate(node *incrementalNode) error {
+	switch node.condition.Type {
+	case TypeCombine:
+		for _, child := range node.children {
+			if err := e.evaluate(child); err != nil {
+				return err
+			}
+		}
+		return e.combine(node)
+	case TypeProduct, TypeProductFound, TypeSubselect:
+		node.matches, node.totals = nil, nil
+		for _, item := range e.cart.Items {
+			if err := e.addItem(node, item); err != nil {
+				return err
+			}
+		}
+		return e.itemsValue(node)
+	default:
+		return e.leaf(node)
+	}
+}
+
+// update brings a node affected by a change up to date.
+func (e *IncrementalEvaluator) update(node *incrementalNode, change CartChange, deps dependency) error {
+	if node.deps&deps == 0 {
+		return nil
+	}
+	switch node.condition.Type {
+	case TypeCombine:
+		for _, child := range node.children {
+			if err := e.update(child, change, deps); err != nil {
+				return err
+			}
+		}
+		return e.combine(node)
+	case TypeProduct, TypeProductFound, TypeSubselect:
+		switch change.Kind {
+		case ItemAdded:
+			if err := e.addItem(node, change.Item); err != nil {
+				return err
+			}
+		case ItemRemoved:
+			if node.matches != nil {
+				node.matches = append(node.matches[:change.Index:change.Index], node.matches[change.Index+1:]...)
+			}
+			if node.totals != nil {
+				node.totals = append(node.totals[:change.Index:change.Index], node.totals[change.Index+1:]...)
+			}
+		case ItemQuantityChanged:
+			if err := e.setItem(node, change.Index, e.cart.Items[change.Index]); err != nil {
+				return err
+			}
+		}
+		return e.itemsValue(node)
+	default:
+		return e.leaf(node)
+	}
+}
+
+// combine recomputes a Combine node from the cached values of its children.
+func (e *IncrementalEvaluator) combine(node *incrementalNode) error {
+	valid, err := e.cv.aggregate(node.condition, isTrueValue(node.condition.Value), func(i int, _ Condition) (bool, error) {
+		return node.children[i].value, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %v", node.path, err)
+	}
+	node.value = valid
+	return nil
+}
+
+// leaf evaluates an Address or Customer condition, or reports an unknown type.
+func (e *IncrementalEvaluator) leaf(node *incrementalNode) error {
+	valid, err := e.cv.validateNode(context.Background(), node.path, node.condition, e.cart)
+	if err != nil {
+		return fmt.Errorf("%s: %v", node.path, err)
+	}
+	node.value = valid
+	return nil
+}
+
+// addItem caches the result of an item node for an item appended to the cart.
+func (e *IncrementalEvaluator) addItem(node *incrementalNode, item Item) error {
+	if node.condition.Type == TypeSubselect {
+		node.totals = append(node.totals, 0)
+	} else {
+		node.matches = append(node.matches, false)
+	}
+	return e.setItem(node, e.itemCount(node)-1, item)
+}
+
+// setItem recomputes the cached result of an item node for the item at index.
+func (e *IncrementalEvaluator) setItem(node *incrementalNode, index int, item Item) error {
+	var err error
+	ctx := context.Background()
+	switch node.condition.Type {
+	case TypeProduct:
+		node.matches[index], err = e.cv.validateProductItem(node.condition, item)
+	case TypeProductFound:
+		node.matches[index], err = e.cv.validateItemConditions(ctx, node.path, node.condition, item)
+	case TypeSubselect:
+		node.totals[index], err = e.cv.subselectItemTotal(ctx, node.path, node.condition, item)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: item %s: %v", node.path, item.SKU, err)
+	}
+	return nil
+}
+
+// itemCount returns the number of items cached by an item node.
+func (e *IncrementalEvaluator) itemCount(node *incrementalNode) int {
+	if node.condition.Type == TypeSubselect {
+		return len(node.totals)
+	}
+	return len(node.matches)
+}
+
+// itemsValue recomputes an item node from its cached item results.
+func (e *IncrementalEvaluator) itemsValue(node *incrementalNode) error {
+	if node.condition.Type == TypeSubselect {
+		var total float64
+		for _, value := range node.totals {
+			total += value
+		}
+		valid, err := e.cv.compareValues(total, node.condition.Operator, node.condition.Value)
+		if err != nil {
+			return fmt.Errorf("%s: subselect comparison failed: %v", node.path, err)
+		}
+		node.value = valid
+		return nil
+	}
+
+	found := false
+	for _, match := range node.matches {
+		if match {
+			found = true
+			break
+		}
+	}
+	if node.condition.Type == TypeProductFound {
+		found = found == isTrueValue(node.condition.Value)
+	}
+	node.value = found
+	return nil
+}
@@ -0,0 +1,165 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIncrementalEvaluator(t *testing.T) {
+	shoes := Condition{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"7"}}
+	combine := func(aggregator, value string, conditions ...Condition) Condition {
+		return Condition{Type: TypeCombine, Aggregator: aggregator, Value: value, Conditions: conditions}
+	}
+	rules := []Rule{
+		{ID: 1, IsActive: true, Conditions: combine("all", "1",
+			Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"})},
+		{ID: 2, IsActive: true, Conditions: combine("all", "1",
+			Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				shoes, {Type: TypeProduct, Attribute: "qty", Operator: ">=", Value: "2"},
+			}})},
+		{ID: 3, IsActive: true, Conditions: combine("all", "1",
+			Condition{Type: TypeSubselect, Attribute: "qty", Operator: ">=", Value: "3", Aggregator: "all", Conditions: []Condition{shoes}},
+			Condition{Type: TypeAddress, Attribute: "country_id", Operator: "==", Value: "US"})},
+		{ID: 4, IsActive: true, Conditions: combine("any", "1",
+			Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"},
+			Condition{Type: TypeProductFound, Aggregator: "all", Value: "0", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SOCK-1"},
+			}})},
+		{ID: 5, IsActive: false, Conditions: combine("all", "1")},
+	}
+	cart := Cart{
+		Items:           []Item{{SKU: "SHOE-1", Quantity: 1, Price: 60, CategoryIDs: []int{7}}},
+		Subtotal:        60,
+		ShippingAddress: Address{Country: "CA"},
+		Customer:        Customer{GroupID: 1},
+	}
+
+	steps := []struct {
+		change CartChange
+		flips  []RuleFlip
+	}{
+		{
+			change: CartChange{Kind: ItemAdded, Item: Item{SKU: "SOCK-1", Quantity: 1, Price: 10, CategoryIDs: []int{7, 9}}},
+			flips:  []RuleFlip{{RuleIndex: 3, RuleID: 4}},
+		},
+		{
+			change: CartChange{Kind: ItemQuantityChanged, Index: 0, Quantity: 2},
+			flips:  []RuleFlip{{RuleIndex: 0, RuleID: 1, Matched: true}, {RuleIndex: 1, RuleID: 2, Matched: true}},
+		},
+		{
+			change: CartChange{Kind: ShippingAddressChanged, Address: Address{Country: "US"}},
+			flips:  []RuleFlip{{RuleIndex: 2, RuleID: 3, Matched: true}},
+		},
+		{
+			change: CartChange{Kind: CustomerChanged, Customer: Customer{GroupID: 2}},
+			flips:  []RuleFlip{{RuleIndex: 3, RuleID: 4, Matched: true}},
+		},
+		{
+			change: CartChange{Kind: ItemRemoved, Index: 0},
+			flips: []RuleFlip{
+				{RuleIndex: 0, RuleID: 1}, {RuleIndex: 1, RuleID: 2}, {RuleIndex: 2, RuleID: 3},
+			},
+		},
+		{
+			change: CartChange{Kind: ItemQuantityChanged, Index: 0, Quantity: 3},
+			flips:  []RuleFlip{{RuleIndex: 1, RuleID: 2, Matched: true}, {RuleIndex: 2, RuleID: 3, Matched: true}},
+		},
+		{
+			change: CartChange{Kind: ItemAdded, Item: Item{SKU: "BAG-1", Quantity: 1, Price: 80}},
+			flips:  []RuleFlip{{RuleIndex: 0, RuleID: 1, Matched: true}},
+		},
+	}
+
+	cv := NewConditionValidator()
+	evaluator := NewIncrementalEvaluator(cv, rules)
+	evaluator.Load(cart)
+	if got, want := evaluator.Matched(), []bool{false, false, false, true, false}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Matched() after Load = %v, want %v", got, want)
+	}
+	for i, step := range steps {
+		flips, err := evaluator.Apply(step.change)
+		if err != nil {
+			t.Fatalf("step %d: Apply() error = %v", i, err)
+		}
+		if !reflect.DeepEqual(flips, step.flips) {
+			t.Errorf("step %d: Apply() = %+v, want %+v", i, flips, step.flips)
+		}
+
+		// The cached outcome must match a full evaluation of the updated cart
+		current := evaluator.Cart()
+		for j, rule := range rules {
+			valid, err := cv.Validate(rule.Conditions, current)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if want := rule.IsActive && valid; evaluator.Matched()[j] != want {
+				t.Errorf("step %d: rule %d matched = %v, full evaluation = %v", i, rule.ID, evaluator.Matched()[j], want)
+			}
+		}
+	}
+	if got := evaluator.Cart().Subtotal; got != 110 {
+		t.Errorf("Subtotal = %v, want 110", got)
+	}
+
+	t.Run("Billing address", func(t *testing.T) {
+		billing := NewIncrementalEvaluator(cv, []Rule{{ID: 6, IsActive: true, Conditions: combine("all", "1",
+			Condition{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"})}})
+		billing.Load(Cart{ShippingAddress: Address{Country: "DE"}, BillingAddress: Address{Country: "US"}})
+		flips, err := billing.Apply(CartChange{Kind: ShippingAddressChanged, Address: Address{Country: "FR"}})
+		if err != nil || len(flips) != 0 {
+			t.Errorf("Apply(shipping address) = %+v, %v, want no flips", flips, err)
+		}
+		flips, err = billing.Apply(CartChange{Kind: BillingAddressChanged, Address: Address{Country: "DE"}})
+		if want := []RuleFlip{{RuleIndex: 0, RuleID: 6, Matched: true}}; err != nil || !reflect.DeepEqual(flips, want) {
+			t.Errorf("Apply(billing address) = %+v, %v, want %+v", flips, err, want)
+		}
+	})
+
+	t.Run("Rule without conditions", func(t *testing.T) {
+		unconditional := NewIncrementalEvaluator(cv, []Rule{{ID: 7, IsActive: true, CustomerGroupIDs: []int{1}}})
+		unconditional.Load(Cart{Customer: Customer{GroupID: 1}})
+		if matched, errs := unconditional.Matched(), unconditional.Errors(); !matched[0] || errs[0] != nil {
+			t.Fatalf("Load() matched %v, errors %v, want a match", matched, errs)
+		}
+		flips, err := unconditional.Apply(CartChange{Kind: ItemAdded, Item: Item{SKU: "A", Quantity: 1, Price: 5}})
+		if err != nil || len(flips) != 0 || !unconditional.Matched()[0] {
+			t.Errorf("Apply(item) = %+v, %v, want the rule to keep matching", flips, err)
+		}
+		flips, err = unconditional.Apply(CartChange{Kind: CustomerChanged, Customer: Customer{GroupID: 2}})
+		if want := []RuleFlip{{RuleIndex: 0, RuleID: 7}}; err != nil || !reflect.DeepEqual(flips, want) {
+			t.Errorf("Apply(customer) = %+v, %v, want %+v", flips, err, want)
+		}
+	})
+
+	t.Run("Invalid changes", func(t *testing.T) {
+		before := evaluator.Cart()
+		for _, change := range []CartChange{
+			{Kind: ItemRemoved, Index: 5},
+			{Kind: ItemQuantityChanged, Index: 0, Quantity: 0},
+			{Kind: ChangeKind(99)},
+		} {
+			if _, err := evaluator.Apply(change); err == nil {
+				t.Errorf("Apply(%+v) error = nil, want an error", change)
+			}
+		}
+		if !reflect.DeepEqual(evaluator.Cart(), before) {
+			t.Errorf("Cart() changed after invalid changes")
+		}
+	})
+
+	t.Run("Evaluation errors", func(t *testing.T) {
+		broken := NewIncrementalEvaluator(cv, []Rule{{ID: 9, IsActive: true, Conditions: combine("all", "1",
+			Condition{Type: TypeSubselect, Attribute: "sku", Operator: ">=", Value: "1", Aggregator: "all"})}})
+		broken.Load(Cart{})
+		if err := broken.Errors()[0]; err != nil {
+			t.Fatalf("Errors() on empty cart = %v, want nil", err)
+		}
+		flips, err := broken.Apply(CartChange{Kind: ItemAdded, Item: Item{SKU: "A", Quantity: 1}})
+		if err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if len(flips) != 1 || flips[0].Err == nil || flips[0].Matched {
+			t.Errorf("Apply() = %+v, want a failed rule", flips)
+		}
+	})
+}
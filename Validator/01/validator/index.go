@@ -0,0 +1,313 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Kinds of facts a rule can require from a cart
+const (
+	requireSKU      = "sku"
+	requireCategory = "category"
+	requireGroup    = "group"
+	requireWebsite  = "website"
+	requireSubtotal = "subtotal"
+)
+
+// ruleClause is a requirement derived from a condition tree that a cart must
+// meet for the rule to have any chance of matching: one of Values must be
+// present in the cart, or the subtotal must reach MinSubtotal.
+type ruleClause struct {
+	Kind        string
+	Values      map[string]bool
+	MinSubtotal float64
+}
+
+// IndexStats reports how effective a RuleIndex has been.
+type IndexStats struct {
+	Lookups    int64 // Carts looked up
+	Considered int64 // Rules considered over all lookups
+	Pruned     int64 // Rules excluded without full evaluation
+}
+
+// PruneRate returns the share of considered rules that were pruned.
+func (s IndexStats) PruneRate() float64 {
+	if s.Considered == 0 {
+		return 0
+	}
+	return float64(s.Pruned) / float64(s.Considered)
+}
+
+// RuleIndex excludes rules that cannot match a cart before full evaluation.
+// Requirements such as SKUs, category IDs, customer group IDs and a minimum
+// subtotal are extracted from each condition tree, and the website and customer
+// group scope from the rule, and indexed by the cart fact that satisfies them. The index never excludes a rule that could match; rules
+// whose conditions it does not understand are always candidates.
+// It is safe for concurrent use.
+type RuleIndex struct {
+	cv          *ConditionValidator
+	rules       []Rule
+	ruleClauses [][]int          // Clause IDs required by each rule
+	clauses     []ruleClause     // All clauses, by ID
+	postings    map[string][]int // Fact key to the clause IDs it satisfies
+	subtotals   []int            // Clause IDs of subtotal requirements
+	inactive    []bool
+
+	lookups    atomic.Int64
+	considered atomic.Int64
+	pruned     atomic.Int64
+}
+
+// NewRuleIndex builds an index over the given rules.
+func NewRuleIndex(rules []Rule) *RuleIndex {
+	idx := &RuleIndex{
+		cv:          NewConditionValidator(),
+		rules:       rules,
+		ruleClauses: make([][]int, len(rules)),
+		postings:    make(map[string][]int),
+		inactive:    make([]bool, len(rules)),
+	}
+	for i, rule := range rules {
+		idx.inactive[i] = !rule.IsActive
+		for _, clause := range append(idx.scopeRequirements(rule), idx.requirements(rule.Conditions)...) {
+			id := len(idx.clauses)
+			idx.clauses = append(idx.clauses, clause)
+			idx.ruleClauses[i] = append(idx.ruleClauses[i], id)
+			if clause.Kind == requireSubtotal {
+				idx.subtotals = append(idx.subtotals, id)
+				continue
+			}
+			for value := range clause.Values {
+				key := clause.Kind + ":" + value
+				idx.postings[key] = append(idx.postings[key], id)
+			}
+		}
+	}
+	return idx
+}
+
+// Candidates returns the positions of the rules that may match the cart.
+func (idx *RuleIndex) Candidates(cart Cart) []int {
+	satisfied := make([]bool, len(idx.clauses))
+	for key := range idx.cartFacts(cart) {
+		for _, id := range idx.postings[key] {
+			satisfied[id] = true
+		}
+	}
+	for _, id := range idx.subtotals {
+		satisfied[id] = cart.Subtotal >= idx.clauses[id].MinSubtotal
+	}
+
+	var candidates []int
+	for i, ids := range idx.ruleClauses {
+		if idx.inactive[i] {
+			continue
+		}
+		matched := true
+		for _, id := range ids {
+			if !satisfied[id] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			candidates = append(candidates, i)
+		}
+	}
+
+	idx.lookups.Add(1)
+	idx.considered.Add(int64(len(idx.rules)))
+	idx.pruned.Add(int64(len(idx.rules) - len(candidates)))
+	return candidates
+}
+
+// Stats returns a snapshot of the index metrics.
+func (idx *RuleIndex) Stats() IndexStats {
+	return IndexStats{
+		Lookups:    idx.lookups.Load(),
+		Considered: idx.considered.Load(),
+		Pruned:     idx.pruned.Load(),
+	}
+}
+
+// cartFacts collects the indexed facts present in the cart, including the
+// children of configurable and bundle items.
+func (idx *RuleIndex) cartFacts(cart Cart) map[string]bool {
+	facts := map[string]bool{
+		requireGroup + ":" + idx.normalize(cart.Customer.GroupID): true,
+		requireWebsite + ":" + idx.normalize(cart.WebsiteID):      true,
+	}
+	var addItem func(item Item)
+	addItem = func(item Item) {
+		facts[requireSKU+":"+idx.normalize(item.SKU)] = true
+		for _, id := range item.CategoryIDs {
+			facts[requireCategory+":"+idx.normalize(id)] = true
+		}
+		for _, child := range item.Children {
+			addItem(child)
+		}
+	}
+	for _, item := range cart.Items {
+		addItem(item)
+	}
+	return facts
+}
+
+// scopeRequirements returns the clauses for the websites and customer groups a rule is limited to.
+func (idx *RuleIndex) scopeRequirements(rule Rule) []ruleClause {
+	var clauses []ruleClause
+	scopes := []struct {
+		kind string
+		ids  []int
+	}{{requireWebsite, rule.WebsiteIDs}, {requireGroup, rule.CustomerGroupIDs}}
+	for _, scope := range scopes {
+		if len(scope.ids) == 0 {
+			continue
+		}
+		clause := ruleClause{Kind: scope.kind, Values: make(map[string]bool, len(scope.ids))}
+		for _, id := range scope.ids {
+			clause.Values[idx.normalize(id)] = true
+		}
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+// requirements derives the clauses a cart must satisfy for the condition to
+// match. All returned clauses are required; nil means no requirement is known.
+func (idx *RuleIndex) requirements(condition Condition) []ruleClause {
+	switch condition.Type {
+	case TypeCombine, TypeProductCombine, TypeProductFound:
+		if !idx.cv.isTrue(condition) {
+			return nil
+		}
+		return idx.combineRequirements(condition)
+	case TypeSubselect:
+		// Only a subselect that needs a positive total requires a matching item
+		value, err := idx.cv.toFloat64(condition.Value)
+		if err != nil {
+			return nil
+		}
+		if (condition.Operator == ">" && value >= 0) || ((condition.Operator == ">=" || condition.Operator == "==") && value > 0) {
+			return idx.combineRequirements(condition)
+		}
+		return nil
+	case TypeProduct:
+		switch strings.ToLower(condition.Attribute) {
+		case "sku":
+			return idx.valueClause(requireSKU, condition)
+		case "category_ids":
+			if condition.Operator == "()" {
+				return idx.valueClause(requireCategory, condition)
+			}
+		}
+		return nil
+	case TypeCustomer:
+		if strings.ToLower(condition.Attribute) == "group_id" {
+			return idx.valueClause(requireGroup, condition)
+		}
+		return nil
+	case TypeAddress:
+		switch strings.ToLower(condition.Attribute) {
+		case "base_subtotal", "subtotal":
+			value, err := idx.cv.toFloat64(condition.Value)
+			if err != nil {
+				return nil
+			}
+			switch condition.Operator {
+			case ">=", ">", "==":
+				return []ruleClause{{Kind: requireSubtotal, MinSubtotal: value}}
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// combineRequirements merges the requirements of the subconditions: with
+// "all" every requirement holds, with "any" only a requirement shared by
+// every subcondition can be kept.
+func (idx *RuleIndex) combineRequirements(condition Condition) []ruleClause {
+	switch condition.Aggregator {
+	case "all", "":
+		var clauses []ruleClause
+		for _, subCondition := range condition.Conditions {
+			clauses = append(clauses, idx.requirements(subCondition)...)
+		}
+		return clauses
+	case "any":
+		if len(condition.Conditions) == 0 {
+			return nil
+		}
+		for _, kind := range []string{requireSKU, requireCategory, requireGroup, requireSubtotal} {
+			merged := ruleClause{Kind: kind, Values: make(map[string]bool)}
+			for i, subCondition := range condition.Conditions {
+				clause, ok := idx.findClause(idx.requirements(subCondition), kind)
+				if !ok {
+					merged.Kind = ""
+					break
+				}
+				for value := range clause.Values {
+					merged.Values[value] = true
+				}
+				if i == 0 || clause.MinSubtotal < merged.MinSubtotal {
+					merged.MinSubtotal = clause.MinSubtotal
+				}
+			}
+			if merged.Kind != "" {
+				return []ruleClause{merged}
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// findClause returns the first clause of the given kind.
+func (idx *RuleIndex) findClause(clauses []ruleClause, kind string) (ruleClause, bool) {
+	for _, clause := range clauses {
+		if clause.Kind == kind {
+			return clause, true
+		}
+	}
+	return ruleClause{}, false
+}
+
+// valueClause builds a clause from an equality or "is one of" condition.
+func (idx *RuleIndex) valueClause(kind string, condition Condition) []ruleClause {
+	values := make(map[string]bool)
+	switch condition.Operator {
+	case "==":
+		values[idx.normalize(condition.Value)] = true
+	case "()":
+		switch v := condition.Value.(type) {
+		case []interface{}:
+			for _, value := range v {
+				values[idx.normalize(value)] = true
+			}
+		case string:
+			for _, value := range strings.Split(v, ",") {
+				values[idx.normalize(strings.TrimSpace(value))] = true
+			}
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+	return []ruleClause{{Kind: kind, Values: values}}
+}
+
+// normalize turns a value into an index key. Numbers are formatted
+// canonically, without exponents, because the validator compares numeric
+// strings as numbers and cart facts and rule values must agree.
+func (idx *RuleIndex) normalize(value interface{}) string {
+	if number, err := idx.cv.toFloat64(value); err == nil {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,119 @@
+package validator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRuleIndex(t *testing.T) {
+	rules := []Rule{
+		{
+			ID: 1, IsActive: true,
+			Conditions: Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProductFound, Value: "1", Aggregator: "all", Conditions: []Condition{
+					{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SHIRT-RED-M"},
+				}},
+				{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"},
+			}},
+		},
+		{
+			ID: 2, IsActive: true,
+			Conditions: Condition{Type: TypeCombine, Aggregator: "any", Value: "1", Conditions: []Condition{
+				{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"},
+				{Type: TypeCustomer, Attribute: "group_id", Operator: "()", Value: []interface{}{"3", "4"}},
+			}},
+		},
+		{
+			ID: 3, IsActive: true,
+			Conditions: Condition{Type: TypeSubselect, Attribute: "qty", Operator: ">=", Value: "2", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"30"}},
+			}},
+		},
+		{
+			ID: 4, IsActive: true,
+			Conditions: Condition{Type: TypeCustomer, Attribute: "email", Operator: "{}", Value: "example.com"},
+		},
+		{ID: 5, IsActive: false},
+	}
+
+	tests := []struct {
+		name string
+		cart Cart
+		want []int
+	}{
+		{
+			name: "Child SKU and subtotal satisfy rule 1",
+			cart: Cart{
+				Subtotal: 120,
+				Items:    []Item{{SKU: "SHIRT", ProductType: ProductTypeConfigurable, Children: []Item{{SKU: "SHIRT-RED-M"}}}},
+				Customer: Customer{GroupID: 1},
+			},
+			want: []int{0, 3},
+		},
+		{
+			name: "Subtotal too low prunes rule 1",
+			cart: Cart{
+				Subtotal: 50,
+				Items:    []Item{{SKU: "SHIRT-RED-M", CategoryIDs: []int{30}}},
+				Customer: Customer{GroupID: 4},
+			},
+			want: []int{1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewRuleIndex(rules)
+			got := index.Candidates(tt.cart)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RuleIndex.Candidates() = %v, want %v", got, tt.want)
+			}
+			stats := index.Stats()
+			if stats.Pruned != int64(len(rules)-len(tt.want)) {
+				t.Errorf("RuleIndex.Stats().Pruned = %d, want %d", stats.Pruned, len(rules)-len(tt.want))
+			}
+		})
+	}
+
+	t.Run("Prefilter keeps batch results unchanged", func(t *testing.T) {
+		createCarts := func() <-chan Cart {
+			carts := make(chan Cart, len(tests))
+			for _, tt := range tests {
+				carts <- tt.cart
+			}
+			close(carts)
+			return carts
+		}
+		validator := NewConditionValidator()
+		plain, err := NewBatchEvaluator(validator, 2).Run(context.Background(), rules, createCarts(), nil)
+		if err != nil {
+			t.Fatalf("BatchEvaluator.Run() error = %v", err)
+		}
+		filtered, err := NewBatchEvaluator(validator, 2).WithPrefilter().Run(context.Background(), rules, createCarts(), nil)
+		if err != nil {
+			t.Fatalf("BatchEvaluator.Run() error = %v", err)
+		}
+		if plain.Matches != filtered.Matches || filtered.Pruned == 0 {
+			t.Errorf("prefiltered run matched %d (pruned %d), want %d", filtered.Matches, filtered.Pruned, plain.Matches)
+		}
+	})
+}
+
+func TestRuleIndexLargeCategoryIDs(t *testing.T) {
+	rule := Rule{
+		ID: 1, IsActive: true,
+		Conditions: Condition{Type: TypeProductFound, Value: "1", Aggregator: "all", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"1234567"}},
+		}},
+	}
+	cart := Cart{Items: []Item{{SKU: "LAMP", CategoryIDs: []int{1234567}}}}
+
+	matched, err := NewConditionValidator().ValidateRule(rule, cart)
+	if err != nil || !matched {
+		t.Fatalf("ValidateRule() = %v, %v, want a match", matched, err)
+	}
+	if got := NewRuleIndex([]Rule{rule}).Candidates(cart); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("RuleIndex.Candidates() = %v, want [0]", got)
+	}
+}
@@ -0,0 +1,67 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrLimitExceeded is returned when a condition tree is larger than the validator allows.
+var ErrLimitExceeded = errors.New("condition exceeds evaluation limits")
+
+// Limits bounds the size of condition trees a validator accepts, so that a
+// malicious or buggy rule from the admin UI cannot exhaust checkout.
+// A zero field means no limit.
+type Limits struct {
+	MaxDepth   int // Maximum nesting depth, the root condition being at depth 1
+	MaxNodes   int // Maximum number of conditions in the tree
+	MaxSetSize int // Maximum number of values in an "is one of" list
+}
+
+// DefaultLimits returns limits that comfortably fit rules built in the Magento admin.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxDepth:   32,
+		MaxNodes:   1000,
+		MaxSetSize: 10000,
+	}
+}
+
+// CheckLimits verifies that the condition tree fits within the validator limits.
+// The returned error wraps ErrLimitExceeded.
+func (cv *ConditionValidator) CheckLimits(condition Condition) error {
+	nodes := 0
+	return cv.checkLimits(condition, 1, &nodes)
+}
+
+// CheckRule checks that a rule can be evaluated: its experiment and variants
+// must be valid and its conditions and actions within the validator limits.
+// A RuleLoader rejects files with rules that fail it.
+func (cv *ConditionValidator) CheckRule(rule Rule) error {
+	if err := rule.checkExperiment(); err != nil {
+		return err
+	}
+	if err := cv.CheckLimits(rule.Conditions); err != nil {
+		return err
+	}
+	return cv.CheckLimits(rule.Actions)
+}
+
+// checkLimits walks the tree without descending past the maximum depth.
+func (cv *ConditionValidator) checkLimits(condition Condition, depth int, nodes *int) error {
+	if cv.limits.MaxDepth > 0 && depth > cv.limits.MaxDepth {
+		return fmt.Errorf("%w: depth exceeds %d", ErrLimitExceeded, cv.limits.MaxDepth)
+	}
+	*nodes++
+	if cv.limits.MaxNodes > 0 && *nodes > cv.limits.MaxNodes {
+		return fmt.Errorf("%w: more than %d conditions", ErrLimitExceeded, cv.limits.MaxNodes)
+	}
+	if values, ok := condition.Value.([]interface{}); ok && cv.limits.MaxSetSize > 0 && len(values) > cv.limits.MaxSetSize {
+		return fmt.Errorf("%w: %d values for attribute %s exceed %d", ErrLimitExceeded, len(values), condition.Attribute, cv.limits.MaxSetSize)
+	}
+	for _, subCondition := range condition.Conditions {
+		if err := cv.checkLimits(subCondition, depth+1, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConditionValidatorLimits(t *testing.T) {
+	validator := NewConditionValidatorWithLimits(Limits{MaxDepth: 3, MaxNodes: 5, MaxSetSize: 2})
+	cart := Cart{Items: []Item{{SKU: "SKU001", Name: "a.c (special)", Quantity: 1}}}
+
+	nest := func(depth int) Condition {
+		condition := Condition{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SKU001"}
+		for i := 1; i < depth; i++ {
+			condition = Condition{Type: TypeCombine, Aggregator: "all", Conditions: []Condition{condition}}
+		}
+		return condition
+	}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		wantErr   bool
+	}{
+		{name: "Within limits", condition: nest(3)},
+		{name: "Too deep", condition: nest(4), wantErr: true},
+		{
+			name: "Too many nodes",
+			condition: Condition{Type: TypeCombine, Aggregator: "any", Conditions: []Condition{
+				nest(1), nest(1), nest(1), nest(1), nest(1),
+			}},
+			wantErr: true,
+		},
+		{
+			name:      "Set too large",
+			condition: Condition{Type: TypeProduct, Attribute: "sku", Operator: "()", Value: []interface{}{"A", "B", "SKU001"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.Validate(tt.condition, cart)
+			if tt.wantErr != errors.Is(err, ErrLimitExceeded) {
+				t.Errorf("Validator.Validate() error = %v, want limit error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Like pattern metacharacters are literal", func(t *testing.T) {
+		for pattern, want := range map[string]bool{
+			"a.c (%":    true,
+			"a_c%":      false,
+			"(special)": true,
+			"abc":       false,
+			"a.c%[":     false,
+		} {
+			condition := Condition{Type: TypeProduct, Attribute: "name", Operator: "like", Value: pattern}
+			got, err := validator.Validate(condition, cart)
+			if err != nil {
+				t.Fatalf("Validator.Validate(%q) error = %v", pattern, err)
+			}
+			if got != want {
+				t.Errorf("Validator.Validate(%q) = %v, want %v", pattern, got, want)
+			}
+		}
+	})
+
+	t.Run("Stops when the deadline has passed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		_, err := validator.ValidateContext(ctx, nest(3), cart)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Validator.ValidateContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	})
+}
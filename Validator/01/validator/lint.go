@@ -0,0 +1,449 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a lint diagnostic is.
+type Severity string
+
+// Diagnostic severities
+const (
+	SeverityError   Severity = "error"   // The condition fails or misbehaves at evaluation time
+	SeverityWarning Severity = "warning" // The condition evaluates, but probably not as intended
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic codes
+const (
+	LintUnknownType       = "unknown-type"
+	LintMisplacedType     = "misplaced-type"
+	LintUnknownAttribute  = "unknown-attribute"
+	LintInvalidOperator   = "invalid-operator"
+	LintMalformedValue    = "malformed-value"
+	LintUnknownAggregator = "unknown-aggregator"
+	LintEmptyCombine      = "empty-combine"
+	LintAlwaysTrue        = "always-true"
+	LintAlwaysFalse       = "always-false"
+)
+
+// Diagnostic is a problem found by the Linter. Path is the JSON path of the
+// condition, as used by observers, and Field the offending condition field.
+type Diagnostic struct {
+	Path     string   `json:"path"`
+	Field    string   `json:"field,omitempty"`
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", d.Path, d.Severity, d.Message, d.Code)
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Operators allowed for each attribute kind
+var kindOperators = map[AttributeKind]map[string]bool{
+	KindString: {"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "{}": true, "!{}": true,
+		"()": true, "!()": true, "like": true, "nlike": true, "null": true, "notnull": true},
+	KindNumber: {"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true,
+		"()": true, "!()": true, "null": true, "notnull": true},
+	KindDate: {"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true, "null": true, "notnull": true},
+	KindIDs:  {"()": true, "!()": true, "null": true, "notnull": true},
+	KindBool: {"==": true, "!=": true, "null": true, "notnull": true},
+	KindList: {"null": true, "notnull": true},
+}
+
+// constant is the statically known result of a condition, if any.
+type constant struct {
+	known bool
+	value bool
+}
+
+// Linter checks condition trees without evaluating them against a cart:
+// unknown types and attributes, operators that cannot apply to an attribute,
+// malformed values, empty combines and branches that are always true or false.
+type Linter struct {
+	cv          *ConditionValidator
+	diagnostics []Diagnostic
+}
+
+// NewLinter creates a new Linter.
+func NewLinter() *Linter {
+	return &Linter{cv: NewConditionValidator()}
+}
+
+// operatorOrder is the order operators are offered in the Magento admin.
+var operatorOrder = []string{"==", "!=", ">=", ">", "<=", "<", "{}", "!{}", "()", "!()", "like", "nlike", "null", "notnull"}
+
+// KindOperators returns the operators allowed for an attribute kind, in the
+// order the Magento admin offers them.
+func KindOperators(kind AttributeKind) []string {
+	var operators []string
+	for _, operator := range operatorOrder {
+		if kindOperators[kind][operator] {
+			operators = append(operators, operator)
+		}
+	}
+	return operators
+}
+
+// Lint checks the conditions of a rule, which are evaluated against a cart.
+func (l *Linter) Lint(condition Condition) []Diagnostic {
+	l.diagnostics = nil
+	l.lint(RootPath, condition, false, true)
+	return l.diagnostics
+}
+
+// LintActions checks the actions of a rule, which are evaluated against each item.
+func (l *Linter) LintActions(condition Condition) []Diagnostic {
+	l.diagnostics = nil
+	l.lint(RootPath, condition, true, true)
+	return l.diagnostics
+}
+
+func (l *Linter) report(path, field string, severity Severity, code, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{
+		Path:     path,
+		Field:    field,
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// lint checks a condition in cart or item context and returns its constant result.
+func (l *Linter) lint(path string, condition Condition, item, root bool) constant {
+	switch condition.Type {
+	case TypeCombine, TypeProductCombine:
+		if item && condition.Type == TypeCombine {
+			l.report(path, "type", SeverityError, LintMisplacedType, "Combine cannot be used on items, use Product\\Combine")
+		} else if !item && condition.Type == TypeProductCombine {
+			l.report(path, "type", SeverityError, LintMisplacedType, "Product\\Combine can only be used in rule actions")
+		}
+		l.lintCombineValue(path, condition)
+		result := l.lintAggregate(path, condition, isTrueValue(condition.Value), item, root)
+		if result.known && len(condition.Conditions) > 0 {
+			return l.reportConstant(path, result.value)
+		}
+		return result
+	case TypeProductFound:
+		if item {
+			l.report(path, "type", SeverityError, LintMisplacedType, "Product\\Found cannot be nested in item conditions")
+			return constant{}
+		}
+		l.lintCombineValue(path, condition)
+		matches := l.lintAggregate(path, condition, true, true, false)
+		if matches.known && !matches.value {
+			// No item can match, so FOUND never holds and NOT FOUND always does
+			return l.reportConstant(path, !isTrueValue(condition.Value))
+		}
+		return constant{}
+	case TypeSubselect:
+		if item {
+			l.report(path, "type", SeverityError, LintMisplacedType, "Product\\Subselect cannot be nested in item conditions")
+			return constant{}
+		}
+		return l.lintSubselect(path, condition)
+	case TypeProduct:
+		result := l.lintAttribute(path, condition, SeverityWarning)
+		if !item {
+			// On the cart a product condition holds when some item matches it,
+			// so even one every item matches fails on an empty cart
+			return constant{}
+		}
+		return l.reportKnown(path, result)
+	case TypeAddress, TypeCustomer:
+		if item {
+			l.report(path, "type", SeverityError, LintMisplacedType, "%s conditions cannot be used on items", shortType(condition.Type))
+			return constant{}
+		}
+		severity := SeverityError
+		if condition.Type == TypeCustomer {
+			// Customers may carry custom attributes
+			severity = SeverityWarning
+		}
+		return l.reportKnown(path, l.lintAttribute(path, condition, severity))
+	case TypeSegment:
+		if item {
+			l.report(path, "type", SeverityError, LintMisplacedType, "Segment conditions cannot be used on items")
+			return constant{}
+		}
+		switch condition.Operator {
+		case "==", "!=", "()", "!()", "":
+		default:
+			l.report(path, "operator", SeverityError, LintInvalidOperator, "operator %q cannot be used on customer segments", condition.Operator)
+		}
+		if names, err := segmentNames(condition.Value); err != nil {
+			l.report(path, "value", SeverityError, LintMalformedValue, "%v", err)
+		} else if len(names) == 0 {
+			l.report(path, "value", SeverityError, LintMalformedValue, "no customer segment given")
+		}
+		return constant{}
+	default:
+		l.report(path, "type", SeverityError, LintUnknownType, "unknown condition type %q", condition.Type)
+		return constant{}
+	}
+}
+
+// lintCombineValue checks the TRUE/FALSE value of a Combine-style condition.
+func (l *Linter) lintCombineValue(path string, condition Condition) {
+	switch v := condition.Value.(type) {
+	case nil, bool:
+	case string:
+		if v != "1" && v != "0" && !strings.EqualFold(v, "true") && !strings.EqualFold(v, "false") {
+			l.report(path, "value", SeverityWarning, LintMalformedValue, "value %q is neither \"1\" nor \"0\" and is treated as TRUE", v)
+		}
+	case float64:
+	default:
+		l.report(path, "value", SeverityWarning, LintMalformedValue, "value %v is neither \"1\" nor \"0\" and is treated as TRUE", v)
+	}
+}
+
+// lintAggregate checks the subconditions of a Combine-style condition and folds
+// their constant results the way aggregate does, leaving the caller to report it.
+func (l *Linter) lintAggregate(path string, condition Condition, expected, item, root bool) constant {
+	switch condition.Aggregator {
+	case "all", "any", "":
+	default:
+		l.report(path, "aggregator", SeverityError, LintUnknownAggregator, "unknown aggregator %q", condition.Aggregator)
+		return constant{}
+	}
+
+	if len(condition.Conditions) == 0 {
+		switch {
+		case condition.Type == TypeProductFound || condition.Type == TypeSubselect:
+			// Every item is selected
+		case root:
+			l.report(path, "", SeverityInfo, LintEmptyCombine, "no conditions, the rule applies to everything")
+		default:
+			l.report(path, "", SeverityWarning, LintEmptyCombine, "empty condition group is always true")
+		}
+		return constant{known: true, value: true}
+	}
+
+	results := make([]constant, len(condition.Conditions))
+	for i, subCondition := range condition.Conditions {
+		results[i] = l.lint(ChildPath(path, i), subCondition, item, false)
+	}
+
+	// With "all" one subcondition differing from expected decides, with "any" one matching does
+	decisive := expected
+	if condition.Aggregator != "any" {
+		decisive = !expected
+	}
+	allKnown := true
+	for _, result := range results {
+		if result.known && result.value == decisive {
+			return constant{known: true, value: condition.Aggregator == "any"}
+		}
+		allKnown = allKnown && result.known
+	}
+	return constant{known: allKnown, value: condition.Aggregator != "any"}
+}
+
+// lintSubselect checks a Subselect condition and its item subconditions.
+func (l *Linter) lintSubselect(path string, condition Condition) constant {
+	if kind, ok := LookupAttribute(TypeProduct, condition.Attribute); !ok || kind != KindNumber {
+		l.report(path, "attribute", SeverityError, LintUnknownAttribute, "cannot total attribute %q, use qty or base_row_total", condition.Attribute)
+	}
+	switch condition.Operator {
+	case "==", "!=", ">", ">=", "<", "<=":
+	default:
+		l.report(path, "operator", SeverityError, LintInvalidOperator, "operator %q cannot compare a total", condition.Operator)
+		return constant{}
+	}
+	value, err := l.cv.toFloat64(condition.Value)
+	if err != nil {
+		l.report(path, "value", SeverityError, LintMalformedValue, "value %v is not a number", condition.Value)
+		return constant{}
+	}
+
+	matches := l.lintAggregate(path, condition, true, true, false)
+	if matches.known && !matches.value {
+		// No item is selected, so the total is always zero
+		return l.reportConstant(path, compareFloats(0, condition.Operator, value))
+	}
+	if result, ok := l.nonNegativeBound(condition.Operator, value); ok {
+		return l.reportConstant(path, result)
+	}
+	return constant{}
+}
+
+// lintAttribute checks an attribute comparison of a Product, Address or Customer
+// condition and returns its constant result, leaving the caller to report it.
+// unknown is the severity of an attribute missing from the catalog.
+func (l *Linter) lintAttribute(path string, condition Condition, unknown Severity) constant {
+	if _, ok := operatorLabels[condition.Operator]; !ok {
+		l.report(path, "operator", SeverityError, LintInvalidOperator, "unknown operator %q", condition.Operator)
+		return constant{}
+	}
+
+	attribute := condition.Attribute
+	if condition.Type == TypeAddress {
+		_, attribute = splitAddressAttribute(attribute)
+	}
+	if isAttributePath(attribute) {
+		// Paths reach custom attributes and related entities outside the catalog
+		if _, err := parseAttributePath(attribute); err != nil {
+			l.report(path, "attribute", SeverityError, LintUnknownAttribute, "%v", err)
+			return constant{}
+		}
+		return l.lintValue(path, condition, KindString, false)
+	}
+
+	kind, ok := LookupAttribute(condition.Type, attribute)
+	if !ok {
+		if condition.Attribute == "" {
+			l.report(path, "attribute", SeverityError, LintUnknownAttribute, "missing attribute")
+		} else {
+			l.report(path, "attribute", unknown, LintUnknownAttribute, "unknown %s attribute %q", shortType(condition.Type), condition.Attribute)
+		}
+		return l.lintValue(path, condition, KindString, false)
+	}
+	if !kindOperators[kind][condition.Operator] {
+		l.report(path, "operator", SeverityError, LintInvalidOperator, "operator %q (%s) cannot be used on %s attribute %q",
+			condition.Operator, operatorLabels[condition.Operator], kind, condition.Attribute)
+		return constant{}
+	}
+	return l.lintValue(path, condition, kind, true)
+}
+
+// lintValue checks the value of a comparison against an attribute of the given kind.
+// strict is false for attributes outside the catalog, whose kind is a guess.
+func (l *Linter) lintValue(path string, condition Condition, kind AttributeKind, strict bool) constant {
+	switch condition.Operator {
+	case "null", "notnull":
+		return constant{}
+	case "()", "!()":
+		values, ok := condition.Value.([]interface{})
+		if !ok {
+			l.report(path, "value", SeverityError, LintMalformedValue, "operator %q needs a list of values", condition.Operator)
+			return constant{}
+		}
+		for _, value := range values {
+			l.lintScalar(path, value, kind, strict)
+		}
+		if len(values) == 0 {
+			return constant{known: true, value: condition.Operator == "!()"}
+		}
+		return constant{}
+	case "{}", "!{}", "like", "nlike":
+		value, ok := condition.Value.(string)
+		if !ok {
+			l.report(path, "value", SeverityError, LintMalformedValue, "operator %q needs a string value", condition.Operator)
+			return constant{}
+		}
+		if (condition.Operator == "{}" || condition.Operator == "!{}") && value == "" ||
+			(condition.Operator == "like" || condition.Operator == "nlike") && strings.Trim(value, "%") == "" {
+			return constant{known: true, value: condition.Operator == "{}" || condition.Operator == "like"}
+		}
+		return constant{}
+	default:
+		if _, ok := condition.Value.([]interface{}); ok {
+			l.report(path, "value", SeverityError, LintMalformedValue, "operator %q needs a single value, not a list", condition.Operator)
+			return constant{}
+		}
+		if !l.lintScalar(path, condition.Value, kind, strict) || kind != KindNumber || !strict {
+			return constant{}
+		}
+		value, _ := l.cv.toFloat64(condition.Value)
+		if result, ok := l.nonNegativeBound(condition.Operator, value); ok && nonNegativeAttributes[strings.ToLower(condition.Attribute)] {
+			return constant{known: true, value: result}
+		}
+		return constant{}
+	}
+}
+
+// lintScalar checks a single value against an attribute kind and reports whether it is well-formed.
+func (l *Linter) lintScalar(path string, value interface{}, kind AttributeKind, strict bool) bool {
+	switch value.(type) {
+	case string, float64, bool:
+	default:
+		l.report(path, "value", SeverityError, LintMalformedValue, "value %v is not a string or number", value)
+		return false
+	}
+	if !strict {
+		return true
+	}
+	switch kind {
+	case KindNumber, KindBool:
+		if _, err := l.cv.toFloat64(value); err != nil {
+			l.report(path, "value", SeverityError, LintMalformedValue, "value %v is not a number", value)
+			return false
+		}
+	case KindIDs:
+		// Category IDs are only matched when given as strings of digits
+		s, ok := value.(string)
+		if _, err := strconv.Atoi(s); !ok || err != nil {
+			l.report(path, "value", SeverityError, LintMalformedValue, "ID %v must be a string of digits", value)
+			return false
+		}
+	case KindDate:
+		if _, err := l.cv.toTime(value); err != nil {
+			l.report(path, "value", SeverityError, LintMalformedValue, "value %v is not a date", value)
+			return false
+		}
+	}
+	return true
+}
+
+// nonNegativeBound returns the result of comparing a value that is never below
+// zero against a bound, when the comparison alone decides it.
+func (l *Linter) nonNegativeBound(operator string, bound float64) (bool, bool) {
+	switch {
+	case operator == ">=" && bound <= 0, operator == ">" && bound < 0, operator == "!=" && bound < 0:
+		return true, true
+	case operator == "<" && bound <= 0, operator == "<=" && bound < 0, operator == "==" && bound < 0:
+		return false, true
+	}
+	return false, false
+}
+
+// reportKnown reports a constant result, if it is known.
+func (l *Linter) reportKnown(path string, result constant) constant {
+	if !result.known {
+		return result
+	}
+	return l.reportConstant(path, result.value)
+}
+
+// reportConstant reports a condition that is always true or always false.
+func (l *Linter) reportConstant(path string, value bool) constant {
+	if value {
+		l.report(path, "", SeverityWarning, LintAlwaysTrue, "condition is always true")
+	} else {
+		l.report(path, "", SeverityWarning, LintAlwaysFalse, "condition is always false")
+	}
+	return constant{known: true, value: value}
+}
+
+// compareFloats compares two numbers with a comparison operator.
+func compareFloats(a float64, operator string, b float64) bool {
+	switch operator {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package validator
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLinter(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		actions   bool
+		want      []Diagnostic
+	}{
+		{
+			name: "Clean rule",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "100"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "group_id", "operator": "()", "value": ["1", "2"]},
+					{
+						"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+						"value": "1",
+						"aggregator": "all",
+						"conditions": [
+							{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "category_ids", "operator": "()", "value": ["3", "4"]}
+						]
+					}
+				]
+			}`,
+		},
+		{
+			name: "Unknown type and attribute",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Coupon", "attribute": "code", "operator": "==", "value": "X"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "planet", "operator": "==", "value": "Earth"}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[0]", Field: "type", Severity: SeverityError, Code: LintUnknownType, Message: `unknown condition type "Magento\\SalesRule\\Model\\Rule\\Condition\\Coupon"`},
+				{Path: "$.conditions[1]", Field: "attribute", Severity: SeverityError, Code: LintUnknownAttribute, Message: `unknown Address attribute "planet"`},
+			},
+		},
+		{
+			name: "Operators and values",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"aggregator": "any",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "quantity", "operator": "like", "value": "2%"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">", "value": "lots"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "created_at", "operator": "<", "value": "yesterday"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "category_ids", "operator": "()", "value": "3"}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[0]", Field: "operator", Severity: SeverityError, Code: LintInvalidOperator, Message: `operator "like" (is like) cannot be used on number attribute "quantity"`},
+				{Path: "$.conditions[1]", Field: "value", Severity: SeverityError, Code: LintMalformedValue, Message: "value lots is not a number"},
+				{Path: "$.conditions[2]", Field: "value", Severity: SeverityError, Code: LintMalformedValue, Message: "value yesterday is not a date"},
+				{Path: "$.conditions[3]", Field: "value", Severity: SeverityError, Code: LintMalformedValue, Message: `operator "()" needs a list of values`},
+			},
+		},
+		{
+			name: "Always false branch",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "country", "operator": "==", "value": "US"},
+					{
+						"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+						"aggregator": "any",
+						"conditions": [
+							{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "total_qty", "operator": "<", "value": "0"},
+							{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "group_id", "operator": "()", "value": []}
+						]
+					}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[1].conditions[0]", Severity: SeverityWarning, Code: LintAlwaysFalse, Message: "condition is always false"},
+				{Path: "$.conditions[1].conditions[1]", Severity: SeverityWarning, Code: LintAlwaysFalse, Message: "condition is always false"},
+				{Path: "$.conditions[1]", Severity: SeverityWarning, Code: LintAlwaysFalse, Message: "condition is always false"},
+				{Path: "$", Severity: SeverityWarning, Code: LintAlwaysFalse, Message: "condition is always false"},
+			},
+		},
+		{
+			name: "Empty group and always true subselect",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine", "aggregator": "all", "conditions": []},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect", "attribute": "qty", "operator": ">=", "value": "0", "aggregator": "all", "conditions": []}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[0]", Severity: SeverityWarning, Code: LintEmptyCombine, Message: "empty condition group is always true"},
+				{Path: "$.conditions[1]", Severity: SeverityWarning, Code: LintAlwaysTrue, Message: "condition is always true"},
+				{Path: "$", Severity: SeverityWarning, Code: LintAlwaysTrue, Message: "condition is always true"},
+			},
+		},
+		{
+			name: "Not found without matching items",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "0",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "()", "value": []}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[0]", Severity: SeverityWarning, Code: LintAlwaysFalse, Message: "condition is always false"},
+				{Path: "$", Severity: SeverityWarning, Code: LintAlwaysTrue, Message: "condition is always true"},
+			},
+		},
+		{
+			name: "Product condition on the cart",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "qty", "operator": ">=", "value": "0"}
+				]
+			}`,
+		},
+		{
+			name:    "Misplaced types in actions",
+			actions: true,
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Combine",
+				"value": "1",
+				"aggregator": "yes",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "country", "operator": "==", "value": "US"}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$", Field: "aggregator", Severity: SeverityError, Code: LintUnknownAggregator, Message: `unknown aggregator "yes"`},
+			},
+		},
+		{
+			name:    "Address condition on items",
+			actions: true,
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Combine",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "country", "operator": "==", "value": "US"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "color", "operator": "==", "value": "red"}
+				]
+			}`,
+			want: []Diagnostic{
+				{Path: "$.conditions[0]", Field: "type", Severity: SeverityError, Code: LintMisplacedType, Message: "Address conditions cannot be used on items"},
+				{Path: "$.conditions[1]", Field: "attribute", Severity: SeverityWarning, Code: LintUnknownAttribute, Message: `unknown Product attribute "color"`},
+			},
+		},
+	}
+
+	linter := NewLinter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var condition Condition
+			if err := json.Unmarshal([]byte(tt.condition), &condition); err != nil {
+				t.Fatalf("Failed to unmarshal condition: %v", err)
+			}
+
+			var got []Diagnostic
+			if tt.actions {
+				got = linter.LintActions(condition)
+			} else {
+				got = linter.Lint(condition)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lint() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
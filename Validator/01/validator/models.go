@@ -0,0 +1,165 @@
+package validator
+
+import "time"
+
+// Condition types understood by the validator, named after their Magento classes
+const (
+	TypeCombine        = "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine"
+	TypeAddress        = "Magento\\SalesRule\\Model\\Rule\\Condition\\Address"
+	TypeCustomer       = "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer"
+	TypeProduct        = "Magento\\SalesRule\\Model\\Rule\\Condition\\Product"
+	TypeProductFound   = "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found"
+	TypeSubselect      = "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect"
+	TypeProductCombine = "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Combine"
+	TypeSegment        = "Magento\\CustomerSegment\\Model\\Segment\\Condition\\Segment"
+)
+
+// Product types that change how parent and child items are validated
+const (
+	ProductTypeSimple       = "simple"
+	ProductTypeConfigurable = "configurable"
+	ProductTypeBundle       = "bundle"
+)
+
+// Condition represents a generic condition structure
+type Condition struct {
+	Type             string      `json:"type"`
+	Attribute        string      `json:"attribute"`
+	Operator         string      `json:"operator"`
+	Value            interface{} `json:"value"`
+	IsValueProcessed bool        `json:"is_value_processed"`
+	Aggregator       string      `json:"aggregator"`
+	Conditions       []Condition `json:"conditions"`
+}
+
+// Cart represents a shopping cart
+type Cart struct {
+	Items           []Item
+	Subtotal        float64
+	GrandTotal      float64
+	ShippingAddress Address
+	BillingAddress  Address
+	Customer        Customer
+	CouponCode      string
+	CreatedAt       time.Time
+	WebsiteID       int
+	StoreID         int
+}
+
+// Item represents a product in the cart.
+// Configurable and bundle items carry their selected products in Children;
+// quantity and pricing of a configurable item live on the parent.
+type Item struct {
+	SKU          string
+	Name         string
+	ProductType  string
+	Quantity     int
+	Price        float64
+	FinalPrice   float64
+	SpecialPrice float64
+	Weight       float64
+	CategoryIDs  []int
+	Attributes   map[string]interface{}
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+
+	// Children are the simple products selected for a configurable item or
+	// the options of a bundle item.
+	Children []Item
+	// IsChildrenCalculated is set for bundles with dynamic pricing, where
+	// the children are priced and discounted instead of the parent.
+	IsChildrenCalculated bool
+}
+
+// Address represents a customer address
+type Address struct {
+	Country    string
+	Region     string
+	RegionID   int
+	City       string
+	PostalCode string
+	Street     []string
+	Telephone  string
+	Company    string
+	FirstName  string
+	LastName   string
+	Email      string
+}
+
+// Customer represents a customer
+type Customer struct {
+	ID                 int
+	GroupID            int
+	Email              string
+	FirstName          string
+	LastName           string
+	Gender             string
+	DateOfBirth        time.Time
+	CreatedAt          time.Time
+	LastLoginAt        time.Time
+	Orders             int
+	TotalSpent         float64
+	AverageOrderAmount float64
+	Addresses          []Address
+	IsSubscribed       bool
+	Attributes         map[string]interface{}
+}
+
+// Product represents a product (for more detailed product conditions)
+type Product struct {
+	ID            int
+	SKU           string
+	Name          string
+	Price         float64
+	SpecialPrice  float64
+	Weight        float64
+	Status        int
+	Visibility    int
+	TypeID        string
+	CategoryIDs   []int
+	Attributes    map[string]interface{}
+	StockQuantity int
+	InStock       bool
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Discount actions of a sales rule (Rule.SimpleAction)
+const (
+	ActionByPercent = "by_percent"
+	ActionByFixed   = "by_fixed"
+	ActionCartFixed = "cart_fixed"
+	ActionBuyXGetY  = "buy_x_get_y"
+)
+
+// Rule represents a sales rule
+type Rule struct {
+	ID                  int            `json:"id"`
+	Name                string         `json:"name"`
+	Description         string         `json:"description"`
+	FromDate            time.Time      `json:"from_date"`
+	ToDate              time.Time      `json:"to_date"`
+	IsActive            bool           `json:"is_active"`
+	Conditions          Condition      `json:"conditions"`
+	Actions             Condition      `json:"actions"`
+	StopRulesProcessing bool           `json:"stop_rules_processing"`
+	SortOrder           int            `json:"sort_order"`
+	SimpleAction        string         `json:"simple_action"`
+	DiscountAmount      float64        `json:"discount_amount"`
+	DiscountQty         float64        `json:"discount_qty"`
+	DiscountStep        int            `json:"discount_step"`
+	ApplyToShipping     bool           `json:"apply_to_shipping"`
+	TimesUsed           int            `json:"times_used"`
+	IsRss               bool           `json:"is_rss"`
+	CouponType          int            `json:"coupon_type"`
+	UseAutoGeneration   bool           `json:"use_auto_generation"`
+	UsesPerCoupon       int            `json:"uses_per_coupon"`
+	UsesPerCustomer     int            `json:"uses_per_customer"`
+	Exclusive           bool           `json:"exclusive,omitempty"`          // Applies only on its own, never together with other rules
+	PriorityGroup       string         `json:"priority_group,omitempty"`     // At most one rule of a non-empty group applies to a cart
+	WebsiteIDs          []int          `json:"website_ids,omitempty"`        // Websites the rule applies to, every website when empty
+	CustomerGroupIDs    []int          `json:"customer_group_ids,omitempty"` // Customer groups the rule applies to, every group when empty
+	StoreLabels         map[int]string `json:"store_labels,omitempty"`       // Label shown to shoppers by store view ID, with 0 as the default
+	Experiment          *Experiment    `json:"experiment,omitempty"`         // Buckets customers into variants, nil for no experiment
+	Variants            []string       `json:"variants,omitempty"`           // Experiment variants the rule applies to, every variant when empty
+}
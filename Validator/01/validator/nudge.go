@@ -0,0 +1,344 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HintKind says what a shopper has to change in the cart.
+type HintKind string
+
+// Hint kinds
+const (
+	HintSubtotal  HintKind = "subtotal"   // Change the subtotal by Amount
+	HintQuantity  HintKind = "quantity"   // Change the number of matching items by Quantity
+	HintItemTotal HintKind = "item_total" // Change the row total of matching items by Amount
+	HintAddItem   HintKind = "add_item"   // Add Quantity of an item matching the hint
+)
+
+// Hint is one change that brings a cart closer to a rule, e.g. "add $12.50"
+// or "add one more item from category 7". Items are described by SKUs and
+// CategoryIDs, one of which they must have, and by the full item Conditions.
+// Negative amounts and quantities mean removing from the cart.
+type Hint struct {
+	Kind        HintKind    `json:"kind"`
+	Path        string      `json:"path"`
+	Amount      float64     `json:"amount,omitempty"`
+	Quantity    int         `json:"quantity,omitempty"`
+	SKUs        []string    `json:"skus,omitempty"`
+	CategoryIDs []int       `json:"category_ids,omitempty"`
+	Conditions  []Condition `json:"conditions,omitempty"`
+}
+
+// Nudge is what a cart lacks to satisfy a rule.
+type Nudge struct {
+	Matched bool `json:"matched"` // The cart already satisfies the rule
+	// Reachable is false when the rule depends on something the shopper cannot
+	// change, such as the customer group or shipping country.
+	Reachable bool   `json:"reachable"`
+	Hints     []Hint `json:"hints,omitempty"`
+}
+
+// NudgeRule returns the changes that make the cart satisfy the rule.
+func (cv *ConditionValidator) NudgeRule(rule Rule, cart Cart) (*Nudge, error) {
+	matched, err := cv.ValidateRule(rule, cart)
+	if err != nil {
+		return nil, err
+	}
+	if matched {
+		return &Nudge{Matched: true, Reachable: true}, nil
+	}
+	if !cv.ruleApplies(rule, cart) {
+		return &Nudge{}, nil
+	}
+	return cv.Nudge(rule.Conditions, cart)
+}
+
+// Nudge returns the smallest set of changes to numeric and set conditions that
+// makes the cart satisfy the condition. Where the condition offers a choice,
+// the alternative with the fewest hints wins. Hints are computed one condition
+// at a time: adding an item for one hint also raises the subtotal of another.
+func (cv *ConditionValidator) Nudge(condition Condition, cart Cart) (*Nudge, error) {
+	if err := cv.CheckLimits(condition); err != nil {
+		return nil, err
+	}
+	matched, err := cv.validate(context.Background(), RootPath, condition, cart)
+	if err != nil {
+		return nil, err
+	}
+	if matched {
+		return &Nudge{Matched: true, Reachable: true}, nil
+	}
+	hints, reachable, err := cv.nudge(RootPath, condition, cart, true)
+	if err != nil || !reachable {
+		return &Nudge{}, err
+	}
+	return &Nudge{Reachable: true, Hints: hints}, nil
+}
+
+// nudge returns the hints that make the condition evaluate to want, and
+// whether the shopper can get there.
+func (cv *ConditionValidator) nudge(path string, condition Condition, cart Cart, want bool) ([]Hint, bool, error) {
+	valid, err := cv.validate(context.Background(), path, condition, cart)
+	if err != nil {
+		return nil, false, err
+	}
+	if valid == want {
+		return nil, true, nil
+	}
+
+	switch condition.Type {
+	case TypeCombine:
+		return cv.nudgeCombine(path, condition, cart, want)
+	case TypeProductFound:
+		if want != isTrueValue(condition.Value) {
+			// Removing items is not something to nudge shoppers towards
+			return nil, false, nil
+		}
+		hint := cv.itemHint(path, condition.Aggregator, condition.Conditions, cart)
+		return []Hint{hint}, true, nil
+	case TypeProduct:
+		if !want {
+			return nil, false, nil
+		}
+		hint := cv.itemHint(path, "all", []Condition{condition}, cart)
+		return []Hint{hint}, true, nil
+	case TypeSubselect:
+		return cv.nudgeSubselect(path, condition, cart, want)
+	case TypeAddress:
+		return cv.nudgeAddress(path, condition, cart, want)
+	default:
+		return nil, false, nil
+	}
+}
+
+// nudgeCombine needs every subcondition when it is effectively "all", and the
+// cheapest one when it is effectively "any".
+func (cv *ConditionValidator) nudgeCombine(path string, condition Condition, cart Cart, want bool) ([]Hint, bool, error) {
+	expected := cv.isTrue(condition)
+	target := expected == want
+	var every bool
+	switch condition.Aggregator {
+	case "all", "":
+		every = want
+	case "any":
+		every = !want
+	default:
+		return nil, false, fmt.Errorf("unknown aggregator: %s", condition.Aggregator)
+	}
+
+	var result []Hint
+	found := false
+	for i, subCondition := range condition.Conditions {
+		hints, reachable, err := cv.nudge(ChildPath(path, i), subCondition, cart, target)
+		if err != nil {
+			return nil, false, err
+		}
+		if every {
+			if !reachable {
+				return nil, false, nil
+			}
+			result = append(result, hints...)
+		} else if reachable && (!found || len(hints) < len(result)) {
+			result, found = hints, true
+		}
+	}
+	return result, every || found, nil
+}
+
+// nudgeSubselect changes the total of the matching items to the nearest value that satisfies the comparison.
+func (cv *ConditionValidator) nudgeSubselect(path string, condition Condition, cart Cart, want bool) ([]Hint, bool, error) {
+	attribute := strings.ToLower(condition.Attribute)
+	var total float64
+	for _, item := range cart.Items {
+		valid, err := cv.validateItemConditions(context.Background(), path, condition, item)
+		if err != nil {
+			return nil, false, err
+		}
+		if valid {
+			value, err := cv.itemNumericAttribute(item, attribute)
+			if err != nil {
+				return nil, false, err
+			}
+			total += value
+		}
+	}
+
+	skus, categoryIDs, _ := cv.itemRequirements(condition.Aggregator, condition.Conditions)
+	hint := Hint{Path: path, SKUs: skus, CategoryIDs: categoryIDs, Conditions: condition.Conditions}
+	switch attribute {
+	case "qty", "quantity", "quote_item_qty":
+		delta, ok := cv.nudgeDelta(total, condition, want, 1)
+		if !ok {
+			return nil, false, nil
+		}
+		hint.Kind, hint.Quantity = HintQuantity, int(delta)
+	default:
+		delta, ok := cv.nudgeDelta(total, condition, want, 0.01)
+		if !ok {
+			return nil, false, nil
+		}
+		hint.Kind, hint.Amount = HintItemTotal, delta
+	}
+	return []Hint{hint}, true, nil
+}
+
+// nudgeAddress changes the subtotal or item count shipped to the address.
+// Fields of the shipping or billing address, including those reached along
+// an attribute path, are not the shopper's to change for a discount.
+func (cv *ConditionValidator) nudgeAddress(path string, condition Condition, cart Cart, want bool) ([]Hint, bool, error) {
+	billing, attribute := splitAddressAttribute(condition.Attribute)
+	if isAttributePath(attribute) {
+		return nil, false, nil
+	}
+	address := cart.ShippingAddress
+	if billing {
+		address = cart.BillingAddress
+	}
+	value, err := cv.getQuoteAddressAttribute(cart, address, attribute)
+	if err != nil {
+		return nil, false, err
+	}
+	current, err := cv.toFloat64(value)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	switch strings.ToLower(attribute) {
+	case "base_subtotal", "subtotal":
+		delta, ok := cv.nudgeDelta(current, condition, want, 0.01)
+		if !ok {
+			return nil, false, nil
+		}
+		return []Hint{{Kind: HintSubtotal, Path: path, Amount: delta}}, true, nil
+	case "total_qty":
+		delta, ok := cv.nudgeDelta(current, condition, want, 1)
+		if !ok {
+			return nil, false, nil
+		}
+		return []Hint{{Kind: HintQuantity, Path: path, Quantity: int(delta)}}, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// nudgeDelta returns the smallest change to current, in multiples of step,
+// for which the numeric comparison gives want.
+func (cv *ConditionValidator) nudgeDelta(current float64, condition Condition, want bool, step float64) (float64, bool) {
+	value, err := cv.toFloat64(condition.Value)
+	if err != nil {
+		return 0, false
+	}
+	operator := condition.Operator
+	if !want {
+		operator = negatedOperators[operator]
+	}
+
+	var target float64
+	switch operator {
+	case "==", ">=", "<=":
+		target = value
+	case ">":
+		target = math.Floor(value/step)*step + step
+	case "<":
+		target = math.Ceil(value/step)*step - step
+	case "!=":
+		target = value + step
+	default:
+		return 0, false
+	}
+	if step == 1 {
+		if operator == ">=" || operator == "==" {
+			target = math.Ceil(target)
+		} else if operator == "<=" {
+			target = math.Floor(target)
+		}
+	}
+	delta := math.Round((target-current)*100) / 100
+	if delta < 0 && current+delta < 0 {
+		return 0, false
+	}
+	return delta, true
+}
+
+// itemHint returns the cheapest way to get an item matching the conditions into
+// the cart: more of an item that only lacks quantity, or a new item.
+func (cv *ConditionValidator) itemHint(path, aggregator string, conditions []Condition, cart Cart) Hint {
+	filter := Condition{Type: TypeProductCombine, Aggregator: aggregator, Value: "1", Conditions: conditions}
+	skus, categoryIDs, quantity := cv.itemRequirements(aggregator, conditions)
+
+	var best *Hint
+	for _, item := range cart.Items {
+		if item.Quantity >= quantity {
+			continue
+		}
+		candidate := item
+		candidate.Quantity = quantity
+		if valid, err := cv.validateItemConditions(context.Background(), path, filter, candidate); err != nil || !valid {
+			continue
+		}
+		if delta := quantity - item.Quantity; best == nil || delta < best.Quantity {
+			best = &Hint{Kind: HintQuantity, Path: path, Quantity: delta, SKUs: []string{item.SKU}, Conditions: conditions}
+		}
+	}
+	if best != nil {
+		return *best
+	}
+	return Hint{Kind: HintAddItem, Path: path, Quantity: quantity, SKUs: skus, CategoryIDs: categoryIDs, Conditions: conditions}
+}
+
+// itemRequirements reads the SKUs, categories and minimum quantity an item must
+// have from item conditions. With "any", only the first condition is used.
+func (cv *ConditionValidator) itemRequirements(aggregator string, conditions []Condition) ([]string, []int, int) {
+	if aggregator == "any" && len(conditions) > 0 {
+		conditions = conditions[:1]
+	}
+
+	var skus []string
+	var categoryIDs []int
+	quantity := 1
+	for _, condition := range conditions {
+		if condition.Type != TypeProduct {
+			continue
+		}
+		values, ok := condition.Value.([]interface{})
+		if !ok {
+			values = []interface{}{condition.Value}
+		}
+		switch strings.ToLower(condition.Attribute) {
+		case "sku":
+			if condition.Operator == "==" || condition.Operator == "()" {
+				for _, value := range values {
+					skus = append(skus, fmt.Sprint(value))
+				}
+			}
+		case "category_ids":
+			if condition.Operator == "()" {
+				for _, value := range values {
+					if id, err := strconv.Atoi(fmt.Sprint(value)); err == nil {
+						categoryIDs = append(categoryIDs, id)
+					}
+				}
+			}
+		case "qty", "quantity", "quote_item_qty":
+			value, err := cv.toFloat64(condition.Value)
+			if err != nil {
+				continue
+			}
+			minimum := 0
+			switch condition.Operator {
+			case ">=", "==":
+				minimum = int(math.Ceil(value))
+			case ">":
+				minimum = int(math.Floor(value)) + 1
+			}
+			if minimum > quantity {
+				quantity = minimum
+			}
+		}
+	}
+	return skus, categoryIDs, quantity
+}
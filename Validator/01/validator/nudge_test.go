@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNudge(t *testing.T) {
+	subtotal := Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"}
+	shoes := Condition{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"7"}}
+	twoOfSKU := []Condition{
+		{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "1012096"},
+		{Type: TypeProduct, Attribute: "qty", Operator: ">=", Value: "2"},
+	}
+	cart := Cart{
+		Subtotal: 87.5,
+		Items: []Item{
+			{SKU: "1012096", Quantity: 1, Price: 37.5, CategoryIDs: []int{3}},
+			{SKU: "SHOE-1", Quantity: 1, Price: 50, CategoryIDs: []int{7}},
+		},
+		Customer: Customer{GroupID: 1},
+	}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      *Nudge
+	}{
+		{
+			name:      "Already matched",
+			condition: Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">", Value: "50"},
+			want:      &Nudge{Matched: true, Reachable: true},
+		},
+		{
+			name:      "Subtotal missing",
+			condition: Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{subtotal}},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintSubtotal, Path: "$.conditions[0]", Amount: 12.5},
+			}},
+		},
+		{
+			name: "One more item from a category",
+			condition: Condition{Type: TypeSubselect, Attribute: "qty", Operator: ">", Value: "1", Aggregator: "all",
+				Conditions: []Condition{shoes}},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintQuantity, Path: "$", Quantity: 1, CategoryIDs: []int{7}, Conditions: []Condition{shoes}},
+			}},
+		},
+		{
+			name: "More of an item in the cart",
+			condition: Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: twoOfSKU},
+				subtotal,
+			}},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintQuantity, Path: "$.conditions[0]", Quantity: 1, SKUs: []string{"1012096"}, Conditions: twoOfSKU},
+				{Kind: HintSubtotal, Path: "$.conditions[1]", Amount: 12.5},
+			}},
+		},
+		{
+			name: "Missing product",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "sku", Operator: "()", Value: []interface{}{"BAG-1", "BAG-2"}},
+			}},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintAddItem, Path: "$", Quantity: 1, SKUs: []string{"BAG-1", "BAG-2"}, Conditions: []Condition{
+					{Type: TypeProduct, Attribute: "sku", Operator: "()", Value: []interface{}{"BAG-1", "BAG-2"}},
+				}},
+			}},
+		},
+		{
+			name: "Cheapest alternative",
+			condition: Condition{Type: TypeCombine, Aggregator: "any", Value: "1", Conditions: []Condition{
+				{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"},
+				{Type: TypeAddress, Attribute: "total_qty", Operator: ">=", Value: "5"},
+			}},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintQuantity, Path: "$.conditions[1]", Quantity: 3},
+			}},
+		},
+		{
+			name:      "Customer group cannot be changed",
+			condition: Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"},
+			want:      &Nudge{},
+		},
+		{
+			name:      "Billing country cannot be changed",
+			condition: Condition{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"},
+			want:      &Nudge{},
+		},
+		{
+			name:      "Address path cannot be changed",
+			condition: Condition{Type: TypeAddress, Attribute: "billing_address.street[*]", Operator: "{}", Value: "Main"},
+			want:      &Nudge{},
+		},
+		{
+			name:      "Billing address subtotal",
+			condition: Condition{Type: TypeAddress, Attribute: "billing_address.base_subtotal", Operator: ">=", Value: "100"},
+			want: &Nudge{Reachable: true, Hints: []Hint{
+				{Kind: HintSubtotal, Path: "$", Amount: 12.5},
+			}},
+		},
+	}
+
+	cv := NewConditionValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cv.Nudge(tt.condition, cart)
+			if err != nil {
+				t.Fatalf("Nudge() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Nudge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
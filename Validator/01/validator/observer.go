@@ -0,0 +1,112 @@
+package validator
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RootPath is the JSON path of the root condition of a tree.
+const RootPath = "$"
+
+// ChildPath returns the JSON path of the i-th subcondition of the condition at path.
+func ChildPath(path string, i int) string {
+	return path + ".conditions[" + strconv.Itoa(i) + "]"
+}
+
+// ItemPath returns the position of the i-th cart item, as recorded in NodeEvent.ItemPath.
+func ItemPath(i int) string {
+	return "items[" + strconv.Itoa(i) + "]"
+}
+
+// ChildItemPath returns the position of the i-th child of the item at path.
+func ChildItemPath(path string, i int) string {
+	return path + ".children[" + strconv.Itoa(i) + "]"
+}
+
+// NodeEvent describes a condition being evaluated.
+type NodeEvent struct {
+	Path      string    // JSON path of the condition within the evaluated tree
+	Condition Condition // The condition itself
+	Item      *Item     // The item being checked, for item-level conditions
+	ItemPath  string    // Position of Item in the cart, such as items[1]; empty for items passed to ValidateItem
+}
+
+// Observer is notified when the validator enters and leaves each condition.
+// EnterNode may return a derived context, which is passed to the evaluation
+// of the subconditions and to the matching ExitNode call. Observers must be
+// safe for concurrent use when the validator is shared between goroutines.
+type Observer interface {
+	EnterNode(ctx context.Context, event NodeEvent) context.Context
+	ExitNode(ctx context.Context, event NodeEvent, result bool, err error, duration time.Duration)
+}
+
+// multiObserver forwards events to several observers.
+type multiObserver []Observer
+
+// MultiObserver returns an observer that notifies each of the given observers in turn.
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+func (m multiObserver) EnterNode(ctx context.Context, event NodeEvent) context.Context {
+	for _, observer := range m {
+		ctx = observer.EnterNode(ctx, event)
+	}
+	return ctx
+}
+
+func (m multiObserver) ExitNode(ctx context.Context, event NodeEvent, result bool, err error, duration time.Duration) {
+	for i := len(m) - 1; i >= 0; i-- {
+		m[i].ExitNode(ctx, event, result, err, duration)
+	}
+}
+
+// SlogObserver logs every evaluated condition with its result and duration.
+type SlogObserver struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewSlogObserver creates an observer logging to logger at the given level.
+func NewSlogObserver(logger *slog.Logger, level slog.Level) *SlogObserver {
+	return &SlogObserver{logger: logger, level: level}
+}
+
+// EnterNode does not log; each condition is logged once, when it completes.
+func (o *SlogObserver) EnterNode(ctx context.Context, event NodeEvent) context.Context {
+	return ctx
+}
+
+// ExitNode logs the evaluated condition.
+func (o *SlogObserver) ExitNode(ctx context.Context, event NodeEvent, result bool, err error, duration time.Duration) {
+	if !o.logger.Enabled(ctx, o.level) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("type", shortType(event.Condition.Type)),
+		slog.String("path", event.Path),
+		slog.Bool("result", result),
+		slog.Duration("duration", duration),
+	}
+	if event.Condition.Attribute != "" {
+		attrs = append(attrs, slog.String("attribute", event.Condition.Attribute), slog.String("operator", event.Condition.Operator))
+	}
+	if event.Item != nil {
+		attrs = append(attrs, slog.String("sku", event.Item.SKU))
+		if event.ItemPath != "" {
+			attrs = append(attrs, slog.String("item", event.ItemPath))
+		}
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	o.logger.LogAttrs(ctx, o.level, "condition evaluated", attrs...)
+}
+
+// shortType strips the Magento namespace from a condition type, e.g. "Product\Subselect".
+func shortType(conditionType string) string {
+	return strings.TrimPrefix(conditionType, "Magento\\SalesRule\\Model\\Rule\\Condition\\")
+}
@@ -0,0 +1,70 @@
+package validator
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestConditionValidatorObservers(t *testing.T) {
+	condition := Condition{Type: TypeCombine, Aggregator: "all", Conditions: []Condition{
+		{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"},
+		{Type: TypeProductFound, Value: "1", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SKU001"},
+		}},
+	}}
+	cart := Cart{
+		Items:    []Item{{SKU: "SKU001", Quantity: 1}},
+		Customer: Customer{GroupID: 2},
+	}
+
+	t.Run("Span recorder nests spans like the condition tree", func(t *testing.T) {
+		exporter := NewInMemoryExporter()
+		validator := NewConditionValidator().WithObserver(NewSpanRecorder(exporter))
+		valid, err := validator.Validate(condition, cart)
+		if err != nil || !valid {
+			t.Fatalf("Validator.Validate() = %v, %v, want true", valid, err)
+		}
+
+		spans := exporter.Spans()
+		if len(spans) != 4 {
+			t.Fatalf("got %d spans, want 4", len(spans))
+		}
+		byPath := make(map[string]Span)
+		for _, span := range spans {
+			byPath[span.Attributes["condition.path"].(string)] = span
+		}
+		root := byPath["$"]
+		item := byPath["$.conditions[1].conditions[0]"]
+		if root.ParentID != 0 || byPath["$.conditions[1]"].ParentID != root.SpanID {
+			t.Errorf("Found span parent = %d, want root span %d", byPath["$.conditions[1]"].ParentID, root.SpanID)
+		}
+		if item.ParentID != byPath["$.conditions[1]"].SpanID || item.TraceID != root.TraceID {
+			t.Errorf("item span is not nested under the Found span: %+v", item)
+		}
+		if item.Attributes["item.sku"] != "SKU001" || item.Attributes["condition.result"] != true {
+			t.Errorf("item span attributes = %v", item.Attributes)
+		}
+		if spans[len(spans)-1].SpanID != root.SpanID {
+			t.Errorf("root span should end last")
+		}
+	})
+
+	t.Run("Slog observer logs each condition", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		validator := NewConditionValidator().WithObserver(NewSlogObserver(logger, slog.LevelInfo))
+		if _, err := validator.Validate(condition, cart); err != nil {
+			t.Fatalf("Validator.Validate() error = %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("got %d log lines, want 4:\n%s", len(lines), buf.String())
+		}
+		if !strings.Contains(lines[0], "type=Customer") || !strings.Contains(lines[0], "result=true") {
+			t.Errorf("unexpected first log line: %s", lines[0])
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cartAddressAttributes are Address condition attributes computed from the cart
+// rather than read from the address, so partial evaluation cannot decide them.
+var cartAddressAttributes = map[string]bool{
+	"base_subtotal": true,
+	"subtotal":      true,
+	"total_qty":     true,
+	"weight":        true,
+}
+
+// Residual is the outcome of partially evaluating a condition. When Decided is
+// true the condition has the same outcome, Matched, for every cart; otherwise
+// Condition is the simplified tree left to evaluate once the cart is known.
+// A condition decided to match leaves an empty Combine, which every cart matches.
+type Residual struct {
+	Decided   bool
+	Matched   bool
+	Condition Condition
+}
+
+// decided returns the residual of a condition with the same outcome for every cart.
+func decided(matched bool) Residual {
+	residual := Residual{Decided: true, Matched: matched}
+	if matched {
+		residual.Condition = Condition{Type: TypeCombine, Aggregator: "all", Value: "1"}
+	}
+	return residual
+}
+
+// EligibleRule is a rule that may still apply to a customer, with the part of
+// its conditions that depends on the cart.
+type EligibleRule struct {
+	Rule     Rule
+	Residual Condition
+	// Unconditional is true when the rule applies to every cart of the customer.
+	Unconditional bool
+}
+
+// PartialEvaluator specialises conditions for a known customer, and optionally
+// a known shipping address, before the cart is known.
+type PartialEvaluator struct {
+	cv       *ConditionValidator
+	customer Customer
+	address  *Address
+}
+
+// NewPartialEvaluator creates a PartialEvaluator for the customer. address may
+// be nil when the shipping address is not known yet; conditions on the billing
+// address are always left to the cart.
+func NewPartialEvaluator(validator *ConditionValidator, customer Customer, address *Address) *PartialEvaluator {
+	return &PartialEvaluator{cv: validator, customer: customer, address: address}
+}
+
+// Specialize evaluates the Customer and segment conditions, and the Address
+// conditions when an address is known, and simplifies the Combine conditions
+// they decide.
+// Evaluating the residual against a cart for the same customer and address
+// gives the same result as evaluating the original condition.
+func (p *PartialEvaluator) Specialize(condition Condition) (Residual, error) {
+	if err := p.cv.CheckLimits(condition); err != nil {
+		return Residual{}, err
+	}
+	return p.specialize(RootPath, condition)
+}
+
+// EligibleRules returns the active rules that may apply to the customer, in
+// the order given, dropping those limited to other customer groups and those
+// its customer and address conditions rule out.
+func (p *PartialEvaluator) EligibleRules(rules []Rule) ([]EligibleRule, error) {
+	var eligible []EligibleRule
+	for _, rule := range rules {
+		if err := rule.checkExperiment(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", rule.ID, err)
+		}
+		if !rule.IsActive || !scopeIncludes(rule.CustomerGroupIDs, p.customer.GroupID) || !rule.inVariant(p.customer) {
+			continue
+		}
+		residual, err := p.Specialize(rule.Conditions)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", rule.ID, err)
+		}
+		if residual.Decided && !residual.Matched {
+			continue
+		}
+		eligible = append(eligible, EligibleRule{Rule: rule, Residual: residual.Condition, Unconditional: residual.Decided})
+	}
+	return eligible, nil
+}
+
+// specialize partially evaluates a cart-level condition at the given path.
+func (p *PartialEvaluator) specialize(path string, condition Condition) (Residual, error) {
+	switch condition.Type {
+	case TypeCombine:
+		return p.specializeCombine(path, condition)
+	case TypeCustomer:
+		valid, err := p.cv.validateCustomer(condition, Cart{Customer: p.customer})
+		if err != nil {
+			return Residual{}, fmt.Errorf("%s: %v", path, err)
+		}
+		return decided(valid), nil
+	case TypeSegment:
+		valid, err := p.cv.validateSegment(condition, Cart{Customer: p.customer})
+		if err != nil {
+			return Residual{}, fmt.Errorf("%s: %v", path, err)
+		}
+		return decided(valid), nil
+	case TypeAddress:
+		billing, attribute := splitAddressAttribute(condition.Attribute)
+		if p.address == nil || billing || cartAddressAttributes[strings.ToLower(attribute)] {
+			return Residual{Condition: condition}, nil
+		}
+		valid, err := p.cv.validateAddress(condition, Cart{ShippingAddress: *p.address})
+		if err != nil {
+			return Residual{}, fmt.Errorf("%s: %v", path, err)
+		}
+		return decided(valid), nil
+	default:
+		// Product, Found and Subselect conditions depend on the items
+		return Residual{Condition: condition}, nil
+	}
+}
+
+// specializeCombine drops the decided subconditions that cannot change the
+// outcome of a Combine and decides the Combine when one settles it.
+func (p *PartialEvaluator) specializeCombine(path string, condition Condition) (Residual, error) {
+	if len(condition.Conditions) == 0 {
+		return decided(true), nil
+	}
+	expected := isTrueValue(condition.Value)
+	var anyOf bool
+	switch condition.Aggregator {
+	case "all", "":
+	case "any":
+		anyOf = true
+	default:
+		return Residual{}, fmt.Errorf("%s: unknown aggregator: %s", path, condition.Aggregator)
+	}
+
+	var remaining []Condition
+	for i, subCondition := range condition.Conditions {
+		residual, err := p.specialize(ChildPath(path, i), subCondition)
+		if err != nil {
+			return Residual{}, err
+		}
+		if !residual.Decided {
+			remaining = append(remaining, residual.Condition)
+			continue
+		}
+		// With "all" one subcondition missing expected fails the Combine, with "any" one meeting it passes
+		if (residual.Matched == expected) == anyOf {
+			return decided(anyOf), nil
+		}
+	}
+	if len(remaining) == 0 {
+		// Every subcondition was decided without settling the Combine
+		return decided(!anyOf), nil
+	}
+	condition.Conditions = remaining
+	return Residual{Condition: condition}, nil
+}
@@ -0,0 +1,151 @@
+package validator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPartialEvaluator(t *testing.T) {
+	customer := Customer{ID: 7, GroupID: 1, TotalSpent: 1500, Email: "ann@example.com"}
+	address := &Address{Country: "US", City: "Austin"}
+
+	wholesale := Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "2"}
+	general := Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "==", Value: "1"}
+	bigSpender := Condition{Type: TypeCustomer, Attribute: "total_spent", Operator: ">=", Value: "1000"}
+	subtotal := Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"}
+	us := Condition{Type: TypeAddress, Attribute: "country_id", Operator: "==", Value: "US"}
+	shoes := Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"7"}},
+	}}
+	combine := func(aggregator, value string, conditions ...Condition) Condition {
+		return Condition{Type: TypeCombine, Aggregator: aggregator, Value: value, Conditions: conditions}
+	}
+	always := combine("all", "1")
+
+	tests := []struct {
+		name      string
+		condition Condition
+		address   *Address
+		want      Residual
+	}{
+		{
+			name:      "Customer conditions are dropped from all",
+			condition: combine("all", "1", general, bigSpender, subtotal),
+			want:      Residual{Condition: combine("all", "1", subtotal)},
+		},
+		{
+			name:      "Failing customer condition decides all",
+			condition: combine("all", "1", wholesale, subtotal),
+			want:      Residual{Decided: true},
+		},
+		{
+			name:      "Matching customer condition decides any",
+			condition: combine("any", "1", subtotal, bigSpender),
+			want:      Residual{Decided: true, Matched: true, Condition: always},
+		},
+		{
+			name:      "Failing customer conditions are dropped from any",
+			condition: combine("any", "1", wholesale, shoes),
+			want:      Residual{Condition: combine("any", "1", shoes)},
+		},
+		{
+			name:      "Any false is decided by a failing condition",
+			condition: combine("any", "0", wholesale, shoes),
+			want:      Residual{Decided: true, Matched: true, Condition: always},
+		},
+		{
+			name:      "All false fails on a matching condition",
+			condition: combine("all", "0", general, shoes),
+			want:      Residual{Decided: true},
+		},
+		{
+			name:      "Nested combine",
+			condition: combine("all", "1", combine("any", "1", wholesale, shoes), combine("all", "1", bigSpender)),
+			want:      Residual{Condition: combine("all", "1", combine("any", "1", shoes))},
+		},
+		{
+			name:      "Address is kept without a known address",
+			condition: combine("all", "1", us, subtotal),
+			want:      Residual{Condition: combine("all", "1", us, subtotal)},
+		},
+		{
+			name:      "Known address decides address attributes but not totals",
+			condition: combine("all", "1", us, subtotal),
+			address:   address,
+			want:      Residual{Condition: combine("all", "1", subtotal)},
+		},
+		{
+			name:      "Every subcondition of any failing",
+			condition: combine("any", "1", wholesale, combine("all", "0", us)),
+			address:   address,
+			want:      Residual{Decided: true},
+		},
+	}
+
+	cv := NewConditionValidator()
+	carts := []Cart{
+		{Subtotal: 50},
+		{Subtotal: 150, Items: []Item{{SKU: "SHOE-1", Quantity: 1, Price: 150, CategoryIDs: []int{7}}}},
+		{Subtotal: 20, Items: []Item{{SKU: "SHOE-2", Quantity: 1, Price: 20, CategoryIDs: []int{7}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPartialEvaluator(cv, customer, tt.address).Specialize(tt.condition)
+			if err != nil {
+				t.Fatalf("Specialize() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Specialize() = %+v, want %+v", got, tt.want)
+			}
+
+			// The residual must agree with the original condition on every cart of the customer
+			for i, cart := range carts {
+				cart.Customer = customer
+				if tt.address != nil {
+					cart.ShippingAddress = *tt.address
+				}
+				want, err := cv.Validate(tt.condition, cart)
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				matched := got.Matched
+				if !got.Decided {
+					if matched, err = cv.Validate(got.Condition, cart); err != nil {
+						t.Fatalf("Validate() residual error = %v", err)
+					}
+				}
+				if matched != want {
+					t.Errorf("cart %d: residual = %v, original = %v", i, matched, want)
+				}
+			}
+		})
+	}
+
+	t.Run("Eligible rules", func(t *testing.T) {
+		rules := []Rule{
+			{ID: 1, IsActive: true, Conditions: combine("all", "1", wholesale, subtotal)},
+			{ID: 2, IsActive: true, Conditions: combine("all", "1", general, subtotal)},
+			{ID: 3, IsActive: false, Conditions: combine("all", "1", general)},
+			{ID: 4, IsActive: true, Conditions: combine("all", "1", bigSpender)},
+		}
+		got, err := NewPartialEvaluator(cv, customer, nil).EligibleRules(rules)
+		if err != nil {
+			t.Fatalf("EligibleRules() error = %v", err)
+		}
+		want := []EligibleRule{
+			{Rule: rules[1], Residual: combine("all", "1", subtotal)},
+			{Rule: rules[3], Residual: always, Unconditional: true},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("EligibleRules() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Errors carry the path", func(t *testing.T) {
+		_, err := NewPartialEvaluator(cv, customer, nil).Specialize(combine("all", "1", subtotal, Condition{Type: TypeCustomer, Attribute: "shoe_size", Operator: "==", Value: "9"}))
+		if err == nil || !strings.HasPrefix(err.Error(), "$.conditions[1]") {
+			t.Errorf("Specialize() error = %v, want an error at $.conditions[1]", err)
+		}
+	})
+}
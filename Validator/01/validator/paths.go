@@ -0,0 +1,238 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Quantifiers of a list segment in an attribute path
+const (
+	quantifyIndex = "index" // addresses[0]: one element
+	quantifyAny   = "any"   // addresses[*] or addresses[any]: some element matches
+	quantifyAll   = "all"   // addresses[all]: every element matches
+)
+
+// Prefixes of Address condition attributes that select the address to read
+const (
+	shippingAddressPrefix = "shipping_address."
+	billingAddressPrefix  = "billing_address."
+)
+
+// pathSegment is one dotted part of an attribute path, such as addresses[*].
+type pathSegment struct {
+	name       string
+	quantifier string // Empty for a plain field
+	index      int
+}
+
+// isAttributePath reports whether an attribute is a dotted or indexed path
+// rather than a plain attribute code.
+func isAttributePath(attribute string) bool {
+	return strings.ContainsAny(attribute, ".[")
+}
+
+// parseAttributePath parses a path such as addresses[*].country or
+// attributes.color. A list segment takes an index, * or any to match when
+// some element matches, or all to match when every element does.
+func parseAttributePath(attribute string) ([]pathSegment, error) {
+	var segments []pathSegment
+	for _, part := range strings.Split(attribute, ".") {
+		segment := pathSegment{name: part}
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			if !strings.HasSuffix(part, "]") {
+				return nil, fmt.Errorf("invalid attribute path %q: unclosed [", attribute)
+			}
+			segment.name = part[:open]
+			switch selector := strings.ToLower(part[open+1 : len(part)-1]); selector {
+			case "*", "any":
+				segment.quantifier = quantifyAny
+			case "all":
+				segment.quantifier = quantifyAll
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid attribute path %q: %q is not an index, *, any or all", attribute, selector)
+				}
+				segment.quantifier, segment.index = quantifyIndex, index
+			}
+		}
+		if segment.name == "" || strings.ContainsAny(segment.name, "[]") {
+			return nil, fmt.Errorf("invalid attribute path %q: empty or malformed segment", attribute)
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// trimEntityPrefix removes a leading entity name, such as "customer.", from an attribute path.
+func trimEntityPrefix(attribute string, entities ...string) string {
+	for _, entity := range entities {
+		if len(attribute) > len(entity) && strings.EqualFold(attribute[:len(entity)+1], entity+".") {
+			return attribute[len(entity)+1:]
+		}
+	}
+	return attribute
+}
+
+// splitAddressAttribute returns whether an Address condition attribute reads
+// the billing address, and the attribute within the address. Attributes
+// without a shipping_address. or billing_address. prefix read the shipping address.
+func splitAddressAttribute(attribute string) (bool, string) {
+	lower := strings.ToLower(attribute)
+	switch {
+	case strings.HasPrefix(lower, billingAddressPrefix):
+		return true, attribute[len(billingAddressPrefix):]
+	case strings.HasPrefix(lower, shippingAddressPrefix):
+		return false, attribute[len(shippingAddressPrefix):]
+	default:
+		return false, attribute
+	}
+}
+
+// comparePath compares the value at an attribute path below root with the
+// condition value. A missing value only matches null and negated operators.
+func (cv *ConditionValidator) comparePath(root interface{}, attribute, operator string, value interface{}) (bool, error) {
+	segments, err := parseAttributePath(attribute)
+	if err != nil {
+		return false, err
+	}
+	return cv.matchPath(root, segments, func(found interface{}) (bool, error) {
+		if found == nil {
+			switch operator {
+			case "null", "!=", "!{}", "!()", "nlike":
+				return true, nil
+			}
+			return false, nil
+		}
+		return cv.compareValues(found, operator, value)
+	})
+}
+
+// resolvePath returns the value at an attribute path below root. A path with
+// list quantifiers resolves to the list of the values it reaches.
+func (cv *ConditionValidator) resolvePath(root interface{}, attribute string) (interface{}, error) {
+	segments, err := parseAttributePath(attribute)
+	if err != nil {
+		return nil, err
+	}
+	values, err := cv.collectPath(root, segments)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		if segment.quantifier == quantifyAny || segment.quantifier == quantifyAll {
+			return values, nil
+		}
+	}
+	return values[0], nil
+}
+
+// collectPath follows the path segments from value and returns every value
+// they reach, visiting each list element whatever its quantifier.
+func (cv *ConditionValidator) collectPath(value interface{}, segments []pathSegment) ([]interface{}, error) {
+	if len(segments) == 0 {
+		return []interface{}{value}, nil
+	}
+	segment := segments[0]
+	field, err := cv.lookupField(value, segment.name)
+	if err != nil {
+		return nil, err
+	}
+	if segment.quantifier == "" {
+		return cv.collectPath(field, segments[1:])
+	}
+
+	list := reflect.ValueOf(field)
+	if field == nil {
+		list = reflect.ValueOf([]interface{}{})
+	} else if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return nil, fmt.Errorf("%s is not a list", segment.name)
+	}
+	if segment.quantifier == quantifyIndex {
+		var element interface{}
+		if segment.index < list.Len() {
+			element = list.Index(segment.index).Interface()
+		}
+		return cv.collectPath(element, segments[1:])
+	}
+	values := []interface{}{}
+	for i := 0; i < list.Len(); i++ {
+		found, err := cv.collectPath(list.Index(i).Interface(), segments[1:])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, found...)
+	}
+	return values, nil
+}
+
+// matchPath follows the path segments from value and applies match to the
+// values it reaches, combining them as the list quantifiers say.
+func (cv *ConditionValidator) matchPath(value interface{}, segments []pathSegment, match func(interface{}) (bool, error)) (bool, error) {
+	if len(segments) == 0 {
+		return match(value)
+	}
+	segment := segments[0]
+	field, err := cv.lookupField(value, segment.name)
+	if err != nil {
+		return false, err
+	}
+	if segment.quantifier == "" {
+		return cv.matchPath(field, segments[1:], match)
+	}
+
+	list := reflect.ValueOf(field)
+	if field == nil {
+		list = reflect.ValueOf([]interface{}{})
+	} else if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+		return false, fmt.Errorf("%s is not a list", segment.name)
+	}
+	switch segment.quantifier {
+	case quantifyIndex:
+		var element interface{}
+		if segment.index < list.Len() {
+			element = list.Index(segment.index).Interface()
+		}
+		return cv.matchPath(element, segments[1:], match)
+	case quantifyAny:
+		for i := 0; i < list.Len(); i++ {
+			matched, err := cv.matchPath(list.Index(i).Interface(), segments[1:], match)
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	default:
+		for i := 0; i < list.Len(); i++ {
+			matched, err := cv.matchPath(list.Index(i).Interface(), segments[1:], match)
+			if err != nil || !matched {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// lookupField returns a named field of a value met along an attribute path,
+// using the attribute lookups of the cart models. Missing map keys and fields
+// of missing values are nil.
+func (cv *ConditionValidator) lookupField(value interface{}, name string) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case Item:
+		return cv.getItemAttribute(v, name)
+	case Address:
+		return cv.getAddressAttribute(v, name)
+	case Customer:
+		return cv.getCustomerAttribute(v, name)
+	case Cart:
+		return cv.getCartAttribute(v, name)
+	case map[string]interface{}:
+		return v[name], nil
+	default:
+		return cv.getStructField(v, name)
+	}
+}
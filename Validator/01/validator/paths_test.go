@@ -0,0 +1,179 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAttributePaths(t *testing.T) {
+	lastLogin := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	cart := Cart{
+		Subtotal: 120,
+		Items: []Item{
+			{SKU: "TEE", ProductType: "configurable", Quantity: 1, Price: 20, Attributes: map[string]interface{}{"color": "red"},
+				Children: []Item{{SKU: "TEE-RED-M"}, {SKU: "TEE-RED-L"}}},
+			{SKU: "MUG", Quantity: 2, Price: 50},
+		},
+		ShippingAddress: Address{Country: "US", City: "Austin"},
+		BillingAddress:  Address{Country: "DE", City: "Berlin"},
+		Customer: Customer{
+			LastLoginAt: lastLogin,
+			Addresses:   []Address{{Country: "US", City: "Austin"}, {Country: "CA", City: "Toronto"}},
+			Attributes:  map[string]interface{}{"loyalty": map[string]interface{}{"tier": "gold"}},
+		},
+	}
+	customer := func(attribute, operator string, value interface{}) Condition {
+		return Condition{Type: TypeCustomer, Attribute: attribute, Operator: operator, Value: value}
+	}
+	address := func(attribute, operator string, value interface{}) Condition {
+		return Condition{Type: TypeAddress, Attribute: attribute, Operator: operator, Value: value}
+	}
+	found := func(attribute, operator string, value interface{}) Condition {
+		return Condition{Type: TypeProductFound, Value: "1", Aggregator: "all", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: attribute, Operator: operator, Value: value},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+		wantErr   bool
+	}{
+		{"Any address in Canada", customer("customer.addresses[*].country", "==", "CA"), true, false},
+		{"Any address in Mexico", customer("addresses[any].country", "==", "MX"), false, false},
+		{"All addresses in North America", customer("addresses[all].country", "()", []interface{}{"US", "CA"}), true, false},
+		{"All addresses in the US", customer("addresses[all].country", "==", "US"), false, false},
+		{"First address city", customer("addresses[0].city", "==", "Austin"), true, false},
+		{"Missing address is not Austin", customer("addresses[5].city", "!=", "Austin"), true, false},
+		{"Nested custom attribute", customer("attributes.loyalty.tier", "==", "gold"), true, false},
+		{"Snake case field", customer("last_login_at", ">=", "2024-04-01"), true, false},
+		{"Item custom attribute", found("item.attributes.color", "==", "red"), true, false},
+		{"Missing item attribute equals", found("attributes.size", "==", "M"), false, false},
+		{"Missing item attribute differs", found("attributes.size", "!=", "M"), true, false},
+		{"Missing item attribute is null", found("attributes.size", "null", ""), true, false},
+		{"Any child SKU", found("children[*].sku", "==", "TEE-RED-L"), true, false},
+		{"Shipping address by default", address("country_id", "==", "US"), true, false},
+		{"Shipping address prefix", address("shipping_address.city", "==", "Austin"), true, false},
+		{"Billing address", address("billing_address.country_id", "==", "DE"), true, false},
+		{"Billing address subtotal", address("billing_address.base_subtotal", ">=", "100"), true, false},
+		{"City is not a list", customer("addresses[0].city[*]", "==", "A"), false, true},
+		{"Unclosed index", customer("addresses[0.city", "==", "Austin"), false, true},
+		{"Negative index", customer("addresses[-1].city", "==", "Austin"), false, true},
+		{"Empty segment", customer("addresses..city", "==", "Austin"), false, true},
+	}
+	cv := NewConditionValidator()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cv.Validate(tt.condition, cart)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAttributePath(t *testing.T) {
+	got, err := parseAttributePath("addresses[ALL].street[1]")
+	if err != nil {
+		t.Fatalf("parseAttributePath() error = %v", err)
+	}
+	want := []pathSegment{{name: "addresses", quantifier: quantifyAll}, {name: "street", quantifier: quantifyIndex, index: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAttributePath() = %+v, want %+v", got, want)
+	}
+	for _, attribute := range []string{"addresses[x]", "addresses[", "[0]", "a]b", ".city"} {
+		if _, err := parseAttributePath(attribute); err == nil {
+			t.Errorf("parseAttributePath(%q) error = nil, want an error", attribute)
+		}
+	}
+}
+
+func TestLintAttributePaths(t *testing.T) {
+	condition := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeCustomer, Attribute: "addresses[*].country", Operator: "==", Value: "CA"},
+		{Type: TypeCustomer, Attribute: "addresses[x].country", Operator: "==", Value: "CA"},
+		{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"},
+	}}
+	var codes []string
+	for _, diagnostic := range NewLinter().Lint(condition) {
+		codes = append(codes, diagnostic.Path+" "+diagnostic.Code)
+	}
+	want := []string{"$.conditions[1] " + LintUnknownAttribute}
+	if !reflect.DeepEqual(codes, want) {
+		t.Errorf("Lint() = %v, want %v", codes, want)
+	}
+}
+
+func TestResolveAttribute(t *testing.T) {
+	cart := Cart{
+		Subtotal:        60,
+		ShippingAddress: Address{Country: "US"},
+		BillingAddress:  Address{Country: "DE"},
+		Customer:        Customer{GroupID: 2, Addresses: []Address{{City: "Austin"}, {City: "Berlin"}}},
+		Items:           []Item{{SKU: "TEE", Quantity: 2, Price: 20, Attributes: map[string]interface{}{"color": "red"}}},
+	}
+	item := &cart.Items[0]
+	tests := []struct {
+		conditionType string
+		attribute     string
+		item          *Item
+		want          interface{}
+	}{
+		{TypeProduct, "sku", item, "TEE"},
+		{TypeProduct, "row_total", item, 40.0},
+		{TypeProduct, "item.attributes.color", item, "red"},
+		{TypeAddress, "base_subtotal", nil, 60.0},
+		{TypeAddress, "country_id", nil, "US"},
+		{TypeAddress, "billing_address.country_id", nil, "DE"},
+		{TypeCustomer, "group_id", nil, 2},
+		{TypeCustomer, "addresses[1].city", nil, "Berlin"},
+		{TypeCustomer, "addresses[*].city", nil, []interface{}{"Austin", "Berlin"}},
+		{TypeCustomer, "addresses[all].city", nil, []interface{}{"Austin", "Berlin"}},
+		{TypeCustomer, "addresses[2].city", nil, nil},
+	}
+	cv := NewConditionValidator()
+	for _, tt := range tests {
+		got, err := cv.ResolveAttribute(tt.conditionType, tt.attribute, cart, tt.item)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveAttribute(%s, %s) = %#v, %v, want %#v", shortType(tt.conditionType), tt.attribute, got, err, tt.want)
+		}
+	}
+	if _, err := cv.ResolveAttribute(TypeProduct, "sku", cart, nil); err == nil {
+		t.Error("ResolveAttribute() of a product attribute without an item succeeded")
+	}
+	if _, err := cv.ResolveAttribute(TypeCombine, "sku", cart, item); err == nil {
+		t.Error("ResolveAttribute() of a combination succeeded")
+	}
+}
+
+func TestResolveCondition(t *testing.T) {
+	item := Item{SKU: "TEE", ProductType: ProductTypeConfigurable, Quantity: 2, Attributes: map[string]interface{}{"size": "M"},
+		Children: []Item{{SKU: "TEE-RED", Attributes: map[string]interface{}{"color": "red"}}}}
+	cv := NewConditionValidator()
+	tests := []struct {
+		condition Condition
+		want      interface{}
+	}{
+		{condition: Condition{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "TEE"}, want: "TEE"},
+		{condition: Condition{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "TEE-RED"}, want: "TEE-RED"},
+		{condition: Condition{Type: TypeProduct, Attribute: "color", Operator: "==", Value: "red"}, want: "red"},
+		{condition: Condition{Type: TypeProduct, Attribute: "quote_item_qty", Operator: ">=", Value: "3"}, want: 2},
+	}
+	for _, tt := range tests {
+		got, err := cv.ResolveCondition(tt.condition, Cart{Items: []Item{item}}, &item)
+		if err != nil {
+			t.Fatalf("ResolveCondition(%s %v) error = %v", tt.condition.Attribute, tt.condition.Value, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ResolveCondition(%s %v) = %#v, want %#v", tt.condition.Attribute, tt.condition.Value, got, tt.want)
+		}
+	}
+	if got, _ := cv.ResolveAttribute(TypeProduct, "color", Cart{}, &item); got != nil {
+		t.Errorf("ResolveAttribute(color) = %#v, want nil on the parent", got)
+	}
+}
@@ -0,0 +1,415 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// RuleSet is an immutable set of validated rules with their index. Evaluations
+// holding a RuleSet keep using it while a RuleLoader swaps in a newer one.
+type RuleSet struct {
+	Generation int            // Counts the rule sets a loader has made, from 1
+	LoadedAt   time.Time      // When the set was made
+	Rules      []Rule         // By SortOrder, then ID
+	Sources    map[int]string // File each rule was loaded from, by rule ID
+	index      *RuleIndex
+}
+
+// newRuleSet compiles rules into a RuleSet.
+func newRuleSet(generation int, rules []Rule, sources map[int]string) *RuleSet {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].SortOrder != rules[j].SortOrder {
+			return rules[i].SortOrder < rules[j].SortOrder
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return &RuleSet{Generation: generation, LoadedAt: time.Now(), Rules: rules, Sources: sources, index: NewRuleIndex(rules)}
+}
+
+// Match returns the rules of the set that apply to the cart, in set order.
+func (s *RuleSet) Match(ctx context.Context, validator *ConditionValidator, cart Cart) ([]Rule, error) {
+	var matched []Rule
+	for _, i := range s.index.Candidates(cart) {
+		ok, err := validator.ValidateRuleContext(ctx, s.Rules[i], cart)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", s.Rules[i].ID, err)
+		}
+		if ok {
+			matched = append(matched, s.Rules[i])
+		}
+	}
+	return matched, nil
+}
+
+// RuleFileError reports a rule file that was rejected. The rules the file held
+// before, if any, stay in the active set.
+type RuleFileError struct {
+	Path        string
+	Err         error        // Why the file could not be loaded, nil for lint errors alone
+	Diagnostics []Diagnostic // Lint diagnostics, paths prefixed with the rule
+}
+
+func (e *RuleFileError) Error() string {
+	var problems []string
+	if e.Err != nil {
+		problems = append(problems, e.Err.Error())
+	}
+	for _, diagnostic := range e.Diagnostics {
+		if diagnostic.Severity == SeverityError {
+			problems = append(problems, diagnostic.String())
+		}
+	}
+	return fmt.Sprintf("%s: %s", e.Path, strings.Join(problems, "; "))
+}
+
+// ReloadResult is the outcome of a RuleLoader reload.
+type ReloadResult struct {
+	Set      *RuleSet         // The active set after the reload
+	Swapped  bool             // A new set was made
+	Loaded   []string         // Files loaded or changed
+	Removed  []string         // Files deleted, whose rules were dropped
+	Rejected []*RuleFileError // Files rejected, still active in their previous good version
+}
+
+// loadedFile is the last good content of a rule file.
+type loadedFile struct {
+	sum   [sha256.Size]byte
+	rules []Rule
+}
+
+// clashingFile is the content of a rule file rejected because another file
+// already defines one of its rule IDs.
+type clashingFile struct {
+	file loadedFile
+	with string // The file defining the rule ID
+}
+
+// RuleLoader loads the rules in a directory of JSON files and reloads them
+// as the files change. A file holds a single rule, or the version history a
+// DirectoryStore writes, of which the latest version is used unless it is a
+// deletion. Every rule is linted and checked against the validator limits
+// before it goes live; a file with a broken rule is rejected as a whole and
+// its previous good version stays live.
+type RuleLoader struct {
+	cv       *ConditionValidator
+	dir      string
+	debounce time.Duration
+	onReload func(ReloadResult)
+
+	current atomic.Pointer[RuleSet]
+	mu      sync.Mutex // Serialises reloads
+	linter  *Linter
+	files   map[string]loadedFile
+	sums    map[string][sha256.Size]byte // Content of every file seen, good or not
+	clashes map[string]clashingFile      // Files to retry once the file they clash with changes
+}
+
+// NewRuleLoader creates a RuleLoader for the rule files in dir, validating
+// rules with the validator. Call Load or Watch to load the rules.
+func NewRuleLoader(validator *ConditionValidator, dir string) *RuleLoader {
+	l := &RuleLoader{
+		cv:       validator,
+		dir:      dir,
+		debounce: 100 * time.Millisecond,
+		linter:   NewLinter(),
+		files:    make(map[string]loadedFile),
+		sums:     make(map[string][sha256.Size]byte),
+		clashes:  make(map[string]clashingFile),
+	}
+	l.current.Store(newRuleSet(0, nil, map[int]string{}))
+	return l
+}
+
+// WithDebounce sets how long Watch waits for changes to settle before reloading.
+func (l *RuleLoader) WithDebounce(d time.Duration) *RuleLoader {
+	l.debounce = d
+	return l
+}
+
+// WithReloadHandler makes Watch call handler after every reload, from the
+// watching goroutine.
+func (l *RuleLoader) WithReloadHandler(handler func(ReloadResult)) *RuleLoader {
+	l.onReload = handler
+	return l
+}
+
+// Current returns the active rule set. It is safe to call concurrently with
+// reloads, and the returned set never changes.
+func (l *RuleLoader) Current() *RuleSet {
+	return l.current.Load()
+}
+
+// Load reads the directory and swaps in a new rule set if any file was added,
+// changed or removed. Rejected files are reported in the result; the error is
+// only for a directory that cannot be read.
+func (l *RuleLoader) Load() (ReloadResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return ReloadResult{Set: l.Current()}, fmt.Errorf("failed to read rule directory: %v", err)
+	}
+	var result ReloadResult
+	present := make(map[string]bool)
+	replaced := make(map[string]loadedFile)
+	for _, entry := range entries {
+		if entry.IsDir() || !isRuleFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(l.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue // Removed while reading the directory
+		}
+		present[path] = true
+		if err != nil {
+			result.Rejected = append(result.Rejected, &RuleFileError{Path: path, Err: err})
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if previous, ok := l.sums[path]; ok && previous == sum {
+			continue
+		}
+		l.sums[path] = sum
+		delete(l.clashes, path)
+		rules, rejection := l.parse(path, data)
+		if rejection != nil {
+			result.Rejected = append(result.Rejected, rejection)
+			continue
+		}
+		if previous, ok := l.files[path]; ok {
+			replaced[path] = previous
+		}
+		l.files[path] = loadedFile{sum: sum, rules: rules}
+		result.Loaded = append(result.Loaded, path)
+	}
+	for path := range l.sums {
+		if !present[path] {
+			delete(l.sums, path)
+			delete(l.clashes, path)
+			if _, ok := l.files[path]; ok {
+				delete(l.files, path)
+				result.Removed = append(result.Removed, path)
+			}
+		}
+	}
+	sort.Strings(result.Removed)
+
+	changed := make(map[string]bool)
+	for _, path := range append(result.Loaded, result.Removed...) {
+		changed[path] = true
+	}
+	for path, clash := range l.clashes {
+		if changed[clash.with] {
+			// The file it clashed with changed, so try it again
+			if previous, ok := l.files[path]; ok {
+				replaced[path] = previous
+			}
+			l.files[path] = clash.file
+			delete(l.clashes, path)
+			result.Loaded = append(result.Loaded, path)
+		}
+	}
+
+	if len(result.Loaded) == 0 && len(result.Removed) == 0 {
+		result.Set = l.Current()
+		return result, nil
+	}
+	set := l.compile(&result, replaced)
+	if len(result.Loaded) == 0 && reflect.DeepEqual(set.Sources, l.Current().Sources) {
+		// Every change was rejected, so the active rules stay as they are
+		result.Set = l.Current()
+		return result, nil
+	}
+	result.Set, result.Swapped = set, true
+	l.current.Store(set)
+	return result, nil
+}
+
+// compile builds the rule set from the loaded files. When two files define the
+// same rule ID, the file loaded in this reload is rejected in favour of the
+// one already live, falling back to its own previous version, and is tried
+// again once the other file changes; between files already live, the first by
+// name wins.
+func (l *RuleLoader) compile(result *ReloadResult, replaced map[string]loadedFile) *RuleSet {
+	paths := make([]string, 0, len(l.files))
+	for path := range l.files {
+		paths = append(paths, path)
+	}
+	fresh := make(map[string]bool, len(result.Loaded))
+	for _, path := range result.Loaded {
+		fresh[path] = true
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if fresh[paths[i]] != fresh[paths[j]] {
+			return !fresh[paths[i]]
+		}
+		return paths[i] < paths[j]
+	})
+
+	var rules []Rule
+	sources := make(map[int]string)
+	var loaded []string
+	// clash returns a rule ID of the file that is already defined, and the file defining it
+	clash := func(file loadedFile) (int, string) {
+		for _, rule := range file.rules {
+			if other, ok := sources[rule.ID]; ok {
+				return rule.ID, other
+			}
+		}
+		return 0, ""
+	}
+	for _, path := range paths {
+		file := l.files[path]
+		if id, other := clash(file); other != "" {
+			err := fmt.Errorf("rule %d is already defined in %s", id, other)
+			result.Rejected = append(result.Rejected, &RuleFileError{Path: path, Err: err})
+			l.clashes[path] = clashingFile{file: file, with: other}
+			previous, ok := replaced[path]
+			if _, previousOther := clash(previous); !ok || previousOther != "" {
+				delete(l.files, path)
+				continue
+			}
+			file = previous
+			l.files[path] = previous
+			fresh[path] = false
+		}
+		for _, rule := range file.rules {
+			sources[rule.ID] = path
+			rules = append(rules, rule)
+		}
+		if fresh[path] {
+			loaded = append(loaded, path)
+		}
+	}
+	sort.Strings(loaded)
+	result.Loaded = loaded
+	return newRuleSet(l.Current().Generation+1, rules, sources)
+}
+
+// parse decodes and validates the rules of a file.
+func (l *RuleLoader) parse(path string, data []byte) ([]Rule, *RuleFileError) {
+	var rules []Rule
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var versions []RuleVersion
+		if err := json.Unmarshal(data, &versions); err != nil {
+			return nil, &RuleFileError{Path: path, Err: fmt.Errorf("invalid rule history: %v", err)}
+		}
+		if n := len(versions); n > 0 && !versions[n-1].Deleted {
+			rules = append(rules, versions[n-1].Rule)
+		}
+	} else {
+		var rule Rule
+		if err := json.Unmarshal(data, &rule); err != nil {
+			return nil, &RuleFileError{Path: path, Err: fmt.Errorf("invalid rule: %v", err)}
+		}
+		rules = append(rules, rule)
+	}
+
+	rejection := &RuleFileError{Path: path}
+	for _, rule := range rules {
+		if rule.ID <= 0 {
+			rejection.Err = fmt.Errorf("rule has no ID")
+			return nil, rejection
+		}
+		if err := l.cv.CheckRule(rule); err != nil {
+			rejection.Err = fmt.Errorf("rule %d: %v", rule.ID, err)
+			return nil, rejection
+		}
+		if !IsEmptyCondition(rule.Conditions) {
+			prefix := fmt.Sprintf("rule %d conditions ", rule.ID)
+			for _, diagnostic := range l.linter.Lint(rule.Conditions) {
+				diagnostic.Path = prefix + diagnostic.Path
+				rejection.Diagnostics = append(rejection.Diagnostics, diagnostic)
+			}
+		}
+		if !IsEmptyCondition(rule.Actions) {
+			prefix := fmt.Sprintf("rule %d actions ", rule.ID)
+			for _, diagnostic := range l.linter.LintActions(rule.Actions) {
+				diagnostic.Path = prefix + diagnostic.Path
+				rejection.Diagnostics = append(rejection.Diagnostics, diagnostic)
+			}
+		}
+	}
+	if HasErrors(rejection.Diagnostics) {
+		return nil, rejection
+	}
+	return rules, nil
+}
+
+// check verifies that a rule can be evaluated by the validator.
+// Watch loads the rules and reloads them whenever files in the directory
+// change, until ctx is done. Bursts of changes, such as an editor saving a
+// file, are reloaded once they settle.
+func (l *RuleLoader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch rule directory: %v", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(l.dir); err != nil {
+		return fmt.Errorf("failed to watch rule directory: %v", err)
+	}
+
+	reload := func() error {
+		result, err := l.Load()
+		if err == nil && l.onReload != nil {
+			l.onReload(result)
+		}
+		return err
+	}
+	if err := reload(); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(l.debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if isRuleFile(filepath.Base(event.Name)) {
+				timer.Reset(l.debounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch rule directory: %v", err)
+		case <-timer.C:
+			if err := reload(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// isRuleFile reports whether a file name is a rule file, skipping hidden and
+// temporary files such as those a DirectoryStore writes before renaming.
+func isRuleFile(name string) bool {
+	return strings.HasSuffix(name, ".json") && !strings.HasPrefix(name, ".")
+}
+
+// IsEmptyCondition reports whether a condition tree is unset, as for a rule
+// without conditions or actions. Such a tree matches everything and is not linted.
+func IsEmptyCondition(condition Condition) bool {
+	return condition.Type == "" && len(condition.Conditions) == 0
+}
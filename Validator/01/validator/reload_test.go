@@ -0,0 +1,202 @@
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ruleJSON returns a rule file whose rule matches carts with a subtotal of at least threshold.
+func ruleJSON(id int, threshold interface{}) string {
+	data, _ := json.Marshal(Rule{ID: id, Name: "Rule", IsActive: true, Conditions: Condition{
+		Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: threshold},
+		},
+	}})
+	return string(data)
+}
+
+func TestRuleLoader(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(set *RuleSet) []int {
+		var ids []int
+		for _, rule := range set.Rules {
+			ids = append(ids, rule.ID)
+		}
+		return ids
+	}
+	cv := NewConditionValidator()
+	loader := NewRuleLoader(cv, dir)
+
+	write("spring.json", ruleJSON(1, "100"))
+	write("summer.json", ruleJSON(2, "200"))
+	write(".rule-123.tmp", "partial write")
+	result, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !result.Swapped || len(result.Loaded) != 2 || len(result.Rejected) != 0 {
+		t.Fatalf("Load() = %+v, want two files loaded", result)
+	}
+	first := loader.Current()
+	if !reflect.DeepEqual(ids(first), []int{1, 2}) || first.Generation != 1 {
+		t.Fatalf("Current() = rules %v generation %d, want [1 2] generation 1", ids(first), first.Generation)
+	}
+	matched, err := first.Match(context.Background(), cv, Cart{Subtotal: 150})
+	if err != nil || len(matched) != 1 || matched[0].ID != 1 {
+		t.Errorf("Match() = %v, %v, want rule 1", matched, err)
+	}
+
+	t.Run("Unchanged files do not swap", func(t *testing.T) {
+		result, _ := loader.Load()
+		if result.Swapped || result.Set != first {
+			t.Errorf("Load() swapped without changes")
+		}
+	})
+
+	t.Run("Invalid file keeps the previous version", func(t *testing.T) {
+		write("spring.json", ruleJSON(1, "a lot"))
+		write("broken.json", `{"id": 3,`)
+		result, err := loader.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if result.Swapped || len(result.Rejected) != 2 {
+			t.Fatalf("Load() = %+v, want two rejections and no swap", result)
+		}
+		var lint *RuleFileError
+		for _, rejection := range result.Rejected {
+			if strings.HasSuffix(rejection.Path, "spring.json") {
+				lint = rejection
+			}
+		}
+		if lint == nil || !HasErrors(lint.Diagnostics) || !strings.Contains(lint.Error(), "rule 1 conditions $.conditions[0]") {
+			t.Errorf("rejection = %v, want lint diagnostics for rule 1", lint)
+		}
+		if loader.Current() != first {
+			t.Error("Current() changed after a rejected reload")
+		}
+	})
+
+	t.Run("Changes swap atomically", func(t *testing.T) {
+		os.Remove(filepath.Join(dir, "broken.json"))
+		write("spring.json", ruleJSON(1, "50"))
+		os.Remove(filepath.Join(dir, "summer.json"))
+		result, err := loader.Load()
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if !result.Swapped || len(result.Loaded) != 1 || len(result.Removed) != 1 {
+			t.Fatalf("Load() = %+v, want spring loaded and summer removed", result)
+		}
+		current := loader.Current()
+		if !reflect.DeepEqual(ids(current), []int{1}) || current.Generation != 2 {
+			t.Errorf("Current() = rules %v generation %d, want [1] generation 2", ids(current), current.Generation)
+		}
+		// An evaluation holding the previous set still sees it unchanged
+		if !reflect.DeepEqual(ids(first), []int{1, 2}) || first.Rules[0].Conditions.Conditions[0].Value != "100" {
+			t.Error("the previous rule set changed")
+		}
+	})
+
+	t.Run("Duplicate rule IDs", func(t *testing.T) {
+		write("copy.json", ruleJSON(1, "10"))
+		result, _ := loader.Load()
+		if len(result.Rejected) != 1 || !strings.Contains(result.Rejected[0].Error(), "already defined") {
+			t.Errorf("Load() rejected %v, want the copy", result.Rejected)
+		}
+		if current := loader.Current(); current.Sources[1] != filepath.Join(dir, "spring.json") {
+			t.Errorf("rule 1 loaded from %s, want spring.json", current.Sources[1])
+		}
+
+		before := loader.Current()
+		if result, _ := loader.Load(); result.Swapped || len(result.Loaded) != 0 || len(result.Rejected) != 0 || result.Set != before {
+			t.Errorf("Load() without changes = %+v, want no swap and no rejections", result)
+		}
+
+		// Moving spring.json to another ID lets the copy in
+		write("spring.json", ruleJSON(4, "50"))
+		result, _ = loader.Load()
+		if want := []string{filepath.Join(dir, "copy.json"), filepath.Join(dir, "spring.json")}; !result.Swapped || !reflect.DeepEqual(result.Loaded, want) {
+			t.Errorf("Load() = %+v, want %v loaded", result, want)
+		}
+		if current := loader.Current(); current.Sources[1] != filepath.Join(dir, "copy.json") || !reflect.DeepEqual(ids(current), []int{1, 4}) {
+			t.Errorf("Current() = rules %v from %v, want rule 1 from copy.json and rule 4", ids(current), current.Sources)
+		}
+	})
+
+	t.Run("Directory store histories", func(t *testing.T) {
+		storeDir := t.TempDir()
+		store, err := NewDirectoryStore(storeDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := NewRuleRepository(store)
+		repo.Create(Rule{ID: 5, Name: "Old", IsActive: true}, "ann", "")
+		repo.Update(Rule{ID: 5, Name: "New", IsActive: true}, 1, "ann", "")
+		repo.Create(Rule{ID: 6, IsActive: true}, "ann", "")
+		repo.Delete(6, "ann", "")
+		history := NewRuleLoader(cv, storeDir)
+		if _, err := history.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if rules := history.Current().Rules; len(rules) != 1 || rules[0].Name != "New" {
+			t.Errorf("Current() = %+v, want the latest version of rule 5 only", rules)
+		}
+	})
+}
+
+func TestRuleLoaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "spring.json"), []byte(ruleJSON(1, "100")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan ReloadResult, 10)
+	loader := NewRuleLoader(NewConditionValidator(), dir).
+		WithDebounce(10 * time.Millisecond).
+		WithReloadHandler(func(result ReloadResult) { reloads <- result })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- loader.Watch(ctx) }()
+
+	next := func() ReloadResult {
+		t.Helper()
+		select {
+		case result := <-reloads:
+			return result
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload within 5s")
+			return ReloadResult{}
+		}
+	}
+	if result := next(); len(result.Set.Rules) != 1 {
+		t.Fatalf("initial load = %d rules, want 1", len(result.Set.Rules))
+	}
+	if err := os.WriteFile(filepath.Join(dir, "summer.json"), []byte(ruleJSON(2, "200")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if result := next(); result.Swapped {
+			if len(result.Set.Rules) != 2 {
+				t.Errorf("reload = %d rules, want 2", len(result.Set.Rules))
+			}
+			break
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch() error = %v", err)
+	}
+}
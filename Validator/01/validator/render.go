@@ -0,0 +1,365 @@
+package validator
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Attribute labels as shown in the Magento admin, by condition type
+var (
+	productLabels = map[string]string{
+		"sku":                  "SKU",
+		"name":                 "Name",
+		"price":                "Price in cart",
+		"quote_item_price":     "Price in cart",
+		"final_price":          "Final price",
+		"quantity":             "Quantity in cart",
+		"qty":                  "Quantity in cart",
+		"quote_item_qty":       "Quantity in cart",
+		"row_total":            "Row total in cart",
+		"base_row_total":       "Row total in cart",
+		"quote_item_row_total": "Row total in cart",
+		"weight":               "Weight",
+		"category_ids":         "Category",
+		"product_type":         "Type",
+		"created_at":           "Created at",
+		"updated_at":           "Updated at",
+	}
+	addressLabels = map[string]string{
+		"base_subtotal":   "Subtotal",
+		"subtotal":        "Subtotal",
+		"total_qty":       "Total Items Quantity",
+		"weight":          "Total Weight",
+		"payment_method":  "Payment Method",
+		"shipping_method": "Shipping Method",
+		"country":         "Shipping Country",
+		"country_id":      "Shipping Country",
+		"region":          "Shipping State/Province",
+		"region_id":       "Shipping State/Province",
+		"city":            "Shipping City",
+		"postcode":        "Shipping Postcode",
+		"street":          "Shipping Street",
+		"telephone":       "Shipping Telephone",
+		"company":         "Shipping Company",
+		"firstname":       "Shipping First Name",
+		"lastname":        "Shipping Last Name",
+		"email":           "Shipping Email",
+	}
+	customerLabels = map[string]string{
+		"id":                   "Customer ID",
+		"group_id":             "Customer Group",
+		"email":                "Customer Email",
+		"firstname":            "Customer First Name",
+		"lastname":             "Customer Last Name",
+		"gender":               "Customer Gender",
+		"dob":                  "Customer Date of Birth",
+		"created_at":           "Customer Since",
+		"last_login_at":        "Customer Last Login",
+		"orders_count":         "Number of Orders",
+		"total_spent":          "Lifetime Sales",
+		"average_order_amount": "Average Order Amount",
+		"is_subscribed":        "Newsletter Subscription",
+	}
+	subselectLabels = map[string]string{
+		"qty":            "total quantity",
+		"quantity":       "total quantity",
+		"base_row_total": "total amount",
+		"row_total":      "total amount",
+	}
+	operatorLabels = map[string]string{
+		"==":      "is",
+		"!=":      "is not",
+		">=":      "equals or greater than",
+		"<=":      "equals or less than",
+		">":       "greater than",
+		"<":       "less than",
+		"{}":      "contains",
+		"!{}":     "does not contain",
+		"()":      "is one of",
+		"!()":     "is not one of",
+		"like":    "is like",
+		"nlike":   "is not like",
+		"null":    "is undefined",
+		"notnull": "is defined",
+	}
+)
+
+// renderNode is a rendered condition: its sentence and the rendered subconditions.
+type renderNode struct {
+	Label    string
+	Children []renderNode
+}
+
+// Renderer turns condition trees into English like the Magento admin shows them,
+// e.g. "If ALL of these conditions are TRUE:" followed by "Subtotal equals or
+// greater than 100".
+type Renderer struct{}
+
+// NewRenderer creates a new Renderer.
+func NewRenderer() *Renderer {
+	return &Renderer{}
+}
+
+// Text renders the condition as plain text, indenting subconditions by two spaces.
+func (r *Renderer) Text(condition Condition) string {
+	var sb strings.Builder
+	var write func(node renderNode, depth int)
+	write = func(node renderNode, depth int) {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString(node.Label)
+		sb.WriteString("\n")
+		for _, child := range node.Children {
+			write(child, depth+1)
+		}
+	}
+	write(r.node(condition), 0)
+	return sb.String()
+}
+
+// Sentence renders the condition on a single line, separating subconditions with semicolons.
+func (r *Renderer) Sentence(condition Condition) string {
+	var sentence func(node renderNode) string
+	sentence = func(node renderNode) string {
+		if len(node.Children) == 0 {
+			return node.Label
+		}
+		parts := make([]string, len(node.Children))
+		for i, child := range node.Children {
+			parts[i] = sentence(child)
+		}
+		return node.Label + " " + strings.Join(parts, "; ")
+	}
+	return sentence(r.node(condition))
+}
+
+// Markdown renders the condition as a nested Markdown list.
+func (r *Renderer) Markdown(condition Condition) string {
+	var sb strings.Builder
+	var write func(node renderNode, depth int)
+	write = func(node renderNode, depth int) {
+		sb.WriteString(strings.Repeat("  ", depth))
+		sb.WriteString("- ")
+		sb.WriteString(r.escapeMarkdown(node.Label))
+		sb.WriteString("\n")
+		for _, child := range node.Children {
+			write(child, depth+1)
+		}
+	}
+	write(r.node(condition), 0)
+	return sb.String()
+}
+
+// HTML renders the condition as nested HTML lists.
+func (r *Renderer) HTML(condition Condition) string {
+	var sb strings.Builder
+	var write func(node renderNode)
+	write = func(node renderNode) {
+		sb.WriteString("<li>")
+		sb.WriteString(html.EscapeString(node.Label))
+		if len(node.Children) > 0 {
+			sb.WriteString("<ul>")
+			for _, child := range node.Children {
+				write(child)
+			}
+			sb.WriteString("</ul>")
+		}
+		sb.WriteString("</li>")
+	}
+	sb.WriteString(`<ul class="rule-conditions">`)
+	write(r.node(condition))
+	sb.WriteString("</ul>")
+	return sb.String()
+}
+
+// node builds the rendered tree of a condition.
+func (r *Renderer) node(condition Condition) renderNode {
+	node := renderNode{Label: r.label(condition)}
+	for _, subCondition := range condition.Conditions {
+		node.Children = append(node.Children, r.node(subCondition))
+	}
+	return node
+}
+
+// label renders the sentence of a single condition, without its subconditions.
+func (r *Renderer) label(condition Condition) string {
+	aggregator := strings.ToUpper(condition.Aggregator)
+	if aggregator == "" {
+		aggregator = "ALL"
+	}
+	expected := "TRUE"
+	if !isTrueValue(condition.Value) {
+		expected = "FALSE"
+	}
+
+	switch condition.Type {
+	case TypeCombine, TypeProductCombine:
+		return fmt.Sprintf("If %s of these conditions are %s:", aggregator, expected)
+	case TypeProductFound:
+		found := "FOUND"
+		if expected == "FALSE" {
+			found = "NOT FOUND"
+		}
+		return fmt.Sprintf("If an item is %s in the cart with %s of these conditions true:", found, aggregator)
+	case TypeSubselect:
+		return fmt.Sprintf("If %s %s %s for a subselection of items in cart matching %s of these conditions:",
+			r.attributeLabel(subselectLabels, condition.Attribute), r.operatorLabel(condition.Operator), r.formatValue(condition.Value), aggregator)
+	case TypeProduct:
+		return r.comparison(r.attributeLabel(productLabels, trimEntityPrefix(condition.Attribute, "item", "product")), condition)
+	case TypeAddress:
+		return r.comparison(r.addressLabel(condition.Attribute), condition)
+	case TypeCustomer:
+		return r.comparison(r.customerLabel(condition.Attribute), condition)
+	case TypeSegment:
+		operator := condition.Operator
+		if operator == "" {
+			operator = "=="
+		}
+		return "Customer segment " + r.operatorLabel(operator) + " " + r.formatValue(condition.Value)
+	default:
+		return strings.TrimSpace(fmt.Sprintf("%s: %s", shortType(condition.Type), r.comparison(r.attributeLabel(nil, condition.Attribute), condition)))
+	}
+}
+
+// comparison renders "<attribute label> <operator> <value>".
+func (r *Renderer) comparison(label string, condition Condition) string {
+	switch condition.Operator {
+	case "null", "notnull":
+		return label + " " + r.operatorLabel(condition.Operator)
+	}
+	return label + " " + r.operatorLabel(condition.Operator) + " " + r.formatValue(condition.Value)
+}
+
+// attributeLabel returns the admin label of an attribute, or a humanised attribute code.
+func (r *Renderer) attributeLabel(labels map[string]string, attribute string) string {
+	if label, ok := labels[strings.ToLower(attribute)]; ok {
+		return label
+	}
+	if isAttributePath(attribute) {
+		return r.pathLabel(attribute)
+	}
+	return humanizeCode(attribute)
+}
+
+// addressLabel returns the label of an Address attribute, naming the billing
+// address for billing_address. attributes. Quote totals and methods are the
+// same whichever address is named.
+func (r *Renderer) addressLabel(attribute string) string {
+	billing, attribute := splitAddressAttribute(attribute)
+	label := r.attributeLabel(addressLabels, attribute)
+	lower := strings.ToLower(attribute)
+	switch {
+	case cartAddressAttributes[lower] || lower == "payment_method" || lower == "shipping_method":
+		return label
+	case billing && strings.HasPrefix(label, "Shipping "):
+		return "Billing " + strings.TrimPrefix(label, "Shipping ")
+	case billing:
+		return "Billing address " + lowerFirst(label)
+	case isAttributePath(attribute):
+		return "Shipping address " + lowerFirst(label)
+	default:
+		return label
+	}
+}
+
+// customerLabel returns the label of a Customer attribute.
+func (r *Renderer) customerLabel(attribute string) string {
+	attribute = trimEntityPrefix(attribute, "customer")
+	if isAttributePath(attribute) {
+		return "Customer " + lowerFirst(r.pathLabel(attribute))
+	}
+	return r.attributeLabel(customerLabels, attribute)
+}
+
+// pathLabel humanises an attribute path, such as "Addresses (any) / Country"
+// for addresses[*].country. Custom attributes are named by their code alone.
+func (r *Renderer) pathLabel(attribute string) string {
+	segments, err := parseAttributePath(attribute)
+	if err != nil {
+		return humanizeCode(attribute)
+	}
+	if len(segments) > 1 && strings.EqualFold(segments[0].name, "attributes") && segments[0].quantifier == "" {
+		segments = segments[1:]
+	}
+	parts := make([]string, len(segments))
+	for i, segment := range segments {
+		part := humanizeCode(segment.name)
+		if i > 0 {
+			part = lowerFirst(part)
+		}
+		switch segment.quantifier {
+		case quantifyIndex:
+			part += fmt.Sprintf(" #%d", segment.index+1)
+		case quantifyAny:
+			part += " (any)"
+		case quantifyAll:
+			part += " (all)"
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, " / ")
+}
+
+// humanizeCode turns an attribute code such as country_id into "Country id".
+func humanizeCode(code string) string {
+	label := strings.ReplaceAll(code, "_", " ")
+	if label == "" {
+		return label
+	}
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// lowerFirst lowercases the first letter of a label that does not start with an acronym.
+func lowerFirst(label string) string {
+	if len(label) < 2 || strings.ToUpper(label[:2]) == label[:2] {
+		return label
+	}
+	return strings.ToLower(label[:1]) + label[1:]
+}
+
+// operatorLabel returns the admin label of an operator.
+func (r *Renderer) operatorLabel(operator string) string {
+	return OperatorLabel(operator)
+}
+
+// OperatorLabel returns the label the Magento admin shows for an operator,
+// such as "is one of" for (), or the operator itself if it has none.
+func OperatorLabel(operator string) string {
+	if label, ok := operatorLabels[operator]; ok {
+		return label
+	}
+	return operator
+}
+
+// formatValue renders a condition value, joining lists with commas.
+func (r *Renderer) formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, part := range v {
+			parts[i] = r.formatValue(part)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// escapeMarkdown escapes characters with a meaning in Markdown.
+func (r *Renderer) escapeMarkdown(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune("\\`*_[]<>|", c) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRenderer(t *testing.T) {
+	var condition Condition
+	err := json.Unmarshal([]byte(`{
+		"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+		"value": "1",
+		"aggregator": "all",
+		"conditions": [
+			{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Address", "attribute": "base_subtotal", "operator": ">=", "value": "100"},
+			{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "1012096"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "category_ids", "operator": "()", "value": ["3", "4"]}
+				]
+			},
+			{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Customer", "attribute": "email", "operator": "like", "value": "%<b>_vip"}
+		]
+	}`), &condition)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal condition: %v", err)
+	}
+	renderer := NewRenderer()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "Sentence",
+			got:  renderer.Sentence(condition),
+			want: "If ALL of these conditions are TRUE: Subtotal equals or greater than 100; " +
+				"If an item is FOUND in the cart with ALL of these conditions true: SKU is 1012096; Category is one of 3, 4; " +
+				"Customer Email is like %<b>_vip",
+		},
+		{
+			name: "Text",
+			got:  renderer.Text(condition),
+			want: "If ALL of these conditions are TRUE:\n" +
+				"  Subtotal equals or greater than 100\n" +
+				"  If an item is FOUND in the cart with ALL of these conditions true:\n" +
+				"    SKU is 1012096\n" +
+				"    Category is one of 3, 4\n" +
+				"  Customer Email is like %<b>_vip\n",
+		},
+		{
+			name: "Markdown",
+			got:  renderer.Markdown(condition),
+			want: "- If ALL of these conditions are TRUE:\n" +
+				"  - Subtotal equals or greater than 100\n" +
+				"  - If an item is FOUND in the cart with ALL of these conditions true:\n" +
+				"    - SKU is 1012096\n" +
+				"    - Category is one of 3, 4\n" +
+				"  - Customer Email is like %\\<b\\>\\_vip\n",
+		},
+		{
+			name: "HTML",
+			got:  renderer.HTML(condition),
+			want: `<ul class="rule-conditions"><li>If ALL of these conditions are TRUE:<ul>` +
+				`<li>Subtotal equals or greater than 100</li>` +
+				`<li>If an item is FOUND in the cart with ALL of these conditions true:<ul><li>SKU is 1012096</li><li>Category is one of 3, 4</li></ul></li>` +
+				`<li>Customer Email is like %&lt;b&gt;_vip</li>` +
+				`</ul></li></ul>`,
+		},
+		{
+			name: "Subselect and false combine",
+			got: renderer.Sentence(Condition{Type: TypeCombine, Aggregator: "any", Value: "0", Conditions: []Condition{
+				{Type: TypeSubselect, Attribute: "qty", Operator: ">=", Value: "2", Conditions: []Condition{
+					{Type: TypeProduct, Attribute: "quantity", Operator: ">", Value: "1"},
+				}},
+			}}),
+			want: "If ANY of these conditions are FALSE: If total quantity equals or greater than 2 for a subselection of items in cart matching ALL of these conditions: Quantity in cart greater than 1",
+		},
+		{
+			name: "Billing addresses and attribute paths",
+			got: renderer.Sentence(Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"},
+				{Type: TypeAddress, Attribute: "billing_address.base_subtotal", Operator: ">", Value: "50"},
+				{Type: TypeAddress, Attribute: "billing_address.street[0]", Operator: "{}", Value: "Main"},
+				{Type: TypeAddress, Attribute: "shipping_address.city", Operator: "==", Value: "Berlin"},
+				{Type: TypeCustomer, Attribute: "addresses[*].country", Operator: "==", Value: "US"},
+				{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+					{Type: TypeProduct, Attribute: "item.attributes.color", Operator: "==", Value: "red"},
+					{Type: TypeProduct, Attribute: "children[all].sku", Operator: "!=", Value: "X"},
+				}},
+			}}),
+			want: "If ALL of these conditions are TRUE: Billing Country is DE; Subtotal greater than 50; " +
+				"Billing address street #1 contains Main; Shipping City is Berlin; Customer addresses (any) / country is US; " +
+				"If an item is FOUND in the cart with ALL of these conditions true: Color is red; Children (all) / sku is not X",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRuleNotFound is returned for a rule or rule version the repository does not hold.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// ErrRuleExists is returned when a rule is created with the ID of an existing rule.
+var ErrRuleExists = errors.New("rule already exists")
+
+// ErrVersionConflict is returned when a version is stored that is not the
+// next version of its rule, such as when two writers update a rule at once,
+// or when a rule is updated from a version that is no longer the latest.
+var ErrVersionConflict = errors.New("rule version conflict")
+
+// RuleVersion is one immutable version of a rule, recording who changed the
+// rule, when and why.
+type RuleVersion struct {
+	RuleID       int       `json:"rule_id"`
+	Version      int       `json:"version"` // Counts from 1
+	Rule         Rule      `json:"rule"`
+	Author       string    `json:"author"`
+	Message      string    `json:"message,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Deleted      bool      `json:"deleted,omitempty"`       // The rule was deleted in this version
+	RestoredFrom int       `json:"restored_from,omitempty"` // The version a rollback restored
+}
+
+// RuleStore persists rule versions. Stores only ever append versions, and
+// must reject a version that is not the next one of its rule with an error
+// wrapping ErrVersionConflict. Implementations must be safe for concurrent use.
+type RuleStore interface {
+	// Append stores a new version of a rule.
+	Append(version RuleVersion) error
+	// Versions returns the versions of a rule, oldest first, or none for an unknown rule.
+	Versions(ruleID int) ([]RuleVersion, error)
+	// RuleIDs returns the IDs of every rule with versions, in ascending order.
+	RuleIDs() ([]int, error)
+	// Close releases the store.
+	Close() error
+}
+
+// RuleRepository manages rules on top of a RuleStore. Every create, update,
+// delete and rollback adds a version to the rule's history, so earlier
+// versions stay available for auditing, diffing and rollback.
+type RuleRepository struct {
+	store RuleStore
+	now   func() time.Time
+	mu    sync.Mutex // Serialises writes, so new rule IDs and versions do not collide
+}
+
+// NewRuleRepository creates a RuleRepository backed by the store.
+func NewRuleRepository(store RuleStore) *RuleRepository {
+	return &RuleRepository{store: store, now: time.Now}
+}
+
+// WithClock makes the repository timestamp versions with the time now returns.
+func (r *RuleRepository) WithClock(now func() time.Time) *RuleRepository {
+	r.now = now
+	return r
+}
+
+// Create stores a new rule as its version 1. A rule without an ID gets the
+// next free ID. Creating a rule whose ID is taken, even by a deleted rule,
+// returns an error wrapping ErrRuleExists.
+func (r *RuleRepository) Create(rule Rule, author, message string) (RuleVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rule.ID == 0 {
+		ids, err := r.store.RuleIDs()
+		if err != nil {
+			return RuleVersion{}, err
+		}
+		rule.ID = 1
+		if len(ids) > 0 {
+			rule.ID = ids[len(ids)-1] + 1
+		}
+	} else {
+		versions, err := r.store.Versions(rule.ID)
+		if err != nil {
+			return RuleVersion{}, err
+		}
+		if len(versions) > 0 {
+			return RuleVersion{}, fmt.Errorf("%w: %d", ErrRuleExists, rule.ID)
+		}
+	}
+	return r.append(RuleVersion{RuleID: rule.ID, Version: 1, Rule: rule, Author: author, Message: message})
+}
+
+// Update stores a new version of an existing rule. baseVersion is the
+// version the change was made to; if the rule has changed since, the update
+// is rejected with ErrVersionConflict rather than overwriting that change.
+func (r *RuleRepository) Update(rule Rule, baseVersion int, author, message string) (RuleVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest, err := r.latest(rule.ID)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	if baseVersion != latest.Version {
+		return RuleVersion{}, fmt.Errorf("%w: rule %d is at version %d, the change was made to version %d", ErrVersionConflict, rule.ID, latest.Version, baseVersion)
+	}
+	return r.append(RuleVersion{RuleID: rule.ID, Version: latest.Version + 1, Rule: rule, Author: author, Message: message})
+}
+
+// Delete marks a rule deleted with a new version. Its history is kept, and a
+// rollback brings it back.
+func (r *RuleRepository) Delete(id int, author, message string) (RuleVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	latest, err := r.latest(id)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	return r.append(RuleVersion{RuleID: id, Version: latest.Version + 1, Rule: latest.Rule, Author: author, Message: message, Deleted: true})
+}
+
+// Rollback restores the rule as it was in an earlier version, by storing a
+// copy of that version as the newest one. Rolling back a deleted rule
+// undeletes it.
+func (r *RuleRepository) Rollback(id, version int, author, message string) (RuleVersion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	versions, err := r.store.Versions(id)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	restored, err := findVersion(versions, id, version)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	if restored.Deleted {
+		return RuleVersion{}, fmt.Errorf("version %d of rule %d is a deletion", version, id)
+	}
+	if message == "" {
+		message = fmt.Sprintf("Roll back to version %d", version)
+	}
+	next := RuleVersion{RuleID: id, Version: len(versions) + 1, Rule: restored.Rule, Author: author, Message: message, RestoredFrom: version}
+	return r.append(next)
+}
+
+// Get returns the current version of a rule that is not deleted.
+func (r *RuleRepository) Get(id int) (Rule, error) {
+	latest, err := r.latest(id)
+	if err != nil {
+		return Rule{}, err
+	}
+	return latest.Rule, nil
+}
+
+// List returns the current version of every rule that is not deleted, by ID.
+func (r *RuleRepository) List() ([]Rule, error) {
+	ids, err := r.store.RuleIDs()
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	for _, id := range ids {
+		versions, err := r.store.Versions(id)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(versions); n > 0 && !versions[n-1].Deleted {
+			rules = append(rules, versions[n-1].Rule)
+		}
+	}
+	return rules, nil
+}
+
+// History returns every version of a rule, oldest first, including deletions.
+func (r *RuleRepository) History(id int) ([]RuleVersion, error) {
+	versions, err := r.store.Versions(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrRuleNotFound, id)
+	}
+	return versions, nil
+}
+
+// Version returns one version of a rule.
+func (r *RuleRepository) Version(id, version int) (RuleVersion, error) {
+	versions, err := r.store.Versions(id)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	return findVersion(versions, id, version)
+}
+
+// Diff returns the changes from one version of a rule to another.
+func (r *RuleRepository) Diff(id, from, to int) (RuleDiff, error) {
+	versions, err := r.store.Versions(id)
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	old, err := findVersion(versions, id, from)
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	new, err := findVersion(versions, id, to)
+	if err != nil {
+		return RuleDiff{}, err
+	}
+	return DiffRules(old.Rule, new.Rule)
+}
+
+// Close closes the store.
+func (r *RuleRepository) Close() error {
+	return r.store.Close()
+}
+
+// latest returns the newest version of a rule that is not deleted.
+func (r *RuleRepository) latest(id int) (RuleVersion, error) {
+	versions, err := r.store.Versions(id)
+	if err != nil {
+		return RuleVersion{}, err
+	}
+	if len(versions) == 0 || versions[len(versions)-1].Deleted {
+		return RuleVersion{}, fmt.Errorf("%w: %d", ErrRuleNotFound, id)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// append timestamps and stores a version.
+func (r *RuleRepository) append(version RuleVersion) (RuleVersion, error) {
+	version.Rule.ID = version.RuleID
+	version.CreatedAt = r.now().UTC()
+	if err := r.store.Append(version); err != nil {
+		return RuleVersion{}, err
+	}
+	return version, nil
+}
+
+// findVersion returns a version from a rule's history.
+func findVersion(versions []RuleVersion, id, version int) (RuleVersion, error) {
+	if version < 1 || version > len(versions) {
+		return RuleVersion{}, fmt.Errorf("%w: version %d of rule %d", ErrRuleNotFound, version, id)
+	}
+	return versions[version-1], nil
+}
+
+// nextVersion checks that version follows the existing versions of its rule.
+func nextVersion(versions []RuleVersion, version RuleVersion) error {
+	if version.Version != len(versions)+1 {
+		return fmt.Errorf("%w: rule %d is at version %d, cannot store version %d", ErrVersionConflict, version.RuleID, len(versions), version.Version)
+	}
+	return nil
+}
+
+// sortedIDs returns the keys of a set of rule IDs in ascending order.
+func sortedIDs(set map[int]bool) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
@@ -0,0 +1,157 @@
+package validator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleRepository(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) RuleStore
+	}{
+		{"Directory", func(t *testing.T) RuleStore {
+			store, err := NewDirectoryStore(filepath.Join(t.TempDir(), "rules"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		}},
+		{"Bolt", func(t *testing.T) RuleStore {
+			store, err := OpenBoltStore(filepath.Join(t.TempDir(), "rules.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			testRuleRepository(t, backend.open(t))
+		})
+	}
+}
+
+func testRuleRepository(t *testing.T, store RuleStore) {
+	now := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	repo := NewRuleRepository(store).WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	})
+	defer repo.Close()
+
+	subtotal := func(amount string) Condition {
+		return Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: amount},
+		}}
+	}
+	created, err := repo.Create(Rule{Name: "Spring sale", IsActive: true, DiscountAmount: 10, Conditions: subtotal("100")}, "ann", "New promotion")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.RuleID != 1 || created.Version != 1 || created.Rule.ID != 1 {
+		t.Fatalf("Create() = rule %d version %d, want rule 1 version 1", created.RuleID, created.Version)
+	}
+	if _, err := repo.Create(Rule{ID: 1, Name: "Duplicate"}, "bob", ""); err == nil {
+		t.Error("Create() with a taken ID error = nil, want an error")
+	}
+	if second, _ := repo.Create(Rule{Name: "Free shipping", IsActive: true}, "bob", ""); second.RuleID != 2 {
+		t.Errorf("Create() second rule ID = %d, want 2", second.RuleID)
+	}
+
+	updated := created.Rule
+	updated.Conditions = subtotal("150")
+	if _, err := repo.Update(updated, 1, "bob", "Raise threshold"); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if _, err := repo.Update(created.Rule, 1, "ann", "Stale edit"); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Update() from a superseded version error = %v, want ErrVersionConflict", err)
+	}
+	if _, err := repo.Update(Rule{ID: 9}, 1, "bob", ""); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("Update() of an unknown rule error = %v, want ErrRuleNotFound", err)
+	}
+	if got, _ := repo.Get(1); got.Conditions.Conditions[0].Value != "150" {
+		t.Errorf("Get() = %+v, want the updated threshold", got.Conditions)
+	}
+
+	diff, err := repo.Diff(1, 1, 2)
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	wantDiff := []ConditionChange{{Path: "$.conditions[0]", Kind: DiffChanged, Field: "value", Old: "100", New: "150"}}
+	if !reflect.DeepEqual(diff.Conditions, wantDiff) || len(diff.Fields) != 0 {
+		t.Errorf("Diff() = %+v, want %+v", diff, wantDiff)
+	}
+
+	if _, err := repo.Delete(1, "carol", "Campaign over"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.Get(1); !errors.Is(err, ErrRuleNotFound) {
+		t.Errorf("Get() of a deleted rule error = %v, want ErrRuleNotFound", err)
+	}
+	if rules, _ := repo.List(); len(rules) != 1 || rules[0].ID != 2 {
+		t.Errorf("List() = %v, want only rule 2", rules)
+	}
+
+	restored, err := repo.Rollback(1, 1, "ann", "")
+	if err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if restored.Version != 4 || restored.RestoredFrom != 1 || restored.Message != "Roll back to version 1" {
+		t.Errorf("Rollback() = %+v", restored)
+	}
+	if _, err := repo.Rollback(1, 3, "ann", ""); err == nil {
+		t.Error("Rollback() to a deletion error = nil, want an error")
+	}
+	if got, _ := repo.Get(1); !reflect.DeepEqual(got.Conditions, created.Rule.Conditions) {
+		t.Errorf("Get() after rollback = %+v, want version 1", got.Conditions)
+	}
+
+	history, err := repo.History(1)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	var audit []string
+	for _, version := range history {
+		audit = append(audit, version.Author+" "+version.CreatedAt.Format("15:04"))
+	}
+	if want := []string{"ann 09:01", "bob 09:03", "carol 09:04", "ann 09:05"}; !reflect.DeepEqual(audit, want) {
+		t.Errorf("History() = %v, want %v", audit, want)
+	}
+	if !history[2].Deleted {
+		t.Error("History() version 3 is not a deletion")
+	}
+
+	// Stored versions are immutable
+	if err := store.Append(RuleVersion{RuleID: 1, Version: 2, Rule: updated}); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("Append() of an existing version error = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestDirectoryStoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDirectoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := NewRuleRepository(store)
+	if _, err := repo.Create(Rule{ID: 7, Name: "Spring sale"}, "ann", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a rule"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := store.RuleIDs(); !reflect.DeepEqual(ids, []int{7}) {
+		t.Errorf("RuleIDs() = %v, want [7]", ids)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "rule-7.json"), []byte(`[{"rule_id": 8, "version": 1}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(7); err == nil {
+		t.Error("Get() of a mismatched file error = nil, want an error")
+	}
+}
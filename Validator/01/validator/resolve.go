@@ -0,0 +1,238 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ResolutionMode says how the discounts of several matching rules combine.
+type ResolutionMode string
+
+// Resolution modes
+const (
+	// ModeStack applies matching rules in SortOrder, lowest first, adding up their
+	// discounts and honouring StopRulesProcessing, Exclusive and PriorityGroup.
+	ModeStack ResolutionMode = "stack"
+	// ModeBestDiscount applies only the matching rule with the largest discount.
+	ModeBestDiscount ResolutionMode = "best_discount"
+)
+
+// SuppressionReason says why a matching rule was not applied.
+type SuppressionReason string
+
+// Suppression reasons
+const (
+	SuppressedStopRules     SuppressionReason = "stop_rules_processing" // An earlier rule stops further rules
+	SuppressedExclusive     SuppressionReason = "exclusive"             // An exclusive rule cannot combine with other rules
+	SuppressedPriorityGroup SuppressionReason = "priority_group"        // Another rule of the same priority group applies
+	SuppressedBestDiscount  SuppressionReason = "best_discount"         // Another rule gives a larger discount
+	SuppressedItemCap       SuppressionReason = "item_cap"              // Its items already have the maximum discount
+	SuppressedCartCap       SuppressionReason = "cart_cap"              // The cart already has the maximum discount
+)
+
+// ResolutionPolicy configures a ConflictResolver. Zero caps mean no cap.
+type ResolutionPolicy struct {
+	Mode            ResolutionMode
+	MaxCartDiscount float64 // Maximum discount over the whole cart
+	MaxItemDiscount float64 // Maximum discount of a single item line
+}
+
+// AppliedRule is a rule applied to the cart with the discount it gives after caps.
+type AppliedRule struct {
+	Rule     Rule
+	Discount float64
+	Items    []ItemDiscount
+	Capped   bool   // The discount was reduced by a cap
+	Variant  string // Experiment variant of the customer, if the rule has an experiment
+}
+
+// SuppressedRule is a matching rule that was not applied.
+type SuppressedRule struct {
+	Rule     Rule
+	Reason   SuppressionReason
+	ByRuleID int     // The rule that caused the suppression, 0 for caps
+	Discount float64 // The discount the rule would have given on its own
+	Variant  string  // Experiment variant of the customer, if the rule has an experiment
+}
+
+// Resolution is the outcome of resolving the rules that match a cart.
+type Resolution struct {
+	Applied       []AppliedRule
+	Suppressed    []SuppressedRule
+	TotalDiscount float64
+}
+
+// ConflictResolver decides which of several matching rules apply to a cart and
+// how their discounts combine.
+type ConflictResolver struct {
+	cv     *ConditionValidator
+	policy ResolutionPolicy
+}
+
+// NewConflictResolver creates a ConflictResolver with the given policy. An
+// empty mode defaults to ModeStack.
+func NewConflictResolver(validator *ConditionValidator, policy ResolutionPolicy) *ConflictResolver {
+	if policy.Mode == "" {
+		policy.Mode = ModeStack
+	}
+	return &ConflictResolver{cv: validator, policy: policy}
+}
+
+// candidate is a rule that matches the cart, with its uncapped discount.
+type candidate struct {
+	rule      Rule
+	variant   string
+	discounts []ItemDiscount
+	total     float64
+}
+
+// Resolve evaluates the rules against the cart and resolves the matching ones.
+// Rules that do not match appear in neither list.
+func (r *ConflictResolver) Resolve(rules []Rule, cart Cart) (*Resolution, error) {
+	return r.ResolveContext(context.Background(), rules, cart)
+}
+
+// ResolveContext is like Resolve but stops evaluating once ctx is done.
+func (r *ConflictResolver) ResolveContext(ctx context.Context, rules []Rule, cart Cart) (*Resolution, error) {
+	if r.policy.Mode != ModeStack && r.policy.Mode != ModeBestDiscount {
+		return nil, fmt.Errorf("unknown resolution mode: %s", r.policy.Mode)
+	}
+	var candidates []candidate
+	for _, rule := range rules {
+		matched, err := r.cv.ValidateRuleContext(ctx, rule, cart)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", rule.ID, err)
+		}
+		if !matched {
+			continue
+		}
+		discounts, err := r.cv.ItemDiscounts(rule, cart)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", rule.ID, err)
+		}
+		c := candidate{rule: rule, variant: rule.Variant(cart.Customer), discounts: discounts}
+		for _, discount := range discounts {
+			c.total += discount.Amount
+		}
+		candidates = append(candidates, c)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].rule.SortOrder < candidates[j].rule.SortOrder })
+
+	resolution := &Resolution{}
+	caps := newDiscountCaps(r.policy)
+	if r.policy.Mode == ModeBestDiscount {
+		best := -1
+		for i, c := range candidates {
+			if best < 0 || c.total > candidates[best].total {
+				best = i
+			}
+		}
+		for i, c := range candidates {
+			if i != best {
+				resolution.suppress(c, SuppressedBestDiscount, candidates[best].rule.ID)
+			}
+		}
+		if best >= 0 {
+			resolution.apply(candidates[best], caps)
+		}
+		return resolution, nil
+	}
+	var stopped, exclusive *Rule
+	groups := make(map[string]int)
+	for i, c := range candidates {
+		winner, grouped := groups[c.rule.PriorityGroup]
+		switch {
+		case stopped != nil:
+			resolution.suppress(c, SuppressedStopRules, stopped.ID)
+		case exclusive != nil:
+			resolution.suppress(c, SuppressedExclusive, exclusive.ID)
+		case c.rule.PriorityGroup != "" && grouped:
+			resolution.suppress(c, SuppressedPriorityGroup, winner)
+		case c.rule.Exclusive && len(resolution.Applied) > 0:
+			resolution.suppress(c, SuppressedExclusive, resolution.Applied[0].Rule.ID)
+		default:
+			if !resolution.apply(c, caps) {
+				continue
+			}
+			if c.rule.PriorityGroup != "" {
+				groups[c.rule.PriorityGroup] = c.rule.ID
+			}
+			if c.rule.Exclusive {
+				exclusive = &candidates[i].rule
+			}
+			if c.rule.StopRulesProcessing {
+				stopped = &candidates[i].rule
+			}
+		}
+	}
+	return resolution, nil
+}
+
+// suppress records a rule that is not applied.
+func (res *Resolution) suppress(c candidate, reason SuppressionReason, byRuleID int) {
+	res.Suppressed = append(res.Suppressed, SuppressedRule{Rule: c.rule, Reason: reason, ByRuleID: byRuleID, Discount: c.total, Variant: c.variant})
+}
+
+// apply applies a rule within the caps. A rule whose whole discount is taken
+// away by the caps is suppressed instead, and apply returns false.
+func (res *Resolution) apply(c candidate, caps *discountCaps) bool {
+	applied := AppliedRule{Rule: c.rule, Variant: c.variant}
+	var reason SuppressionReason
+	for _, discount := range c.discounts {
+		amount, limitedBy := caps.take(discount)
+		if limitedBy != "" {
+			applied.Capped = true
+			reason = limitedBy
+		}
+		discount.Amount = amount
+		applied.Items = append(applied.Items, discount)
+		applied.Discount += amount
+	}
+	if applied.Discount == 0 && c.total > 0 {
+		res.suppress(c, reason, 0)
+		return false
+	}
+	res.Applied = append(res.Applied, applied)
+	res.TotalDiscount += applied.Discount
+	return true
+}
+
+// discountCaps tracks the discount given so far against the policy caps. An
+// item line is never discounted beyond its row total. Lines are told apart by
+// their position, so two lines of the same SKU each have their own limit.
+type discountCaps struct {
+	policy ResolutionPolicy
+	cart   float64
+	items  map[string]float64
+}
+
+// newDiscountCaps creates empty discount caps for the policy.
+func newDiscountCaps(policy ResolutionPolicy) *discountCaps {
+	return &discountCaps{policy: policy, items: make(map[string]float64)}
+}
+
+// take returns how much of an item discount fits within the caps and records
+// it, along with the cap that reduced it, if any.
+func (c *discountCaps) take(discount ItemDiscount) (float64, SuppressionReason) {
+	amount := discount.Amount
+	var limitedBy SuppressionReason
+
+	itemLimit := discount.Item.Price * float64(discount.Item.Quantity)
+	if c.policy.MaxItemDiscount > 0 {
+		itemLimit = math.Min(itemLimit, c.policy.MaxItemDiscount)
+	}
+	if room := math.Max(itemLimit-c.items[discount.Path], 0); amount > room {
+		amount, limitedBy = room, SuppressedItemCap
+	}
+	if c.policy.MaxCartDiscount > 0 {
+		if room := math.Max(c.policy.MaxCartDiscount-c.cart, 0); amount > room {
+			amount, limitedBy = room, SuppressedCartCap
+		}
+	}
+
+	c.items[discount.Path] += amount
+	c.cart += amount
+	return amount, limitedBy
+}
@@ -0,0 +1,166 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConflictResolver(t *testing.T) {
+	cart := Cart{
+		Items: []Item{
+			{SKU: "SHOE-1", Quantity: 1, Price: 100},
+			{SKU: "SOCK-1", Quantity: 2, Price: 10},
+		},
+		Subtotal: 120,
+	}
+	rules := func(change func(rules []Rule)) []Rule {
+		rules := []Rule{
+			{ID: 1, IsActive: true, SortOrder: 1, SimpleAction: ActionByPercent, DiscountAmount: 10},
+			{ID: 2, IsActive: true, SortOrder: 2, SimpleAction: ActionByFixed, DiscountAmount: 5},
+			{ID: 3, IsActive: true, SortOrder: 3, SimpleAction: ActionByPercent, DiscountAmount: 50, Exclusive: true},
+			{ID: 4, IsActive: true, SortOrder: 4, SimpleAction: ActionCartFixed, DiscountAmount: 20, PriorityGroup: "vip"},
+			{ID: 5, IsActive: true, SortOrder: 5, SimpleAction: ActionByPercent, DiscountAmount: 5, PriorityGroup: "vip"},
+			{ID: 6, IsActive: false, SortOrder: 0, SimpleAction: ActionByPercent, DiscountAmount: 90},
+		}
+		if change != nil {
+			change(rules)
+		}
+		return rules
+	}
+
+	tests := []struct {
+		name       string
+		policy     ResolutionPolicy
+		rules      []Rule
+		applied    []string
+		suppressed []string
+		total      float64
+	}{
+		{
+			name:       "Stack",
+			rules:      rules(nil),
+			applied:    []string{"1: 12.00", "2: 15.00", "4: 20.00"},
+			suppressed: []string{"3: exclusive by 1", "5: priority_group by 4"},
+			total:      47,
+		},
+		{
+			name:       "Stop rules processing",
+			rules:      rules(func(rules []Rule) { rules[0].StopRulesProcessing = true }),
+			applied:    []string{"1: 12.00"},
+			suppressed: []string{"2: stop_rules_processing by 1", "3: stop_rules_processing by 1", "4: stop_rules_processing by 1", "5: stop_rules_processing by 1"},
+			total:      12,
+		},
+		{
+			name:       "Exclusive rule first",
+			rules:      rules(func(rules []Rule) { rules[2].SortOrder = 0 }),
+			applied:    []string{"3: 60.00"},
+			suppressed: []string{"1: exclusive by 3", "2: exclusive by 3", "4: exclusive by 3", "5: exclusive by 3"},
+			total:      60,
+		},
+		{
+			name:       "Best discount wins",
+			policy:     ResolutionPolicy{Mode: ModeBestDiscount},
+			rules:      rules(nil),
+			applied:    []string{"3: 60.00"},
+			suppressed: []string{"1: best_discount by 3", "2: best_discount by 3", "4: best_discount by 3", "5: best_discount by 3"},
+			total:      60,
+		},
+		{
+			name:       "Cart cap",
+			policy:     ResolutionPolicy{MaxCartDiscount: 30},
+			rules:      rules(nil),
+			applied:    []string{"1: 12.00", "2: 15.00", "4: 3.00 capped"},
+			suppressed: []string{"3: exclusive by 1", "5: priority_group by 4"},
+			total:      30,
+		},
+		{
+			name:       "Cart cap reached",
+			policy:     ResolutionPolicy{MaxCartDiscount: 27},
+			rules:      rules(nil),
+			applied:    []string{"1: 12.00", "2: 15.00"},
+			suppressed: []string{"3: exclusive by 1", "4: cart_cap", "5: cart_cap"},
+			total:      27,
+		},
+		{
+			name:       "Item cap",
+			policy:     ResolutionPolicy{MaxItemDiscount: 11},
+			rules:      rules(nil),
+			applied:    []string{"1: 12.00", "2: 10.00 capped"},
+			suppressed: []string{"3: exclusive by 1", "4: item_cap", "5: item_cap"},
+			total:      22,
+		},
+		{
+			name: "Discounts never exceed the row total",
+			rules: []Rule{
+				{ID: 1, IsActive: true, SimpleAction: ActionByPercent, DiscountAmount: 80},
+				{ID: 2, IsActive: true, SimpleAction: ActionByPercent, DiscountAmount: 80},
+			},
+			applied: []string{"1: 96.00", "2: 24.00 capped"},
+			total:   120,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolution, err := NewConflictResolver(NewConditionValidator(), tt.policy).Resolve(tt.rules, cart)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			var applied, suppressed []string
+			for _, rule := range resolution.Applied {
+				summary := fmt.Sprintf("%d: %.2f", rule.Rule.ID, rule.Discount)
+				if rule.Capped {
+					summary += " capped"
+				}
+				applied = append(applied, summary)
+			}
+			for _, rule := range resolution.Suppressed {
+				summary := fmt.Sprintf("%d: %s", rule.Rule.ID, rule.Reason)
+				if rule.ByRuleID != 0 {
+					summary += fmt.Sprintf(" by %d", rule.ByRuleID)
+				}
+				suppressed = append(suppressed, summary)
+			}
+			if !reflect.DeepEqual(applied, tt.applied) {
+				t.Errorf("Applied = %v, want %v", applied, tt.applied)
+			}
+			if !reflect.DeepEqual(suppressed, tt.suppressed) {
+				t.Errorf("Suppressed = %v, want %v", suppressed, tt.suppressed)
+			}
+			if math.Abs(resolution.TotalDiscount-tt.total) > 1e-9 {
+				t.Errorf("TotalDiscount = %v, want %v", resolution.TotalDiscount, tt.total)
+			}
+		})
+	}
+
+	t.Run("Lines of the same SKU", func(t *testing.T) {
+		cart := Cart{Items: []Item{{SKU: "A", Quantity: 1, Price: 10}, {SKU: "A", Quantity: 1, Price: 10}}, Subtotal: 20}
+		tests := []struct {
+			policy ResolutionPolicy
+			total  float64
+			capped bool
+		}{
+			{total: 20},
+			{policy: ResolutionPolicy{MaxItemDiscount: 6}, total: 12, capped: true},
+		}
+		for _, tt := range tests {
+			rules := []Rule{{ID: 1, IsActive: true, SimpleAction: ActionByPercent, DiscountAmount: 100}}
+			resolution, err := NewConflictResolver(NewConditionValidator(), tt.policy).Resolve(rules, cart)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if resolution.TotalDiscount != tt.total || resolution.Applied[0].Capped != tt.capped {
+				t.Errorf("Resolve() with %+v = %v, capped %v, want %v, capped %v",
+					tt.policy, resolution.TotalDiscount, resolution.Applied[0].Capped, tt.total, tt.capped)
+			}
+		}
+	})
+
+	t.Run("Unknown mode", func(t *testing.T) {
+		if _, err := NewConflictResolver(NewConditionValidator(), ResolutionPolicy{Mode: "random"}).Resolve(rules(nil), cart); err == nil {
+			t.Error("Resolve() error = nil, want an error for an unknown mode")
+		}
+	})
+}
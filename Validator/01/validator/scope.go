@@ -0,0 +1,70 @@
+package validator
+
+import "reflect"
+
+// InScope reports whether the cart's website and customer group are among
+// those the rule is limited to. A rule without websites or customer groups
+// is not limited by them.
+func (r Rule) InScope(cart Cart) bool {
+	return scopeIncludes(r.WebsiteIDs, cart.WebsiteID) && scopeIncludes(r.CustomerGroupIDs, cart.Customer.GroupID)
+}
+
+// Label returns the label of the rule for a store view, falling back to the
+// default label (store 0) and then to the rule name.
+func (r Rule) Label(storeID int) string {
+	if label := r.StoreLabels[storeID]; label != "" {
+		return label
+	}
+	if label := r.StoreLabels[0]; label != "" {
+		return label
+	}
+	return r.Name
+}
+
+// scopesOverlap reports whether some cart is in the scope of both rules.
+// Rules limited to different variants of the same experiment never overlap.
+func scopesOverlap(rule, other Rule) bool {
+	if !scopeListsOverlap(rule.WebsiteIDs, other.WebsiteIDs) || !scopeListsOverlap(rule.CustomerGroupIDs, other.CustomerGroupIDs) {
+		return false
+	}
+	if rule.Experiment == nil || other.Experiment == nil || len(rule.Variants) == 0 || len(other.Variants) == 0 {
+		return true
+	}
+	if !reflect.DeepEqual(*rule.Experiment, *other.Experiment) {
+		return true
+	}
+	for _, variant := range rule.Variants {
+		for _, otherVariant := range other.Variants {
+			if variant == otherVariant {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scopeIncludes reports whether a scope list includes id, an empty list including every ID.
+func scopeIncludes(ids []int, id int) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, scoped := range ids {
+		if scoped == id {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeListsOverlap reports whether two scope lists include a common ID.
+func scopeListsOverlap(ids, others []int) bool {
+	if len(ids) == 0 || len(others) == 0 {
+		return true
+	}
+	for _, id := range ids {
+		if scopeIncludes(others, id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuleScope(t *testing.T) {
+	subtotal := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "50"},
+	}}
+	rules := []Rule{
+		{ID: 1, IsActive: true, Conditions: subtotal},
+		{ID: 2, IsActive: true, Conditions: subtotal, WebsiteIDs: []int{1}},
+		{ID: 3, IsActive: true, Conditions: subtotal, WebsiteIDs: []int{2}, CustomerGroupIDs: []int{1, 2}},
+		{ID: 4, IsActive: true, Conditions: subtotal, CustomerGroupIDs: []int{3}},
+	}
+
+	tests := []struct {
+		name string
+		cart Cart
+		want []int
+	}{
+		{
+			name: "Main website, general group",
+			cart: Cart{Subtotal: 100, WebsiteID: 1, Customer: Customer{GroupID: 1}},
+			want: []int{1, 2},
+		},
+		{
+			name: "Second website, wholesale group",
+			cart: Cart{Subtotal: 100, WebsiteID: 2, Customer: Customer{GroupID: 2}},
+			want: []int{1, 3},
+		},
+		{
+			name: "Second website, retailer group",
+			cart: Cart{Subtotal: 100, WebsiteID: 2, Customer: Customer{GroupID: 3}},
+			want: []int{1, 4},
+		},
+		{
+			name: "Conditions still apply in scope",
+			cart: Cart{Subtotal: 10, WebsiteID: 1, Customer: Customer{GroupID: 1}},
+			want: nil,
+		},
+	}
+
+	cv := NewConditionValidator()
+	index := NewRuleIndex(rules)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, rule := range rules {
+				valid, err := cv.ValidateRule(rule, tt.cart)
+				if err != nil {
+					t.Fatalf("ValidateRule() error = %v", err)
+				}
+				if valid {
+					got = append(got, rule.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRule() matched %v, want %v", got, tt.want)
+			}
+
+			// The index must keep every rule in scope
+			candidates := make(map[int]bool)
+			for _, i := range index.Candidates(tt.cart) {
+				candidates[rules[i].ID] = true
+			}
+			for _, id := range tt.want {
+				if !candidates[id] {
+					t.Errorf("Candidates() pruned rule %d", id)
+				}
+			}
+		})
+	}
+
+	t.Run("Index prunes rules out of scope", func(t *testing.T) {
+		got := index.Candidates(Cart{Subtotal: 100, WebsiteID: 1, Customer: Customer{GroupID: 1}})
+		if !reflect.DeepEqual(got, []int{0, 1}) {
+			t.Errorf("Candidates() = %v, want [0 1]", got)
+		}
+	})
+
+	t.Run("Disjoint scopes never overlap", func(t *testing.T) {
+		analysis := NewAnalyzer().AnalyzeRules(rules)
+		want := []RuleOverlap{{RuleID: 1, OtherRuleID: 2}, {RuleID: 1, OtherRuleID: 3}, {RuleID: 1, OtherRuleID: 4}, {RuleID: 2, OtherRuleID: 4}}
+		if !reflect.DeepEqual(analysis.Overlaps, want) {
+			t.Errorf("Overlaps = %v, want %v", analysis.Overlaps, want)
+		}
+	})
+
+	t.Run("Customer change moves the cart out of scope", func(t *testing.T) {
+		evaluator := NewIncrementalEvaluator(cv, rules)
+		evaluator.Load(Cart{Subtotal: 100, WebsiteID: 2, Customer: Customer{GroupID: 2}})
+		flips, err := evaluator.Apply(CartChange{Kind: CustomerChanged, Customer: Customer{GroupID: 3}})
+		if err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		want := []RuleFlip{{RuleIndex: 2, RuleID: 3}, {RuleIndex: 3, RuleID: 4, Matched: true}}
+		if !reflect.DeepEqual(flips, want) {
+			t.Errorf("Apply() = %+v, want %+v", flips, want)
+		}
+	})
+}
+
+func TestRuleLabel(t *testing.T) {
+	rule := Rule{Name: "Spring sale", StoreLabels: map[int]string{0: "10% off", 2: "10 % de remise"}}
+	tests := []struct {
+		storeID int
+		want    string
+	}{
+		{2, "10 % de remise"},
+		{1, "10% off"},
+		{0, "10% off"},
+	}
+	for _, tt := range tests {
+		if got := rule.Label(tt.storeID); got != tt.want {
+			t.Errorf("Label(%d) = %q, want %q", tt.storeID, got, tt.want)
+		}
+	}
+	if got := (Rule{Name: "Spring sale"}).Label(1); got != "Spring sale" {
+		t.Errorf("Label() without labels = %q, want the rule name", got)
+	}
+}
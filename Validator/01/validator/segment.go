@@ -0,0 +1,244 @@
+package validator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SegmentProvider returns the names of the customer segments a customer
+// belongs to, such as "vip" or "lapsed_90_days". Implementations must be safe
+// for concurrent use.
+type SegmentProvider interface {
+	Segments(customer Customer) ([]string, error)
+}
+
+// validateSegment validates a segment condition: "Customer segment is (not)
+// one of the given segments". Operators are ==, !=, () and !(), with == by
+// default, and segment names match case-insensitively.
+func (cv *ConditionValidator) validateSegment(condition Condition, cart Cart) (bool, error) {
+	if cv.segments == nil {
+		return false, fmt.Errorf("segment condition requires a segment provider")
+	}
+	wanted, err := segmentNames(condition.Value)
+	if err != nil {
+		return false, err
+	}
+	segments, err := cv.segments.Segments(cart.Customer)
+	if err != nil {
+		return false, fmt.Errorf("failed to get customer segments: %v", err)
+	}
+
+	member := false
+	for _, segment := range segments {
+		if wanted[strings.ToLower(segment)] {
+			member = true
+			break
+		}
+	}
+	switch condition.Operator {
+	case "==", "()", "":
+		return member, nil
+	case "!=", "!()":
+		return !member, nil
+	default:
+		return false, fmt.Errorf("unsupported segment operator: %s", condition.Operator)
+	}
+}
+
+// segmentNames returns the lower-cased segment names of a condition value, a
+// name or a list of names separated by commas.
+func segmentNames(value interface{}) (map[string]bool, error) {
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []interface{}:
+		for _, name := range v {
+			names = append(names, fmt.Sprint(name))
+		}
+	case []string:
+		names = v
+	default:
+		return nil, fmt.Errorf("segment condition value must be a segment name or a list of names, got %T", value)
+	}
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			wanted[name] = true
+		}
+	}
+	return wanted, nil
+}
+
+// SegmentRule defines a segment by a condition tree of Customer conditions. Besides
+// the usual customer attributes, the condition may use days_since_last_login
+// and days_since_created, counted in whole days. A customer who never logged in
+// counts from the day the account was created.
+type SegmentRule struct {
+	Name      string
+	Condition Condition
+}
+
+// RuleSegmentProvider assigns customers to segments defined by rules over
+// their fields, e.g. "vip" for total_spent >= 1000 or "first_order" for
+// orders_count == 0.
+type RuleSegmentProvider struct {
+	cv    *ConditionValidator
+	rules []SegmentRule
+	now   func() time.Time
+}
+
+// NewRuleSegmentProvider creates a RuleSegmentProvider evaluating the segment
+// rules with the validator, which must not itself use this provider.
+func NewRuleSegmentProvider(validator *ConditionValidator, rules []SegmentRule) *RuleSegmentProvider {
+	return &RuleSegmentProvider{cv: validator, rules: rules, now: time.Now}
+}
+
+// WithClock makes the provider count days relative to the time now returns.
+func (p *RuleSegmentProvider) WithClock(now func() time.Time) *RuleSegmentProvider {
+	p.now = now
+	return p
+}
+
+// Segments returns the segments whose rule the customer matches, in rule order.
+func (p *RuleSegmentProvider) Segments(customer Customer) ([]string, error) {
+	now := p.now()
+	attributes := make(map[string]interface{}, len(customer.Attributes)+2)
+	for k, v := range customer.Attributes {
+		attributes[k] = v
+	}
+	lastLogin := customer.LastLoginAt
+	if lastLogin.IsZero() {
+		lastLogin = customer.CreatedAt
+	}
+	attributes["days_since_last_login"] = daysSince(now, lastLogin)
+	attributes["days_since_created"] = daysSince(now, customer.CreatedAt)
+	customer.Attributes = attributes
+
+	var segments []string
+	for _, rule := range p.rules {
+		matched, err := p.cv.Validate(rule.Condition, Cart{Customer: customer})
+		if err != nil {
+			return nil, fmt.Errorf("segment %s: %v", rule.Name, err)
+		}
+		if matched {
+			segments = append(segments, rule.Name)
+		}
+	}
+	return segments, nil
+}
+
+// daysSince returns the whole days from t to now, or 0 when t is unknown.
+func daysSince(now, t time.Time) int {
+	if t.IsZero() || t.After(now) {
+		return 0
+	}
+	return int(math.Floor(now.Sub(t).Hours() / 24))
+}
+
+// StaticSegmentProvider assigns customers to segments from a fixed list, e.g.
+// exported from a CRM. Customers are identified by ID or by email, ignoring
+// case. The list is a JSON object from segment name to its members:
+//
+//	{"vip": [12, "ann@example.com"], "staff": ["ops@example.com"]}
+type StaticSegmentProvider struct {
+	path    string
+	mu      sync.RWMutex
+	members map[string][]string // Customer key to its sorted segments
+}
+
+// NewStaticSegmentProvider creates a StaticSegmentProvider with the list read from r.
+func NewStaticSegmentProvider(r io.Reader) (*StaticSegmentProvider, error) {
+	p := &StaticSegmentProvider{}
+	if err := p.read(r); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadStaticSegmentProvider creates a StaticSegmentProvider with the list in
+// the file at path, which Reload reads again.
+func LoadStaticSegmentProvider(path string) (*StaticSegmentProvider, error) {
+	p := &StaticSegmentProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the segment file again. On error the previous list is kept.
+func (p *StaticSegmentProvider) Reload() error {
+	if p.path == "" {
+		return fmt.Errorf("segment list was not loaded from a file")
+	}
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := p.read(f); err != nil {
+		return fmt.Errorf("%s: %v", p.path, err)
+	}
+	return nil
+}
+
+// read replaces the list with the one read from r.
+func (p *StaticSegmentProvider) read(r io.Reader) error {
+	var list map[string][]interface{}
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return fmt.Errorf("invalid segment list: %v", err)
+	}
+	members := make(map[string][]string)
+	for segment, customers := range list {
+		for _, customer := range customers {
+			var key string
+			switch v := customer.(type) {
+			case string:
+				key = strings.ToLower(strings.TrimSpace(v))
+			case float64:
+				key = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				return fmt.Errorf("segment %s: customer must be an ID or an email, got %v", segment, customer)
+			}
+			members[key] = append(members[key], segment)
+		}
+	}
+	for _, segments := range members {
+		sort.Strings(segments)
+	}
+
+	p.mu.Lock()
+	p.members = members
+	p.mu.Unlock()
+	return nil
+}
+
+// Segments returns the segments listing the customer's ID or email.
+func (p *StaticSegmentProvider) Segments(customer Customer) ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var segments []string
+	if customer.ID != 0 {
+		segments = append(segments, p.members[strconv.Itoa(customer.ID)]...)
+	}
+	if customer.Email != "" {
+		segments = append(segments, p.members[strings.ToLower(customer.Email)]...)
+	}
+	// A customer listed by both ID and email belongs to the segment once
+	sort.Strings(segments)
+	unique := segments[:0]
+	for i, segment := range segments {
+		if i == 0 || segment != segments[i-1] {
+			unique = append(unique, segment)
+		}
+	}
+	return unique, nil
+}
@@ -0,0 +1,198 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRuleSegmentProvider(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	customerCondition := func(attribute, operator, value string) Condition {
+		return Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeCustomer, Attribute: attribute, Operator: operator, Value: value},
+		}}
+	}
+	provider := NewRuleSegmentProvider(NewConditionValidator(), []SegmentRule{
+		{Name: "vip", Condition: customerCondition("total_spent", ">=", "1000")},
+		{Name: "lapsed_90_days", Condition: customerCondition("days_since_last_login", ">=", "90")},
+		{Name: "first_order", Condition: customerCondition("orders_count", "==", "0")},
+		{Name: "new", Condition: customerCondition("days_since_created", "<", "30")},
+	}).WithClock(func() time.Time { return now })
+
+	tests := []struct {
+		name     string
+		customer Customer
+		want     []string
+	}{
+		{
+			name:     "Loyal big spender",
+			customer: Customer{Orders: 12, TotalSpent: 2400, CreatedAt: now.AddDate(-2, 0, 0), LastLoginAt: now.AddDate(0, 0, -3)},
+			want:     []string{"vip"},
+		},
+		{
+			name:     "Lapsed customer",
+			customer: Customer{Orders: 2, TotalSpent: 150, CreatedAt: now.AddDate(-1, 0, 0), LastLoginAt: now.AddDate(0, 0, -90)},
+			want:     []string{"lapsed_90_days"},
+		},
+		{
+			name:     "Not yet lapsed",
+			customer: Customer{Orders: 2, TotalSpent: 150, CreatedAt: now.AddDate(-1, 0, 0), LastLoginAt: now.AddDate(0, 0, -90).Add(time.Hour)},
+			want:     nil,
+		},
+		{
+			name:     "New customer who never logged in",
+			customer: Customer{CreatedAt: now.AddDate(0, 0, -2)},
+			want:     []string{"first_order", "new"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := provider.Segments(tt.customer)
+			if err != nil {
+				t.Fatalf("Segments() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Segments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticSegmentProvider(t *testing.T) {
+	provider, err := NewStaticSegmentProvider(strings.NewReader(`{"vip": [12, "Ann@Example.com"], "staff": ["ops@example.com", 12]}`))
+	if err != nil {
+		t.Fatalf("NewStaticSegmentProvider() error = %v", err)
+	}
+	tests := []struct {
+		customer Customer
+		want     []string
+	}{
+		{Customer{ID: 12, Email: "ann@example.com"}, []string{"staff", "vip"}},
+		{Customer{Email: "ANN@example.com"}, []string{"vip"}},
+		{Customer{ID: 13}, nil},
+	}
+	for _, tt := range tests {
+		got, err := provider.Segments(tt.customer)
+		if err != nil {
+			t.Fatalf("Segments() error = %v", err)
+		}
+		if len(got) == 0 && len(tt.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Segments(%+v) = %v, want %v", tt.customer, got, tt.want)
+		}
+	}
+
+	if _, err := NewStaticSegmentProvider(strings.NewReader(`{"vip": [true]}`)); err == nil {
+		t.Error("NewStaticSegmentProvider() error = nil, want an error for a malformed member")
+	}
+
+	t.Run("Reload", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "segments.json")
+		if err := os.WriteFile(path, []byte(`{"vip": [1]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		provider, err := LoadStaticSegmentProvider(path)
+		if err != nil {
+			t.Fatalf("LoadStaticSegmentProvider() error = %v", err)
+		}
+		if err := os.WriteFile(path, []byte(`{"vip": [2]}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := provider.Reload(); err != nil {
+			t.Fatalf("Reload() error = %v", err)
+		}
+		if got, _ := provider.Segments(Customer{ID: 2}); !reflect.DeepEqual(got, []string{"vip"}) {
+			t.Errorf("Segments() after Reload = %v, want [vip]", got)
+		}
+
+		// A broken file keeps the previous list
+		if err := os.WriteFile(path, []byte(`{"vip": `), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := provider.Reload(); err == nil {
+			t.Error("Reload() error = nil, want an error for a broken file")
+		}
+		if got, _ := provider.Segments(Customer{ID: 2}); !reflect.DeepEqual(got, []string{"vip"}) {
+			t.Errorf("Segments() after failed Reload = %v, want [vip]", got)
+		}
+	})
+}
+
+func TestSegmentCondition(t *testing.T) {
+	provider, err := NewStaticSegmentProvider(strings.NewReader(`{"vip": [1], "staff": [2]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv := NewConditionValidator().WithSegmentProvider(provider)
+	vip := Cart{Customer: Customer{ID: 1}}
+
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+		wantErr   bool
+	}{
+		{"Is", Condition{Type: TypeSegment, Operator: "==", Value: "VIP"}, true, false},
+		{"Default operator", Condition{Type: TypeSegment, Value: "vip"}, true, false},
+		{"Is not", Condition{Type: TypeSegment, Operator: "!=", Value: "vip"}, false, false},
+		{"Is one of", Condition{Type: TypeSegment, Operator: "()", Value: []interface{}{"staff", "vip"}}, true, false},
+		{"Is not one of", Condition{Type: TypeSegment, Operator: "!()", Value: "staff, new"}, true, false},
+		{"Unsupported operator", Condition{Type: TypeSegment, Operator: ">", Value: "vip"}, false, true},
+		{"Malformed value", Condition{Type: TypeSegment, Operator: "==", Value: 3.0}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cv.Validate(tt.condition, vip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Without a provider", func(t *testing.T) {
+		if _, err := NewConditionValidator().Validate(tests[0].condition, vip); err == nil {
+			t.Error("Validate() error = nil, want an error without a segment provider")
+		}
+	})
+
+	t.Run("Partial evaluation decides segments", func(t *testing.T) {
+		condition := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeSegment, Operator: "==", Value: "staff"},
+			{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"},
+		}}
+		residual, err := NewPartialEvaluator(cv, vip.Customer, nil).Specialize(condition)
+		if err != nil {
+			t.Fatalf("Specialize() error = %v", err)
+		}
+		if !residual.Decided || residual.Matched {
+			t.Errorf("Specialize() = %+v, want decided not to match", residual)
+		}
+	})
+
+	t.Run("Lint and render", func(t *testing.T) {
+		condition := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeSegment, Operator: "()", Value: []interface{}{"vip", "staff"}},
+			{Type: TypeSegment, Operator: "{}", Value: ""},
+		}}
+		var codes []string
+		for _, diagnostic := range NewLinter().Lint(condition) {
+			codes = append(codes, diagnostic.Path+" "+diagnostic.Code)
+		}
+		want := []string{"$.conditions[1] " + LintInvalidOperator, "$.conditions[1] " + LintMalformedValue}
+		if !reflect.DeepEqual(codes, want) {
+			t.Errorf("Lint() = %v, want %v", codes, want)
+		}
+		if got := NewRenderer().Sentence(condition.Conditions[0]); got != "Customer segment is one of vip, staff" {
+			t.Errorf("Sentence() = %q", got)
+		}
+	})
+}
@@ -0,0 +1,406 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedPath is returned for conditions on attribute paths, such as
+// addresses[*].country or attributes.color, which have no columns to translate to.
+var ErrUnsupportedPath = errors.New("attribute paths cannot be translated to SQL")
+
+// SQLTable maps the attributes of one condition type to a table. Columns are
+// SQL expressions qualified with Alias, and Join relates a row to its parent:
+// the order for addresses, customers and items, the item for categories.
+// A table without a Name has its columns on the order table.
+type SQLTable struct {
+	Name    string
+	Alias   string
+	Join    string
+	Columns map[string]string // Attribute code to column
+}
+
+// SQLMapping describes the order-history schema conditions are translated for.
+// Where clauses are used in a query on the order table, which Join expressions refer to by its alias.
+type SQLMapping struct {
+	Address SQLTable
+	// BillingAddress maps the fields of billing_address. conditions; their
+	// quote totals, such as base_subtotal, are read from Address.
+	BillingAddress SQLTable
+	Customer       SQLTable
+	Item           SQLTable
+	Categories     SQLTable // One row per item category, with the category in Columns["category_ids"]
+	// Placeholder returns the n-th (1-based) parameter placeholder; nil means "?".
+	Placeholder func(n int) string
+}
+
+// DefaultSQLMapping returns a mapping for a schema of orders (o) with the
+// shipping address and billing_ prefixed billing address on the order,
+// customers (c), order_items (i) and order_item_categories (ic).
+func DefaultSQLMapping() SQLMapping {
+	return SQLMapping{
+		Address: SQLTable{Columns: map[string]string{
+			"base_subtotal": "o.subtotal", "subtotal": "o.subtotal",
+			"total_qty": "o.total_qty", "weight": "o.weight",
+			"country": "o.country", "country_id": "o.country",
+			"region": "o.region", "region_id": "o.region_id",
+			"city": "o.city", "postcode": "o.postcode",
+		}},
+		BillingAddress: SQLTable{Columns: map[string]string{
+			"country": "o.billing_country", "country_id": "o.billing_country",
+			"region": "o.billing_region", "region_id": "o.billing_region_id",
+			"city": "o.billing_city", "postcode": "o.billing_postcode",
+		}},
+		Customer: SQLTable{Name: "customers", Alias: "c", Join: "c.id = o.customer_id", Columns: map[string]string{
+			"id": "c.id", "group_id": "c.group_id", "email": "c.email",
+			"firstname": "c.firstname", "lastname": "c.lastname", "gender": "c.gender",
+			"dob": "c.dob", "created_at": "c.created_at", "orders_count": "c.orders_count",
+			"total_spent": "c.total_spent",
+		}},
+		Item: SQLTable{Name: "order_items", Alias: "i", Join: "i.order_id = o.id", Columns: map[string]string{
+			"sku": "i.sku", "name": "i.name", "product_type": "i.product_type",
+			"price": "i.price", "quote_item_price": "i.price", "final_price": "i.price",
+			"quantity": "i.qty", "qty": "i.qty", "quote_item_qty": "i.qty",
+			"row_total": "i.price * i.qty", "base_row_total": "i.price * i.qty", "quote_item_row_total": "i.price * i.qty",
+			"weight": "i.weight",
+		}},
+		Categories: SQLTable{Name: "order_item_categories", Alias: "ic", Join: "ic.item_id = i.id", Columns: map[string]string{
+			"category_ids": "ic.category_id",
+		}},
+	}
+}
+
+// SQLTranslator translates condition trees into parameterised SQL WHERE clauses
+// over order history, so analysts can find the orders a rule would have matched.
+// Order lines are treated as flat rows: configurable children and bundle
+// options are not expanded as they are by the validator. Customer conditions
+// on a separate table never match orders without a customer row. Conditions on
+// attribute paths return errors wrapping ErrUnsupportedPath.
+//
+// String attributes are compared as text, while the validator compares two
+// numeric strings as numbers: sku == "10" matches an item with SKU "10.0" in a
+// cart but not in the database. Use the clauses to narrow down orders and
+// validate the carts for exact results.
+type SQLTranslator struct {
+	cv      *ConditionValidator
+	mapping SQLMapping
+}
+
+// NewSQLTranslator creates a translator for the given mapping.
+func NewSQLTranslator(mapping SQLMapping) *SQLTranslator {
+	return &SQLTranslator{cv: NewConditionValidator(), mapping: mapping}
+}
+
+// sqlQuery collects the arguments of a clause being built.
+type sqlQuery struct {
+	args []interface{}
+}
+
+// Where returns a boolean SQL expression over the order table that is true for
+// orders matching the condition, and the arguments for its placeholders.
+func (t *SQLTranslator) Where(condition Condition) (string, []interface{}, error) {
+	if err := t.cv.CheckLimits(condition); err != nil {
+		return "", nil, err
+	}
+	query := &sqlQuery{}
+	clause, err := t.cart(query, condition)
+	if err != nil {
+		return "", nil, err
+	}
+	return clause, query.args, nil
+}
+
+// cart translates a cart-level condition.
+func (t *SQLTranslator) cart(query *sqlQuery, condition Condition) (string, error) {
+	switch condition.Type {
+	case TypeCombine:
+		return t.combine(condition, func(subCondition Condition) (string, error) {
+			return t.cart(query, subCondition)
+		})
+	case TypeProductFound:
+		filter, err := t.items(query, condition)
+		if err != nil {
+			return "", err
+		}
+		clause := "EXISTS (" + t.subquery(t.mapping.Item, "1", filter) + ")"
+		if !isTrueValue(condition.Value) {
+			clause = "NOT " + clause
+		}
+		return clause, nil
+	case TypeProduct:
+		filter, err := t.item(query, condition)
+		if err != nil {
+			return "", err
+		}
+		return "EXISTS (" + t.subquery(t.mapping.Item, "1", filter) + ")", nil
+	case TypeSubselect:
+		column, ok := t.mapping.Item.Columns[strings.ToLower(condition.Attribute)]
+		if !ok {
+			return "", fmt.Errorf("no column mapped for item attribute %s", condition.Attribute)
+		}
+		filter, err := t.items(query, condition)
+		if err != nil {
+			return "", err
+		}
+		total := "(" + t.subquery(t.mapping.Item, "COALESCE(SUM("+column+"), 0)", filter) + ")"
+		return t.comparison(query, total, KindNumber, condition)
+	case TypeAddress:
+		billing, attribute := splitAddressAttribute(condition.Attribute)
+		condition.Attribute = attribute
+		if billing && !cartAddressAttributes[strings.ToLower(attribute)] {
+			return t.attribute(query, t.mapping.BillingAddress, TypeAddress, condition)
+		}
+		return t.attribute(query, t.mapping.Address, TypeAddress, condition)
+	case TypeCustomer:
+		return t.attribute(query, t.mapping.Customer, TypeCustomer, condition)
+	case TypeSegment:
+		return "", fmt.Errorf("customer segments have no SQL mapping")
+	default:
+		return "", fmt.Errorf("unknown condition type: %s", condition.Type)
+	}
+}
+
+// items translates the item subconditions of a Found or Subselect condition.
+func (t *SQLTranslator) items(query *sqlQuery, condition Condition) (string, error) {
+	filter := condition
+	filter.Type, filter.Value = TypeProductCombine, "1"
+	return t.item(query, filter)
+}
+
+// item translates an item-level condition over the item table alias.
+func (t *SQLTranslator) item(query *sqlQuery, condition Condition) (string, error) {
+	switch condition.Type {
+	case TypeProductCombine:
+		return t.combine(condition, func(subCondition Condition) (string, error) {
+			return t.item(query, subCondition)
+		})
+	case TypeProduct:
+		if isAttributePath(condition.Attribute) {
+			return "", fmt.Errorf("item attribute %s: %w", condition.Attribute, ErrUnsupportedPath)
+		}
+		if strings.ToLower(condition.Attribute) == "category_ids" {
+			return t.categories(query, condition)
+		}
+		column, ok := t.mapping.Item.Columns[strings.ToLower(condition.Attribute)]
+		if !ok {
+			return "", fmt.Errorf("no column mapped for item attribute %s", condition.Attribute)
+		}
+		kind, _ := LookupAttribute(TypeProduct, condition.Attribute)
+		return t.comparison(query, column, kind, condition)
+	default:
+		return "", fmt.Errorf("condition type %s cannot be translated for an item", condition.Type)
+	}
+}
+
+// categories translates a category condition into a subquery on the category table.
+func (t *SQLTranslator) categories(query *sqlQuery, condition Condition) (string, error) {
+	column, ok := t.mapping.Categories.Columns["category_ids"]
+	if !ok {
+		return "", fmt.Errorf("no column mapped for item attribute %s", condition.Attribute)
+	}
+	exists := func(filter string) string {
+		return "EXISTS (" + t.subquery(t.mapping.Categories, "1", filter) + ")"
+	}
+	switch condition.Operator {
+	case "()", "!()":
+		in, err := t.comparison(query, column, KindNumber, Condition{Operator: "()", Value: condition.Value})
+		if err != nil {
+			return "", err
+		}
+		if condition.Operator == "!()" {
+			return "NOT " + exists(in), nil
+		}
+		return exists(in), nil
+	case "null":
+		return "NOT " + exists(""), nil
+	case "notnull":
+		return exists(""), nil
+	default:
+		return "", fmt.Errorf("operator %s cannot be translated for category_ids", condition.Operator)
+	}
+}
+
+// attribute translates an address or customer comparison, through a subquery
+// when the attributes live outside the order table.
+func (t *SQLTranslator) attribute(query *sqlQuery, table SQLTable, conditionType string, condition Condition) (string, error) {
+	if isAttributePath(condition.Attribute) {
+		return "", fmt.Errorf("%s attribute %s: %w", shortType(conditionType), condition.Attribute, ErrUnsupportedPath)
+	}
+	column, ok := table.Columns[strings.ToLower(condition.Attribute)]
+	if !ok {
+		return "", fmt.Errorf("no column mapped for %s attribute %s", shortType(conditionType), condition.Attribute)
+	}
+	kind, _ := LookupAttribute(conditionType, condition.Attribute)
+	clause, err := t.comparison(query, column, kind, condition)
+	if err != nil || table.Name == "" {
+		return clause, err
+	}
+	return "EXISTS (" + t.subquery(table, "1", clause) + ")", nil
+}
+
+// combine joins translated subconditions with AND or OR, negating each for FALSE combines.
+func (t *SQLTranslator) combine(condition Condition, translate func(Condition) (string, error)) (string, error) {
+	if len(condition.Conditions) == 0 {
+		return "1 = 1", nil
+	}
+	var separator string
+	switch condition.Aggregator {
+	case "all", "":
+		separator = " AND "
+	case "any":
+		separator = " OR "
+	default:
+		return "", fmt.Errorf("unknown aggregator: %s", condition.Aggregator)
+	}
+
+	parts := make([]string, len(condition.Conditions))
+	for i, subCondition := range condition.Conditions {
+		clause, err := translate(subCondition)
+		if err != nil {
+			return "", err
+		}
+		if isTrueValue(condition.Value) {
+			parts[i] = "(" + clause + ")"
+		} else {
+			parts[i] = "NOT (" + clause + ")"
+		}
+	}
+	return "(" + strings.Join(parts, separator) + ")", nil
+}
+
+// subquery selects from a table joined to its parent, filtered by filter.
+func (t *SQLTranslator) subquery(table SQLTable, selection, filter string) string {
+	where := table.Join
+	if filter != "" {
+		where += " AND " + filter
+	}
+	return fmt.Sprintf("SELECT %s FROM %s %s WHERE %s", selection, table.Name, table.Alias, where)
+}
+
+// comparison translates a comparison of a column, mirroring compareValues:
+// "contains" and "like" are case-insensitive and unanchored, and null means
+// a missing or zero value. Unlike compareValues, string columns are compared
+// as text even when both sides are numbers.
+func (t *SQLTranslator) comparison(query *sqlQuery, column string, kind AttributeKind, condition Condition) (string, error) {
+	// Missing values compare as the zero value, as they do on a Cart, which
+	// also keeps NOT from turning SQL NULLs into non-matches
+	zero := "''"
+	switch kind {
+	case KindNumber, KindBool:
+		zero = "0"
+	case KindDate:
+		zero = "'0001-01-01 00:00:00'"
+	}
+	column = fmt.Sprintf("COALESCE(%s, %s)", column, zero)
+
+	switch condition.Operator {
+	case "==", "!=", ">", ">=", "<", "<=":
+		arg, err := t.arg(kind, condition.Value)
+		if err != nil {
+			return "", err
+		}
+		operator := condition.Operator
+		if operator == "==" {
+			operator = "="
+		} else if operator == "!=" {
+			operator = "<>"
+		}
+		return fmt.Sprintf("%s %s %s", column, operator, t.placeholder(query, arg)), nil
+	case "()", "!()":
+		values, ok := condition.Value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("in/nin operator requires a slice value")
+		}
+		if len(values) == 0 {
+			if condition.Operator == "()" {
+				return "1 = 0", nil
+			}
+			return "1 = 1", nil
+		}
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			arg, err := t.arg(kind, value)
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = t.placeholder(query, arg)
+		}
+		operator := "IN"
+		if condition.Operator == "!()" {
+			operator = "NOT IN"
+		}
+		return fmt.Sprintf("%s %s (%s)", column, operator, strings.Join(placeholders, ", ")), nil
+	case "{}", "!{}", "like", "nlike":
+		value, ok := condition.Value.(string)
+		if !ok {
+			return "", fmt.Errorf("%s operator requires a string value", condition.Operator)
+		}
+		pattern := "%" + t.escapeLike(strings.ToLower(value)) + "%"
+		if condition.Operator == "like" || condition.Operator == "nlike" {
+			parts := strings.Split(strings.ToLower(value), "%")
+			for i, part := range parts {
+				parts[i] = t.escapeLike(part)
+			}
+			pattern = "%" + strings.Join(parts, "%") + "%"
+		}
+		operator := "LIKE"
+		if condition.Operator == "!{}" || condition.Operator == "nlike" {
+			operator = "NOT LIKE"
+		}
+		return fmt.Sprintf("LOWER(%s) %s %s ESCAPE '!'", column, operator, t.placeholder(query, pattern)), nil
+	case "null":
+		return fmt.Sprintf("%s = %s", column, zero), nil
+	case "notnull":
+		return fmt.Sprintf("%s <> %s", column, zero), nil
+	default:
+		return "", fmt.Errorf("unknown operator: %s", condition.Operator)
+	}
+}
+
+// arg converts a condition value to a query argument of the attribute's kind.
+func (t *SQLTranslator) arg(kind AttributeKind, value interface{}) (interface{}, error) {
+	switch kind {
+	case KindNumber, KindBool:
+		number, err := t.cv.toFloat64(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number: %v", value)
+		}
+		return number, nil
+	case KindDate:
+		date, err := t.cv.toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		return date.Format("2006-01-02 15:04:05"), nil
+	default:
+		switch v := value.(type) {
+		case string, float64, bool:
+			return v, nil
+		default:
+			return nil, fmt.Errorf("unsupported value: %v", value)
+		}
+	}
+}
+
+// placeholder records an argument and returns its placeholder.
+func (t *SQLTranslator) placeholder(query *sqlQuery, arg interface{}) string {
+	query.args = append(query.args, arg)
+	if t.mapping.Placeholder != nil {
+		return t.mapping.Placeholder(len(query.args))
+	}
+	return "?"
+}
+
+// escapeLike escapes the LIKE wildcards and the escape character itself.
+// The escape character is !, since a backslash would need escaping again in
+// MySQL string literals.
+func (t *SQLTranslator) escapeLike(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
+
+// PostgresPlaceholder numbers placeholders as $1, $2, ...
+func PostgresPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
@@ -0,0 +1,300 @@
+package validator
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed testdata/orders.sql
+var ordersFixture string
+
+// loadOrders opens the order fixture in an in-memory SQLite database and reads
+// its orders back as carts, keyed by order ID.
+func loadOrders(t *testing.T) (*sql.DB, map[int]Cart) {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(ordersFixture); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	cv := NewConditionValidator()
+	carts := make(map[int]Cart)
+	rows, err := db.Query(`SELECT o.id, o.subtotal, COALESCE(o.country, ''), COALESCE(o.city, ''),
+		c.id, c.group_id, c.email, c.created_at FROM orders o JOIN customers c ON c.id = o.customer_id`)
+	if err != nil {
+		t.Fatalf("Failed to query orders: %v", err)
+	}
+	for rows.Next() {
+		var id int
+		var cart Cart
+		var createdAt string
+		if err := rows.Scan(&id, &cart.Subtotal, &cart.ShippingAddress.Country, &cart.ShippingAddress.City,
+			&cart.Customer.ID, &cart.Customer.GroupID, &cart.Customer.Email, &createdAt); err != nil {
+			t.Fatalf("Failed to scan order: %v", err)
+		}
+		if cart.Customer.CreatedAt, err = cv.toTime(createdAt); err != nil {
+			t.Fatalf("Failed to parse date: %v", err)
+		}
+		carts[id] = cart
+	}
+	rows.Close()
+
+	rows, err = db.Query(`SELECT i.id, i.order_id, i.sku, COALESCE(i.name, ''), i.qty, i.price FROM order_items i ORDER BY i.id`)
+	if err != nil {
+		t.Fatalf("Failed to query items: %v", err)
+	}
+	var itemIDs []int
+	var orderIDs []int
+	var items []Item
+	for rows.Next() {
+		var itemID, orderID int
+		item := Item{ProductType: ProductTypeSimple}
+		if err := rows.Scan(&itemID, &orderID, &item.SKU, &item.Name, &item.Quantity, &item.Price); err != nil {
+			t.Fatalf("Failed to scan item: %v", err)
+		}
+		itemIDs, orderIDs, items = append(itemIDs, itemID), append(orderIDs, orderID), append(items, item)
+	}
+	rows.Close()
+	for i, item := range items {
+		categories, err := db.Query(`SELECT category_id FROM order_item_categories WHERE item_id = ?`, itemIDs[i])
+		if err != nil {
+			t.Fatalf("Failed to query categories: %v", err)
+		}
+		for categories.Next() {
+			var id int
+			categories.Scan(&id)
+			item.CategoryIDs = append(item.CategoryIDs, id)
+		}
+		categories.Close()
+		cart := carts[orderIDs[i]]
+		cart.Items = append(cart.Items, item)
+		carts[orderIDs[i]] = cart
+	}
+	return db, carts
+}
+
+func TestSQLTranslator(t *testing.T) {
+	db, carts := loadOrders(t)
+	translator := NewSQLTranslator(DefaultSQLMapping())
+	cv := NewConditionValidator()
+
+	shoes := Condition{Type: TypeProduct, Attribute: "category_ids", Operator: "()", Value: []interface{}{"7"}}
+	tests := []struct {
+		name      string
+		condition Condition
+		want      []int
+	}{
+		{
+			name:      "Subtotal",
+			condition: Condition{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "110"},
+			want:      []int{101, 103, 105},
+		},
+		{
+			name: "Found two of a category",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				shoes, {Type: TypeProduct, Attribute: "qty", Operator: ">=", Value: "2"},
+			}},
+			want: []int{101, 105},
+		},
+		{
+			name: "Socks not found",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "0", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "SOCK-1"},
+			}},
+			want: []int{102, 103, 105},
+		},
+		{
+			name: "Subselect row total of shoes",
+			condition: Condition{Type: TypeSubselect, Attribute: "base_row_total", Operator: ">", Value: "100", Aggregator: "all",
+				Conditions: []Condition{shoes}},
+			want: []int{101, 105},
+		},
+		{
+			name: "Any false combine with missing country",
+			condition: Condition{Type: TypeCombine, Aggregator: "any", Value: "0", Conditions: []Condition{
+				{Type: TypeAddress, Attribute: "country_id", Operator: "==", Value: "US"},
+			}},
+			want: []int{102, 104, 105},
+		},
+		{
+			name: "Customer group and date",
+			condition: Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeCustomer, Attribute: "group_id", Operator: "()", Value: []interface{}{"1"}},
+				{Type: TypeCustomer, Attribute: "created_at", Operator: "<", Value: "2024-01-01"},
+			}},
+			want: []int{101, 103},
+		},
+		{
+			name:      "Contains is case-insensitive",
+			condition: Condition{Type: TypeCustomer, Attribute: "email", Operator: "{}", Value: "WHOLESALE"},
+			want:      []int{102, 105},
+		},
+		{
+			name:      "Like escapes underscores",
+			condition: Condition{Type: TypeAddress, Attribute: "city", Operator: "like", Value: "berlin_%"},
+			want:      []int{104},
+		},
+		{
+			name:      "Like escapes percent signs in contains",
+			condition: Condition{Type: TypeProduct, Attribute: "name", Operator: "{}", Value: "50%"},
+			want:      []int{103},
+		},
+		{
+			name:      "Like keeps backslashes and exclamation marks literal",
+			condition: Condition{Type: TypeProduct, Attribute: "name", Operator: "{}", Value: `p \ sale!`},
+			want:      []int{104},
+		},
+		{
+			name:      "Like wildcards around an exclamation mark",
+			condition: Condition{Type: TypeProduct, Attribute: "name", Operator: "like", Value: "cap%sale!"},
+			want:      []int{104},
+		},
+		{
+			name: "Missing item name is null",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "name", Operator: "null"},
+			}},
+			want: []int{105},
+		},
+		{
+			name: "Not in category",
+			condition: Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+				{Type: TypeProduct, Attribute: "category_ids", Operator: "!()", Value: []interface{}{"7", "9"}},
+			}},
+			want: []int{103, 104},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args, err := translator.Where(tt.condition)
+			if err != nil {
+				t.Fatalf("Where() error = %v", err)
+			}
+			rows, err := db.Query("SELECT o.id FROM orders o WHERE "+where+" ORDER BY o.id", args...)
+			if err != nil {
+				t.Fatalf("Query failed: %v\n%s", err, where)
+			}
+			defer rows.Close()
+			var got []int
+			for rows.Next() {
+				var id int
+				rows.Scan(&id)
+				got = append(got, id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SQL matched %v, want %v\n%s %v", got, tt.want, where, args)
+			}
+
+			// The validator must agree with the database
+			var validated []int
+			for _, id := range []int{101, 102, 103, 104, 105} {
+				valid, err := cv.Validate(tt.condition, carts[id])
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				if valid {
+					validated = append(validated, id)
+				}
+			}
+			if !reflect.DeepEqual(validated, tt.want) {
+				t.Errorf("Validate matched %v, want %v", validated, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLTranslatorNumericStrings(t *testing.T) {
+	db, _ := loadOrders(t)
+	if _, err := db.Exec("UPDATE order_items SET sku = '10.0' WHERE id = 8"); err != nil {
+		t.Fatal(err)
+	}
+	condition := Condition{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "10"},
+	}}
+
+	// The database compares the SKU as text
+	where, args, err := NewSQLTranslator(DefaultSQLMapping()).Where(condition)
+	if err != nil {
+		t.Fatalf("Where() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM orders o WHERE "+where, args...).Scan(&count); err != nil {
+		t.Fatalf("Query failed: %v\n%s", err, where)
+	}
+	if count != 0 {
+		t.Errorf("SQL matched %d orders, want none", count)
+	}
+
+	// The validator compares it as a number
+	valid, err := NewConditionValidator().Validate(condition, Cart{Items: []Item{{SKU: "10.0", Quantity: 1, Price: 100}}})
+	if err != nil || !valid {
+		t.Errorf("Validate() = %v, %v, want a match", valid, err)
+	}
+}
+
+func TestSQLTranslatorPlaceholders(t *testing.T) {
+	mapping := DefaultSQLMapping()
+	mapping.Placeholder = PostgresPlaceholder
+	where, args, err := NewSQLTranslator(mapping).Where(Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "100"},
+		{Type: TypeCustomer, Attribute: "group_id", Operator: "!()", Value: []interface{}{"0", "4"}},
+	}})
+	if err != nil {
+		t.Fatalf("Where() error = %v", err)
+	}
+	want := "((COALESCE(o.subtotal, 0) >= $1) AND (EXISTS (SELECT 1 FROM customers c WHERE c.id = o.customer_id AND COALESCE(c.group_id, 0) NOT IN ($2, $3))))"
+	if where != want {
+		t.Errorf("Where() = %s, want %s", where, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{100.0, 0.0, 4.0}) {
+		t.Errorf("args = %v", args)
+	}
+
+	_, _, err = NewSQLTranslator(mapping).Where(Condition{Type: TypeCustomer, Attribute: "loyalty_tier", Operator: "==", Value: "gold"})
+	if err == nil || !strings.Contains(err.Error(), "loyalty_tier") {
+		t.Errorf("Where() error = %v, want unmapped attribute error", err)
+	}
+}
+
+func TestSQLTranslatorBillingAndPaths(t *testing.T) {
+	translator := NewSQLTranslator(DefaultSQLMapping())
+	where, args, err := translator.Where(Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "billing_address.country_id", Operator: "==", Value: "DE"},
+		{Type: TypeAddress, Attribute: "billing_address.base_subtotal", Operator: ">", Value: "50"},
+		{Type: TypeAddress, Attribute: "shipping_address.city", Operator: "==", Value: "Berlin"},
+	}})
+	if err != nil {
+		t.Fatalf("Where() error = %v", err)
+	}
+	want := "((COALESCE(o.billing_country, '') = ?) AND (COALESCE(o.subtotal, 0) > ?) AND (COALESCE(o.city, '') = ?))"
+	if where != want {
+		t.Errorf("Where() = %s, want %s", where, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"DE", 50.0, "Berlin"}) {
+		t.Errorf("args = %v", args)
+	}
+
+	for _, condition := range []Condition{
+		{Type: TypeAddress, Attribute: "billing_address.street[0]", Operator: "{}", Value: "Main"},
+		{Type: TypeCustomer, Attribute: "addresses[*].country", Operator: "==", Value: "US"},
+		{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "attributes.color", Operator: "==", Value: "red"},
+		}},
+	} {
+		if _, _, err := translator.Where(condition); !errors.Is(err, ErrUnsupportedPath) {
+			t.Errorf("Where(%s) error = %v, want ErrUnsupportedPath", condition.Attribute, err)
+		}
+	}
+}
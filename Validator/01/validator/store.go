@@ -0,0 +1,235 @@
+package validator
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DirectoryStore is a RuleStore keeping each rule in its own JSON file,
+// rule-<id>.json, holding the rule's versions oldest first. Files are
+// replaced atomically, so readers never see a partly written rule, and are
+// easy to review and to keep under version control.
+type DirectoryStore struct {
+	dir string
+	mu  sync.RWMutex
+}
+
+// NewDirectoryStore creates a DirectoryStore in dir, creating the directory if needed.
+func NewDirectoryStore(dir string) (*DirectoryStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create rule directory: %v", err)
+	}
+	return &DirectoryStore{dir: dir}, nil
+}
+
+// Append stores a new version of a rule.
+func (s *DirectoryStore) Append(version RuleVersion) error {
+	if version.RuleID <= 0 {
+		return fmt.Errorf("invalid rule ID %d", version.RuleID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	versions, err := s.read(version.RuleID)
+	if err != nil {
+		return err
+	}
+	if err := nextVersion(versions, version); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(append(versions, version), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode rule %d: %v", version.RuleID, err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".rule-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(version.RuleID))
+}
+
+// Versions returns the versions of a rule, oldest first.
+func (s *DirectoryStore) Versions(ruleID int) ([]RuleVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.read(ruleID)
+}
+
+// RuleIDs returns the IDs of the rules in the directory, in ascending order.
+// Files not named like rule files are ignored.
+func (s *DirectoryStore) RuleIDs() ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[int]bool)
+	for _, entry := range entries {
+		if id, ok := ruleFileID(entry.Name()); ok && !entry.IsDir() {
+			ids[id] = true
+		}
+	}
+	return sortedIDs(ids), nil
+}
+
+// Close does nothing; the files need no cleanup.
+func (s *DirectoryStore) Close() error {
+	return nil
+}
+
+// path returns the file of a rule.
+func (s *DirectoryStore) path(ruleID int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("rule-%d.json", ruleID))
+}
+
+// read returns the versions in a rule's file, none if there is no file.
+func (s *DirectoryStore) read(ruleID int) ([]RuleVersion, error) {
+	data, err := os.ReadFile(s.path(ruleID))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []RuleVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path(ruleID), err)
+	}
+	for i, version := range versions {
+		if version.RuleID != ruleID || version.Version != i+1 {
+			return nil, fmt.Errorf("%s: entry %d is version %d of rule %d", s.path(ruleID), i, version.Version, version.RuleID)
+		}
+	}
+	return versions, nil
+}
+
+// ruleFileID returns the rule ID of a file named rule-<id>.json.
+func ruleFileID(name string) (int, bool) {
+	if !strings.HasPrefix(name, "rule-") || !strings.HasSuffix(name, ".json") {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "rule-"), ".json"))
+	return id, err == nil && id > 0
+}
+
+// boltRulesBucket holds a bucket per rule, keyed by rule ID, holding the
+// rule's versions keyed by version number.
+var boltRulesBucket = []byte("rules")
+
+// BoltStore is a RuleStore in an embedded bbolt key-value database, for
+// deployments that want a single file with transactional writes.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens or creates the bbolt database at path. Only one process
+// can open the database at a time.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rule database: %v", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltRulesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise rule database: %v", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Append stores a new version of a rule.
+func (s *BoltStore) Append(version RuleVersion) error {
+	if version.RuleID <= 0 {
+		return fmt.Errorf("invalid rule ID %d", version.RuleID)
+	}
+	data, err := json.Marshal(version)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule %d: %v", version.RuleID, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		rule, err := tx.Bucket(boltRulesBucket).CreateBucketIfNotExists(boltKey(version.RuleID))
+		if err != nil {
+			return err
+		}
+		stored := 0
+		if last, _ := rule.Cursor().Last(); last != nil {
+			stored = int(binary.BigEndian.Uint64(last))
+		}
+		if version.Version != stored+1 {
+			return fmt.Errorf("%w: rule %d is at version %d, cannot store version %d", ErrVersionConflict, version.RuleID, stored, version.Version)
+		}
+		return rule.Put(boltKey(version.Version), data)
+	})
+}
+
+// Versions returns the versions of a rule, oldest first.
+func (s *BoltStore) Versions(ruleID int) ([]RuleVersion, error) {
+	var versions []RuleVersion
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if ruleID <= 0 {
+			return nil
+		}
+		rule := tx.Bucket(boltRulesBucket).Bucket(boltKey(ruleID))
+		if rule == nil {
+			return nil
+		}
+		return rule.ForEach(func(_, data []byte) error {
+			var version RuleVersion
+			if err := json.Unmarshal(data, &version); err != nil {
+				return fmt.Errorf("rule %d: %v", ruleID, err)
+			}
+			versions = append(versions, version)
+			return nil
+		})
+	})
+	return versions, err
+}
+
+// RuleIDs returns the IDs of the stored rules, in ascending order.
+func (s *BoltStore) RuleIDs() ([]int, error) {
+	var ids []int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltRulesBucket).ForEach(func(key, _ []byte) error {
+			ids = append(ids, int(binary.BigEndian.Uint64(key)))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// boltKey encodes a positive rule ID or version number so that keys sort numerically.
+func boltKey(n int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(n))
+	return key
+}
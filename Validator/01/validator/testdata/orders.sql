@@ -0,0 +1,54 @@
+CREATE TABLE customers (
+    id INTEGER PRIMARY KEY,
+    group_id INTEGER,
+    email TEXT,
+    created_at TEXT
+);
+
+CREATE TABLE orders (
+    id INTEGER PRIMARY KEY,
+    customer_id INTEGER REFERENCES customers (id),
+    created_at TEXT,
+    subtotal REAL,
+    country TEXT,
+    city TEXT
+);
+
+CREATE TABLE order_items (
+    id INTEGER PRIMARY KEY,
+    order_id INTEGER REFERENCES orders (id),
+    sku TEXT,
+    name TEXT,
+    qty INTEGER,
+    price REAL
+);
+
+CREATE TABLE order_item_categories (
+    item_id INTEGER REFERENCES order_items (id),
+    category_id INTEGER
+);
+
+INSERT INTO customers VALUES
+    (1, 1, 'ann@example.com', '2021-05-01 10:00:00'),
+    (2, 2, 'bob@wholesale.example', '2023-11-20 08:30:00'),
+    (3, 1, 'cy@example.org', '2024-02-14 19:45:00');
+
+INSERT INTO orders VALUES
+    (101, 1, '2024-03-01 09:15:00', 110, 'US', 'Austin'),
+    (102, 2, '2024-03-01 17:40:00', 60, 'CA', 'Toronto'),
+    (103, 1, '2024-03-02 11:05:00', 240, 'US', NULL),
+    (104, 3, '2024-03-02 12:00:00', 35.5, 'DE', 'Berlin_Mitte'),
+    (105, 2, '2024-03-03 08:00:00', 500, NULL, 'Vancouver');
+
+INSERT INTO order_items VALUES
+    (1, 101, 'SHOE-1', 'Trail Shoe', 1, 80),
+    (2, 101, 'SOCK-1', 'Wool Sock', 2, 15),
+    (3, 102, 'SHOE-2', 'Road Shoe', 1, 60),
+    (4, 103, 'BAG-1', 'Duffel 50% off', 2, 120),
+    (5, 104, 'SOCK-1', 'Wool Sock', 1, 15),
+    (6, 104, 'CAP-1', 'Cap \ Sale!', 1, 20.5),
+    (7, 105, 'SHOE-1', 'Trail Shoe', 5, 80),
+    (8, 105, 'SHOE-3', NULL, 1, 100);
+
+INSERT INTO order_item_categories VALUES
+    (1, 7), (2, 7), (2, 9), (3, 7), (4, 12), (5, 9), (7, 7), (8, 7);
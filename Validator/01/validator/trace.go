@@ -0,0 +1,116 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceStep is one evaluated condition in a trace.
+type TraceStep struct {
+	Path        string        `json:"path"`
+	Depth       int           `json:"depth"`               // Nesting depth, the root condition being at depth 0
+	Type        string        `json:"type"`                // Short condition type, such as Product
+	Description string        `json:"description"`         // The condition as shown in the admin
+	Item        string        `json:"item,omitempty"`      // SKU of the item checked by item-level conditions
+	ItemPath    string        `json:"item_path,omitempty"` // Position of that item in the cart, resolved by ItemAt
+	Result      bool          `json:"result"`
+	Error       string        `json:"error,omitempty"`
+	Duration    time.Duration `json:"duration"`
+	Condition   Condition     `json:"-"`
+}
+
+// TraceRecorder is an Observer that records every evaluated condition, in the
+// order evaluation reached them, for explaining why a cart did or did not
+// match. Conditions checked once per item appear once per item.
+type TraceRecorder struct {
+	mu       sync.Mutex
+	renderer *Renderer
+	steps    []TraceStep
+}
+
+// traceStepKey is the context key of the position of the step in progress.
+type traceStepKey struct{}
+
+// NewTraceRecorder creates an empty TraceRecorder.
+func NewTraceRecorder() *TraceRecorder {
+	return &TraceRecorder{renderer: NewRenderer()}
+}
+
+// EnterNode records the condition as evaluated.
+func (r *TraceRecorder) EnterNode(ctx context.Context, event NodeEvent) context.Context {
+	step := TraceStep{
+		Path:        event.Path,
+		Depth:       strings.Count(event.Path, ".conditions["),
+		Type:        shortType(event.Condition.Type),
+		Description: r.renderer.label(event.Condition),
+		Condition:   event.Condition,
+	}
+	if event.Item != nil {
+		step.Item = event.Item.SKU
+		step.ItemPath = event.ItemPath
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+	return context.WithValue(ctx, traceStepKey{}, len(r.steps)-1)
+}
+
+// ExitNode records the result of the condition.
+func (r *TraceRecorder) ExitNode(ctx context.Context, event NodeEvent, result bool, err error, duration time.Duration) {
+	i, ok := ctx.Value(traceStepKey{}).(int)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i >= len(r.steps) {
+		return
+	}
+	r.steps[i].Result = result
+	r.steps[i].Duration = duration
+	if err != nil {
+		r.steps[i].Error = err.Error()
+	}
+}
+
+// Steps returns the recorded steps.
+func (r *TraceRecorder) Steps() []TraceStep {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TraceStep(nil), r.steps...)
+}
+
+// Reset discards the recorded steps.
+func (r *TraceRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = nil
+}
+
+// ItemAt returns the item at a position recorded in a trace, such as items[1]
+// or items[0].children[2], as item-level conditions saw it: children of
+// configurable items take quantity, pricing and missing attributes from the parent.
+func (cv *ConditionValidator) ItemAt(cart Cart, path string) (Item, error) {
+	segments, err := parseAttributePath(path)
+	if err != nil {
+		return Item{}, err
+	}
+	if len(segments) > 2 || !strings.EqualFold(segments[0].name, "items") || segments[0].quantifier != quantifyIndex ||
+		len(segments) == 2 && (!strings.EqualFold(segments[1].name, "children") || segments[1].quantifier != quantifyIndex) {
+		return Item{}, fmt.Errorf("invalid item position %q, want items[i] or items[i].children[j]", path)
+	}
+	if segments[0].index >= len(cart.Items) {
+		return Item{}, fmt.Errorf("no item at %s, the cart has %d items", path, len(cart.Items))
+	}
+	item := cart.Items[segments[0].index]
+	if len(segments) == 1 {
+		return item, nil
+	}
+	if segments[1].index >= len(item.Children) {
+		return Item{}, fmt.Errorf("no item at %s, %s has %d children", path, item.SKU, len(item.Children))
+	}
+	return cv.childItem(item, item.Children[segments[1].index]), nil
+}
@@ -0,0 +1,94 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	condition := Condition{Type: TypeCombine, Aggregator: "all", Value: "1", Conditions: []Condition{
+		{Type: TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "50"},
+		{Type: TypeProductFound, Aggregator: "all", Value: "1", Conditions: []Condition{
+			{Type: TypeProduct, Attribute: "sku", Operator: "==", Value: "MUG"},
+		}},
+	}}
+	cart := Cart{Subtotal: 60, Items: []Item{{SKU: "TEE", Quantity: 1, Price: 20}, {SKU: "MUG", Quantity: 1, Price: 40}}}
+
+	recorder := NewTraceRecorder()
+	matched, err := NewConditionValidator().WithObserver(recorder).Validate(condition, cart)
+	if err != nil || !matched {
+		t.Fatalf("Validate() = %v, %v, want a match", matched, err)
+	}
+	var got []string
+	for _, step := range recorder.Steps() {
+		got = append(got, step.Path+" "+step.ItemPath+" "+step.Item+" "+step.Description)
+		if step.Path == RootPath && !step.Result {
+			t.Errorf("root step result = false, want true")
+		}
+	}
+	want := []string{
+		"$   If ALL of these conditions are TRUE:",
+		"$.conditions[0]   Subtotal equals or greater than 50",
+		"$.conditions[1]   If an item is FOUND in the cart with ALL of these conditions true:",
+		"$.conditions[1].conditions[0] items[0] TEE SKU is MUG",
+		"$.conditions[1].conditions[0] items[1] MUG SKU is MUG",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Steps() = %q, want %q", got, want)
+	}
+	if steps := recorder.Steps(); steps[3].Result || !steps[4].Result || steps[3].Depth != 2 {
+		t.Errorf("item steps = %+v, want TEE false and MUG true at depth 2", steps[3:])
+	}
+
+	recorder.Reset()
+	if len(recorder.Steps()) != 0 {
+		t.Error("Steps() after Reset is not empty")
+	}
+}
+
+func TestTraceItemPaths(t *testing.T) {
+	condition := Condition{Type: TypeSubselect, Attribute: "qty", Operator: ">=", Value: "1", Aggregator: "all", Conditions: []Condition{
+		{Type: TypeProduct, Attribute: "color", Operator: "==", Value: "red"},
+	}}
+	cart := Cart{Items: []Item{
+		{SKU: "MUG", Quantity: 1, Price: 10, Attributes: map[string]interface{}{"color": "white"}},
+		{SKU: "TEE", ProductType: ProductTypeConfigurable, Quantity: 2, Price: 20, Attributes: map[string]interface{}{"color": "red"},
+			Children: []Item{{SKU: "TEE-S", Attributes: map[string]interface{}{"size": "S"}}}},
+	}}
+
+	recorder := NewTraceRecorder()
+	cv := NewConditionValidator().WithObserver(recorder)
+	if _, err := cv.Validate(condition, cart); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	var got []string
+	for _, step := range recorder.Steps()[1:] {
+		got = append(got, step.ItemPath+" "+step.Item)
+		item, err := cv.ItemAt(cart, step.ItemPath)
+		if err != nil || item.SKU != step.Item {
+			t.Errorf("ItemAt(%s) = %s, %v, want %s", step.ItemPath, item.SKU, err, step.Item)
+		}
+	}
+	if want := []string{"items[0] MUG", "items[1].children[0] TEE-S"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("item steps = %q, want %q", got, want)
+	}
+
+	child, _ := cv.ItemAt(cart, "items[1].children[0]")
+	if child.Quantity != 2 || child.Attributes["color"] != "red" {
+		t.Errorf("ItemAt() child = %+v, want the quantity and color of its parent", child)
+	}
+	for _, path := range []string{"items[2]", "items[1].children[1]", "items[*]", "customer.addresses[0]", "items[0].children"} {
+		if _, err := cv.ItemAt(cart, path); err == nil {
+			t.Errorf("ItemAt(%s) error = nil, want an error", path)
+		}
+	}
+}
+
+func TestKindOperators(t *testing.T) {
+	if got := KindOperators(KindIDs); !reflect.DeepEqual(got, []string{"()", "!()", "null", "notnull"}) {
+		t.Errorf("KindOperators(KindIDs) = %v", got)
+	}
+	if got := OperatorLabel("!{}"); got != "does not contain" {
+		t.Errorf("OperatorLabel() = %q", got)
+	}
+}
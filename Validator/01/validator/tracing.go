@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Span is a completed, OpenTelemetry-style span covering one evaluated condition.
+type Span struct {
+	TraceID    uint64
+	SpanID     uint64
+	ParentID   uint64 // Zero for the root condition
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Err        error
+}
+
+// SpanExporter receives spans as they end.
+type SpanExporter interface {
+	ExportSpan(span Span)
+}
+
+// InMemoryExporter keeps exported spans in memory, for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan stores the span.
+func (e *InMemoryExporter) ExportSpan(span Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the exported spans in the order they ended.
+func (e *InMemoryExporter) Spans() []Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Span(nil), e.spans...)
+}
+
+// Reset discards the exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// spanContextKey is the context key of the span in progress.
+type spanContextKey struct{}
+
+// SpanRecorder is an Observer that records a span per evaluated condition,
+// nested like the condition tree, and hands it to an exporter when it ends.
+type SpanRecorder struct {
+	exporter SpanExporter
+	lastID   atomic.Uint64
+}
+
+// NewSpanRecorder creates a SpanRecorder exporting to exporter.
+func NewSpanRecorder(exporter SpanExporter) *SpanRecorder {
+	return &SpanRecorder{exporter: exporter}
+}
+
+// EnterNode starts a span as a child of the span in ctx, if any.
+func (r *SpanRecorder) EnterNode(ctx context.Context, event NodeEvent) context.Context {
+	span := &Span{
+		SpanID: r.lastID.Add(1),
+		Name:   "Validate " + shortType(event.Condition.Type),
+		Start:  time.Now(),
+		Attributes: map[string]interface{}{
+			"condition.path": event.Path,
+		},
+	}
+	span.TraceID = span.SpanID
+	if parent, ok := ctx.Value(spanContextKey{}).(*Span); ok {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+	}
+	if event.Condition.Attribute != "" {
+		span.Attributes["condition.attribute"] = event.Condition.Attribute
+		span.Attributes["condition.operator"] = event.Condition.Operator
+	}
+	if event.Item != nil {
+		span.Attributes["item.sku"] = event.Item.SKU
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// ExitNode ends the span started by EnterNode and exports it.
+func (r *SpanRecorder) ExitNode(ctx context.Context, event NodeEvent, result bool, err error, duration time.Duration) {
+	span, ok := ctx.Value(spanContextKey{}).(*Span)
+	if !ok {
+		return
+	}
+	span.End = span.Start.Add(duration)
+	span.Attributes["condition.result"] = result
+	span.Err = err
+	r.exporter.ExportSpan(*span)
+}
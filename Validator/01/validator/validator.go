@@ -0,0 +1,545 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConditionValidator is the struct that will validate conditions.
+type ConditionValidator struct {
+	limits   Limits
+	observer Observer
+	segments SegmentProvider
+}
+
+// NewConditionValidator creates a new instance of ConditionValidator with the default limits.
+func NewConditionValidator() *ConditionValidator {
+	return &ConditionValidator{limits: DefaultLimits()}
+}
+
+// NewConditionValidatorWithLimits creates a ConditionValidator enforcing the given limits.
+func NewConditionValidatorWithLimits(limits Limits) *ConditionValidator {
+	return &ConditionValidator{limits: limits}
+}
+
+// WithObserver makes the validator report every evaluated condition to the observer.
+func (cv *ConditionValidator) WithObserver(observer Observer) *ConditionValidator {
+	cv.observer = observer
+	return cv
+}
+
+// WithSegmentProvider makes the validator look up customer segments for
+// segment conditions with the provider.
+func (cv *ConditionValidator) WithSegmentProvider(provider SegmentProvider) *ConditionValidator {
+	cv.segments = provider
+	return cv
+}
+
+// Validate evaluates a condition tree against the cart.
+// A condition that does not match returns false without an error; errors are
+// reserved for conditions that cannot be evaluated.
+//
+// Compatibility: earlier versions also returned an error describing the
+// failed condition when the cart did not match. Callers that treated any
+// error as "no match" should now check the boolean, and log or surface the
+// error as an evaluation failure.
+func (cv *ConditionValidator) Validate(condition Condition, cart Cart) (bool, error) {
+	return cv.ValidateContext(context.Background(), condition, cart)
+}
+
+// ValidateContext is like Validate but checks the condition against the
+// validator limits first and stops evaluating once ctx is done.
+func (cv *ConditionValidator) ValidateContext(ctx context.Context, condition Condition, cart Cart) (bool, error) {
+	if err := cv.CheckLimits(condition); err != nil {
+		return false, err
+	}
+	valid, err := cv.validate(ctx, RootPath, condition, cart)
+	if err != nil && ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	return valid, err
+}
+
+// validate evaluates a cart-level condition at the given path, reporting it to the observer.
+func (cv *ConditionValidator) validate(ctx context.Context, path string, condition Condition, cart Cart) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if cv.observer == nil {
+		return cv.validateNode(ctx, path, condition, cart)
+	}
+
+	event := NodeEvent{Path: path, Condition: condition}
+	start := time.Now()
+	ctx = cv.observer.EnterNode(ctx, event)
+	valid, err := cv.validateNode(ctx, path, condition, cart)
+	cv.observer.ExitNode(ctx, event, valid, err, time.Since(start))
+	return valid, err
+}
+
+// validateNode dispatches a cart-level condition to its validator.
+func (cv *ConditionValidator) validateNode(ctx context.Context, path string, condition Condition, cart Cart) (bool, error) {
+	switch condition.Type {
+	case TypeCombine:
+		return cv.validateCombine(ctx, path, condition, cart)
+	case TypeProduct:
+		return cv.validateProduct(ctx, condition, cart)
+	case TypeProductFound:
+		return cv.validateFound(ctx, path, condition, cart)
+	case TypeSubselect:
+		return cv.validateSubselect(ctx, path, condition, cart)
+	case TypeAddress:
+		return cv.validateAddress(condition, cart)
+	case TypeCustomer:
+		return cv.validateCustomer(condition, cart)
+	case TypeSegment:
+		return cv.validateSegment(condition, cart)
+	default:
+		return false, fmt.Errorf("unknown condition type: %s", condition.Type)
+	}
+}
+
+// ValidateItem evaluates an item-level condition (a Product condition or a
+// Product\Combine of them) against a single cart item.
+func (cv *ConditionValidator) ValidateItem(condition Condition, item Item) (bool, error) {
+	if err := cv.CheckLimits(condition); err != nil {
+		return false, err
+	}
+	return cv.validateItem(context.Background(), RootPath, condition, item)
+}
+
+// validateItem evaluates an item-level condition at the given path, reporting it to the observer.
+func (cv *ConditionValidator) validateItem(ctx context.Context, path string, condition Condition, item Item) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if cv.observer == nil {
+		return cv.validateItemNode(ctx, path, condition, item)
+	}
+
+	event := NodeEvent{Path: path, Condition: condition, Item: &item}
+	event.ItemPath, _ = ctx.Value(itemPathKey{}).(string)
+	start := time.Now()
+	ctx = cv.observer.EnterNode(ctx, event)
+	valid, err := cv.validateItemNode(ctx, path, condition, item)
+	cv.observer.ExitNode(ctx, event, valid, err, time.Since(start))
+	return valid, err
+}
+
+// itemPathKey is the context key of the position of the item being checked.
+type itemPathKey struct{}
+
+// withItemPath records the position of the item about to be checked for the
+// observer, which reports it with the item-level conditions.
+func (cv *ConditionValidator) withItemPath(ctx context.Context, path string) context.Context {
+	if cv.observer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, itemPathKey{}, path)
+}
+
+// validateItemNode dispatches an item-level condition to its validator.
+func (cv *ConditionValidator) validateItemNode(ctx context.Context, path string, condition Condition, item Item) (bool, error) {
+	switch condition.Type {
+	case TypeProduct:
+		return cv.validateProductItem(condition, item)
+	case TypeProductCombine:
+		return cv.aggregate(condition, cv.isTrue(condition), func(i int, subCondition Condition) (bool, error) {
+			return cv.validateItem(ctx, ChildPath(path, i), subCondition, item)
+		})
+	default:
+		return false, fmt.Errorf("condition type %s cannot be validated against an item", condition.Type)
+	}
+}
+
+// ActionItems returns the items that receive the discount of a rule with the
+// given action conditions. Configurable items and fixed-price bundles are
+// discounted on the parent, while bundles with IsChildrenCalculated pass the
+// discount to each matching child, returned with its total quantity in the
+// cart. Empty actions match every item.
+func (cv *ConditionValidator) ActionItems(actions Condition, cart Cart) ([]Item, error) {
+	return cv.ActionItemsContext(context.Background(), actions, cart)
+}
+
+// ActionItemsContext is like ActionItems but checks the validator limits and stops once ctx is done.
+func (cv *ConditionValidator) ActionItemsContext(ctx context.Context, actions Condition, cart Cart) ([]Item, error) {
+	lines, err := cv.actionLines(ctx, actions, cart)
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, line := range lines {
+		items = append(items, line.item)
+	}
+	return items, nil
+}
+
+// cartLine is an item of the cart with its position, as ItemPath and ChildItemPath give it.
+type cartLine struct {
+	path string
+	item Item
+}
+
+// actionLines returns the items the rule actions apply to with their positions in the cart.
+func (cv *ConditionValidator) actionLines(ctx context.Context, actions Condition, cart Cart) ([]cartLine, error) {
+	if err := cv.CheckLimits(actions); err != nil {
+		return nil, err
+	}
+
+	var lines []cartLine
+	for i, item := range cart.Items {
+		if item.IsChildrenCalculated && len(item.Children) > 0 {
+			for j, child := range item.Children {
+				path := ChildItemPath(ItemPath(i), j)
+				valid, err := cv.validateAction(cv.withItemPath(ctx, path), actions, cv.childItem(item, child))
+				if err != nil {
+					return nil, fmt.Errorf("action validation failed for child %s of %s: %v", child.SKU, item.SKU, err)
+				}
+				if valid {
+					child.Quantity *= item.Quantity
+					lines = append(lines, cartLine{path: path, item: child})
+				}
+			}
+			continue
+		}
+
+		valid, err := cv.validateAction(cv.withItemPath(ctx, ItemPath(i)), actions, item)
+		if err != nil {
+			return nil, fmt.Errorf("action validation failed for item %s: %v", item.SKU, err)
+		}
+		if valid {
+			lines = append(lines, cartLine{path: ItemPath(i), item: item})
+		}
+	}
+	return lines, nil
+}
+
+// validateAction checks an item against the rule actions, treating an unset action tree as matching.
+func (cv *ConditionValidator) validateAction(ctx context.Context, actions Condition, item Item) (bool, error) {
+	if actions.Type == "" && len(actions.Conditions) == 0 {
+		return true, nil
+	}
+	return cv.validateItem(ctx, RootPath, actions, item)
+}
+
+// aggregate combines subcondition results the way Magento's Combine does:
+// with "all" every subcondition must evaluate to expected, with "any" one is enough.
+// An empty aggregator defaults to "all" and an empty condition list is true.
+func (cv *ConditionValidator) aggregate(condition Condition, expected bool, validate func(int, Condition) (bool, error)) (bool, error) {
+	if len(condition.Conditions) == 0 {
+		return true, nil
+	}
+
+	switch condition.Aggregator {
+	case "all", "":
+		for i, subCondition := range condition.Conditions {
+			valid, err := validate(i, subCondition)
+			if err != nil {
+				return false, fmt.Errorf("subcondition validation error: %v", err)
+			}
+			if valid != expected {
+				return false, nil
+			}
+		}
+		return true, nil
+	case "any":
+		for i, subCondition := range condition.Conditions {
+			valid, err := validate(i, subCondition)
+			if err != nil {
+				return false, fmt.Errorf("subcondition validation error: %v", err)
+			}
+			if valid == expected {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown aggregator: %s", condition.Aggregator)
+	}
+}
+
+// isTrue reports whether a Combine-style condition expects its subconditions to be TRUE (value "1") or FALSE (value "0").
+func (cv *ConditionValidator) isTrue(condition Condition) bool {
+	return isTrueValue(condition.Value)
+}
+
+// isTrueValue interprets the value of a Combine-style condition, where a missing value means TRUE.
+func isTrueValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return v
+	case string:
+		return v != "0" && !strings.EqualFold(v, "false")
+	case float64:
+		return v != 0
+	default:
+		return true
+	}
+}
+
+// validateCombine validates the subconditions of a cart-level Combine condition.
+func (cv *ConditionValidator) validateCombine(ctx context.Context, path string, condition Condition, cart Cart) (bool, error) {
+	return cv.aggregate(condition, cv.isTrue(condition), func(i int, subCondition Condition) (bool, error) {
+		return cv.validate(ctx, ChildPath(path, i), subCondition, cart)
+	})
+}
+
+// validateItemConditions checks an item against the product subconditions of a Found or Subselect condition.
+func (cv *ConditionValidator) validateItemConditions(ctx context.Context, path string, condition Condition, item Item) (bool, error) {
+	return cv.aggregate(condition, true, func(i int, subCondition Condition) (bool, error) {
+		return cv.validateItem(ctx, ChildPath(path, i), subCondition, item)
+	})
+}
+
+// validateProduct validates a product condition placed directly in a cart-level
+// Combine: it matches when any visible item in the cart matches.
+func (cv *ConditionValidator) validateProduct(ctx context.Context, condition Condition, cart Cart) (bool, error) {
+	for _, item := range cart.Items {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		valid, err := cv.validateProductItem(condition, item)
+		if err != nil {
+			return false, err
+		}
+		if valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateProductItem validates a product condition (e.g., SKU, quantity) against one item.
+// As in Magento, a configurable item that does not match is checked again
+// through its selected child, so child attributes like SKU or color are honoured
+// even when the parent does not define them.
+func (cv *ConditionValidator) validateProductItem(condition Condition, item Item) (bool, error) {
+	valid, err := cv.matchItemAttribute(condition, item)
+	if valid {
+		return true, nil
+	}
+	if item.ProductType == ProductTypeConfigurable && len(item.Children) > 0 {
+		return cv.matchItemAttribute(condition, cv.childItem(item, item.Children[0]))
+	}
+	return false, err
+}
+
+// matchItemAttribute compares a single item attribute with the condition value.
+func (cv *ConditionValidator) matchItemAttribute(condition Condition, item Item) (bool, error) {
+	if isAttributePath(condition.Attribute) {
+		valid, err := cv.comparePath(item, trimEntityPrefix(condition.Attribute, "item", "product"), condition.Operator, condition.Value)
+		if err != nil {
+			return false, fmt.Errorf("comparison failed for item attribute %s: %v", condition.Attribute, err)
+		}
+		return valid, nil
+	}
+	itemValue, err := cv.getItemAttribute(item, condition.Attribute)
+	if err != nil {
+		return false, fmt.Errorf("failed to get attribute %s from item: %v", condition.Attribute, err)
+	}
+	valid, err := cv.compareValues(itemValue, condition.Operator, condition.Value)
+	if err != nil {
+		return false, fmt.Errorf("comparison failed for item attribute %s: %v", condition.Attribute, err)
+	}
+	return valid, nil
+}
+
+// childItem returns the child as conditions see it. Children of configurable
+// items take quantity and pricing from the parent and fall back to the
+// parent's attributes when they do not define their own.
+func (cv *ConditionValidator) childItem(parent, child Item) Item {
+	if parent.ProductType != ProductTypeConfigurable {
+		return child
+	}
+
+	child.Quantity = parent.Quantity
+	child.Price = parent.Price
+	child.FinalPrice = parent.FinalPrice
+	child.SpecialPrice = parent.SpecialPrice
+	if child.Name == "" {
+		child.Name = parent.Name
+	}
+	if child.Weight == 0 {
+		child.Weight = parent.Weight
+	}
+	if len(child.CategoryIDs) == 0 {
+		child.CategoryIDs = parent.CategoryIDs
+	}
+	if len(parent.Attributes) > 0 {
+		attributes := make(map[string]interface{}, len(parent.Attributes)+len(child.Attributes))
+		for k, v := range parent.Attributes {
+			attributes[k] = v
+		}
+		for k, v := range child.Attributes {
+			attributes[k] = v
+		}
+		child.Attributes = attributes
+	}
+	return child
+}
+
+// validateFound validates a Found condition: "If an item is FOUND (value 1) or
+// NOT FOUND (value 0) in the cart with ALL/ANY of these conditions true".
+func (cv *ConditionValidator) validateFound(ctx context.Context, path string, condition Condition, cart Cart) (bool, error) {
+	found := false
+	for i, item := range cart.Items {
+		valid, err := cv.validateItemConditions(cv.withItemPath(ctx, ItemPath(i)), path, condition, item)
+		if err != nil {
+			return false, fmt.Errorf("found validation failed for item %s: %v", item.SKU, err)
+		}
+		if valid {
+			found = true
+			break
+		}
+	}
+	return found == cv.isTrue(condition), nil
+}
+
+// validateSubselect validates a subselect condition (for subsets of products in the cart).
+// The attribute (qty or base_row_total) is summed over every visible item that
+// matches the subconditions, directly or through one of its children, and the
+// total is compared with the condition value. Matching bundle children
+// contribute their own totals multiplied by the bundle quantity.
+func (cv *ConditionValidator) validateSubselect(ctx context.Context, path string, condition Condition, cart Cart) (bool, error) {
+	var total float64
+	for i, item := range cart.Items {
+		value, err := cv.subselectItemTotal(cv.withItemPath(ctx, ItemPath(i)), path, condition, item)
+		if err != nil {
+			return false, err
+		}
+		total += value
+	}
+
+	valid, err := cv.compareValues(total, condition.Operator, condition.Value)
+	if err != nil {
+		return false, fmt.Errorf("subselect comparison failed: %v", err)
+	}
+	return valid, nil
+}
+
+// subselectItemTotal returns what a visible item contributes to the total of
+// a Subselect condition, which is zero when neither it nor a child matches.
+func (cv *ConditionValidator) subselectItemTotal(ctx context.Context, path string, condition Condition, item Item) (float64, error) {
+	useChildrenTotal := item.ProductType == ProductTypeBundle
+	hasValidChild := false
+	var childrenTotal float64
+
+	itemPath, _ := ctx.Value(itemPathKey{}).(string)
+	for j, child := range item.Children {
+		child = cv.childItem(item, child)
+		childPath := ""
+		if itemPath != "" {
+			childPath = ChildItemPath(itemPath, j)
+		}
+		valid, err := cv.validateItemConditions(cv.withItemPath(ctx, childPath), path, condition, child)
+		if err != nil {
+			return 0, fmt.Errorf("subselect validation failed for child %s of %s: %v", child.SKU, item.SKU, err)
+		}
+		if !valid {
+			continue
+		}
+		hasValidChild = true
+		if useChildrenTotal {
+			value, err := cv.itemNumericAttribute(child, condition.Attribute)
+			if err != nil {
+				return 0, err
+			}
+			childrenTotal += value
+		}
+	}
+
+	if hasValidChild && useChildrenTotal {
+		return childrenTotal * float64(item.Quantity), nil
+	}
+	if !hasValidChild {
+		valid, err := cv.validateItemConditions(ctx, path, condition, item)
+		if err != nil {
+			return 0, fmt.Errorf("subselect validation failed for item %s: %v", item.SKU, err)
+		}
+		if !valid {
+			return 0, nil
+		}
+	}
+	return cv.itemNumericAttribute(item, condition.Attribute)
+}
+
+// itemNumericAttribute returns a numeric item attribute used for subselect totals.
+func (cv *ConditionValidator) itemNumericAttribute(item Item, attribute string) (float64, error) {
+	value, err := cv.getItemAttribute(item, attribute)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get attribute %s from item: %v", attribute, err)
+	}
+	number, err := cv.toFloat64(value)
+	if err != nil {
+		return 0, fmt.Errorf("subselect attribute %s is not numeric: %v", attribute, err)
+	}
+	return number, nil
+}
+
+// validateAddress validates an address-related condition. The attribute reads
+// the shipping address unless it starts with billing_address.
+func (cv *ConditionValidator) validateAddress(condition Condition, cart Cart) (bool, error) {
+	billing, attribute := splitAddressAttribute(condition.Attribute)
+	address := cart.ShippingAddress
+	if billing {
+		address = cart.BillingAddress
+	}
+	if isAttributePath(attribute) {
+		valid, err := cv.comparePath(address, attribute, condition.Operator, condition.Value)
+		if err != nil {
+			return false, fmt.Errorf("address comparison failed: %v", err)
+		}
+		return valid, nil
+	}
+	addressValue, err := cv.getQuoteAddressAttribute(cart, address, attribute)
+	if err != nil {
+		return false, fmt.Errorf("failed to get address attribute %s: %v", condition.Attribute, err)
+	}
+	valid, err := cv.compareValues(addressValue, condition.Operator, condition.Value)
+	if err != nil {
+		return false, fmt.Errorf("address comparison failed: %v", err)
+	}
+	return valid, nil
+}
+
+// validateCustomer validates a customer-related condition.
+func (cv *ConditionValidator) validateCustomer(condition Condition, cart Cart) (bool, error) {
+	if isAttributePath(condition.Attribute) {
+		valid, err := cv.comparePath(cart.Customer, trimEntityPrefix(condition.Attribute, "customer"), condition.Operator, condition.Value)
+		if err != nil {
+			return false, fmt.Errorf("customer comparison failed: %v", err)
+		}
+		return valid, nil
+	}
+	customerValue, err := cv.getCustomerAttribute(cart.Customer, condition.Attribute)
+	if err != nil {
+		return false, fmt.Errorf("failed to get customer attribute %s: %v", condition.Attribute, err)
+	}
+	valid, err := cv.compareValues(customerValue, condition.Operator, condition.Value)
+	if err != nil {
+		return false, fmt.Errorf("customer comparison failed: %v", err)
+	}
+	return valid, nil
+}
+
+// compareValues compares values based on the operator (==, >=, <=, etc.)
+func (cv *ConditionValidator) compareValues(a interface{}, operator string, b interface{}) (bool, error) {
+	switch operator {
+	case "==", "!=", ">", ">=", "<", "<=":
+		return cv.compareNumericOrString(a, operator, b)
+	case "{}", "!{}":
+		return cv.compareContains(a, operator, b)
+	case "()", "!()":
+		return cv.compareInSet(a, operator, b)
+	case "null", "notnull":
+		return cv.compareNull(a, operator)
+	case "like", "nlike":
+		return cv.compareLike(a, operator, b)
+	default:
+		return false, fmt.Errorf("unknown operator: %s", operator)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package validator
 
 import (
 	"encoding/json"
@@ -22,7 +22,7 @@ func TestConditionValidator(t *testing.T) {
 			Customer: Customer{
 				ID: 1, GroupID: 2, Email: "test@example.com",
 				FirstName: "John", LastName: "Doe",
-				CreatedAt: time.Now().AddDate(-1, 0, 0), // Customer created 1 year ago
+				CreatedAt: time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC), // Customer registered before 2023
 				Orders:    5, TotalSpent: 500.0,
 			},
 			CouponCode: "TESTCODE",
@@ -319,3 +319,182 @@ func TestConditionValidatorNegativeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestInSetMatchesNumbers(t *testing.T) {
+	cv := NewConditionValidator()
+	cart := Cart{Customer: Customer{GroupID: 2, IsSubscribed: true}}
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+	}{
+		{name: "Number in a set of strings", condition: Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "()", Value: []interface{}{"1", "2"}}, want: true},
+		{name: "Number not in a set of strings", condition: Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "!()", Value: []interface{}{"2"}}, want: false},
+		{name: "Number in a set of numbers", condition: Condition{Type: TypeCustomer, Attribute: "group_id", Operator: "()", Value: []interface{}{1.0, 3.0}}, want: false},
+		{name: "Flag equals 1", condition: Condition{Type: TypeCustomer, Attribute: "is_subscribed", Operator: "==", Value: "1"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cv.Validate(tt.condition, cart)
+			if err != nil || got != tt.want {
+				t.Errorf("Validate() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionValidatorParentChildItems(t *testing.T) {
+	validator := NewConditionValidator()
+
+	createTestCart := func() Cart {
+		return Cart{
+			Items: []Item{
+				{
+					SKU: "SHIRT", Name: "Shirt", ProductType: ProductTypeConfigurable,
+					Quantity: 2, Price: 25.0, CategoryIDs: []int{10},
+					Children: []Item{
+						{SKU: "SHIRT-RED-M", ProductType: ProductTypeSimple, Attributes: map[string]interface{}{"color": "red"}},
+					},
+				},
+				{
+					SKU: "KIT", Name: "Starter Kit", ProductType: ProductTypeBundle,
+					Quantity: 3, IsChildrenCalculated: true, CategoryIDs: []int{20},
+					Children: []Item{
+						{SKU: "KIT-CABLE", ProductType: ProductTypeSimple, Quantity: 2, Price: 5.0, CategoryIDs: []int{30}},
+						{SKU: "KIT-CHARGER", ProductType: ProductTypeSimple, Quantity: 1, Price: 15.0, CategoryIDs: []int{40}},
+					},
+				},
+			},
+			Subtotal: 230.0,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		want      bool
+	}{
+		{
+			name: "Found item by child SKU",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "SHIRT-RED-M"}
+				]
+			}`,
+			want: true,
+		},
+		{
+			name: "Found item by child attribute with parent quantity",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "1",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "color", "operator": "==", "value": "red"},
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "quantity", "operator": ">=", "value": "2"}
+				]
+			}`,
+			want: true,
+		},
+		{
+			name: "Not found item",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Found",
+				"value": "0",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "SHIRT-BLUE-M"}
+				]
+			}`,
+			want: true,
+		},
+		{
+			name: "Subselect counts configurable parent quantity",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect",
+				"attribute": "qty",
+				"operator": "==",
+				"value": "2",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "SHIRT-RED-M"}
+				]
+			}`,
+			want: true,
+		},
+		{
+			name: "Subselect multiplies bundle children by bundle quantity",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Subselect",
+				"attribute": "qty",
+				"operator": "==",
+				"value": "6",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "category_ids", "operator": "()", "value": ["30"]}
+				]
+			}`,
+			want: true,
+		},
+		{
+			name: "Combine expecting false subconditions",
+			condition: `{
+				"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Combine",
+				"value": "0",
+				"aggregator": "all",
+				"conditions": [
+					{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "MISSING"}
+				]
+			}`,
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var condition Condition
+			err := json.Unmarshal([]byte(tt.condition), &condition)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal condition: %v", err)
+			}
+
+			cart := createTestCart()
+			got, err := validator.Validate(condition, cart)
+			if err != nil {
+				t.Fatalf("Validator.Validate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Validator.Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("Discount goes to configurable parent and dynamic bundle children", func(t *testing.T) {
+		var actions Condition
+		err := json.Unmarshal([]byte(`{
+			"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product\\Combine",
+			"value": "1",
+			"aggregator": "any",
+			"conditions": [
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "SHIRT-RED-M"},
+				{"type": "Magento\\SalesRule\\Model\\Rule\\Condition\\Product", "attribute": "sku", "operator": "==", "value": "KIT-CHARGER"}
+			]
+		}`), &actions)
+		if err != nil {
+			t.Fatalf("Failed to unmarshal actions: %v", err)
+		}
+
+		items, err := validator.ActionItems(actions, createTestCart())
+		if err != nil {
+			t.Fatalf("Validator.ActionItems() error = %v", err)
+		}
+		var skus []string
+		for _, item := range items {
+			skus = append(skus, item.SKU)
+		}
+		if len(skus) != 2 || skus[0] != "SHIRT" || skus[1] != "KIT-CHARGER" {
+			t.Errorf("Validator.ActionItems() = %v, want [SHIRT KIT-CHARGER]", skus)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+// Package web serves a browser UI for authoring sales rules: editing the
+// condition tree, previewing it in English, test-evaluating it against a
+// pasted cart with a trace, and saving it to a rule repository.
+package web
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+)
+
+//go:embed static
+var static embed.FS
+
+// Server handles the UI and its JSON API.
+type Server struct {
+	repo     *validator.RuleRepository
+	segments validator.SegmentProvider
+}
+
+// NewServer creates a Server saving rules to repo.
+func NewServer(repo *validator.RuleRepository) *Server {
+	return &Server{repo: repo}
+}
+
+// WithSegmentProvider makes test evaluations look up customer segments with the provider.
+func (s *Server) WithSegmentProvider(provider validator.SegmentProvider) *Server {
+	s.segments = provider
+	return s
+}
+
+// Register adds the UI and API routes to the router.
+func (s *Server) Register(router gin.IRouter) {
+	files, _ := fs.Sub(static, "static")
+	router.GET("/", func(c *gin.Context) {
+		c.FileFromFS("/", http.FS(files))
+	})
+	router.StaticFS("/static", http.FS(files))
+
+	api := router.Group("/api")
+	api.GET("/schema", s.schema)
+	api.POST("/preview", s.preview)
+	api.POST("/evaluate", s.evaluate)
+	api.GET("/rules", s.listRules)
+	api.POST("/rules", s.createRule)
+	api.GET("/rules/:id", s.getRule)
+	api.PUT("/rules/:id", s.updateRule)
+	api.GET("/rules/:id/history", s.ruleHistory)
+}
+
+// Handler returns a gin engine serving the UI.
+func (s *Server) Handler() http.Handler {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	s.Register(router)
+	return router
+}
+
+// attributeSchema describes an attribute the editor offers.
+type attributeSchema struct {
+	Code      string   `json:"code"`
+	Kind      string   `json:"kind"`
+	Operators []string `json:"operators"`
+}
+
+// typeSchema describes a condition type the editor offers.
+type typeSchema struct {
+	Type       string            `json:"type"`
+	Label      string            `json:"label"`
+	Container  bool              `json:"container"`  // Holds subconditions
+	ItemLevel  bool              `json:"item_level"` // Evaluated against each item, under a Found, Subselect or actions
+	Attributes []attributeSchema `json:"attributes,omitempty"`
+}
+
+// schema returns the condition types, attributes and operators the editor offers.
+func (s *Server) schema(c *gin.Context) {
+	types := []typeSchema{
+		{Type: validator.TypeCombine, Label: "Conditions combination", Container: true},
+		{Type: validator.TypeProductFound, Label: "Product found in cart", Container: true},
+		{Type: validator.TypeSubselect, Label: "Products subselection", Container: true, Attributes: []attributeSchema{
+			{Code: "qty", Kind: string(validator.KindNumber)},
+			{Code: "base_row_total", Kind: string(validator.KindNumber)},
+		}},
+		{Type: validator.TypeProductCombine, Label: "Product conditions combination", Container: true, ItemLevel: true},
+		{Type: validator.TypeProduct, Label: "Product attribute", ItemLevel: true},
+		{Type: validator.TypeAddress, Label: "Cart attribute"},
+		{Type: validator.TypeCustomer, Label: "Customer attribute"},
+		{Type: validator.TypeSegment, Label: "Customer segment"},
+	}
+	for i := range types {
+		for _, code := range validator.KnownAttributes(types[i].Type) {
+			kind, _ := validator.LookupAttribute(types[i].Type, code)
+			types[i].Attributes = append(types[i].Attributes, attributeSchema{Code: code, Kind: string(kind)})
+		}
+		for j := range types[i].Attributes {
+			types[i].Attributes[j].Operators = validator.KindOperators(validator.AttributeKind(types[i].Attributes[j].Kind))
+		}
+	}
+	labels := make(map[string]string)
+	for _, operator := range validator.KindOperators(validator.KindString) {
+		labels[operator] = validator.OperatorLabel(operator)
+	}
+	c.JSON(http.StatusOK, gin.H{"types": types, "operator_labels": labels})
+}
+
+// previewRequest is a condition tree to preview.
+type previewRequest struct {
+	Conditions validator.Condition  `json:"conditions"`
+	Actions    *validator.Condition `json:"actions"`
+}
+
+// preview renders conditions in English and lints them.
+func (s *Server) preview(c *gin.Context) {
+	var req previewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	renderer := validator.NewRenderer()
+	response := gin.H{
+		"text":        renderer.Text(req.Conditions),
+		"html":        renderer.HTML(req.Conditions),
+		"diagnostics": nonNil(validator.NewLinter().Lint(req.Conditions)),
+	}
+	if expression, err := validator.FormatExpression(req.Conditions); err == nil {
+		response["expression"] = expression
+	} else {
+		response["expression_error"] = err.Error()
+	}
+	if req.Actions != nil {
+		response["actions_text"] = renderer.Text(*req.Actions)
+		response["actions_diagnostics"] = nonNil(validator.NewLinter().LintActions(*req.Actions))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// evaluateRequest is a rule to test against a cart.
+type evaluateRequest struct {
+	Rule validator.Rule `json:"rule"`
+	Cart validator.Cart `json:"cart"`
+}
+
+// evaluate validates a rule against a cart, returning the trace of the
+// conditions and the discount the rule would give.
+func (s *Server) evaluate(c *gin.Context) {
+	var req evaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	recorder := validator.NewTraceRecorder()
+	cv := validator.NewConditionValidator().WithObserver(recorder)
+	if s.segments != nil {
+		cv.WithSegmentProvider(s.segments)
+	}
+	// A rule tested in the editor applies regardless of its activation
+	req.Rule.IsActive = true
+
+	response := gin.H{}
+	matched, err := cv.ValidateRule(req.Rule, req.Cart)
+	response["matched"] = matched
+	response["trace"] = nonNil(recorder.Steps())
+	if err != nil {
+		response["error"] = err.Error()
+	} else if matched {
+		if discount, err := cv.CalculateDiscount(req.Rule, req.Cart); err == nil {
+			response["discount"] = discount
+		}
+	}
+	if variant := req.Rule.Variant(req.Cart.Customer); variant != "" {
+		response["variant"] = variant
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// saveRequest is a rule to save with the author and reason of the change.
+type saveRequest struct {
+	Rule        validator.Rule `json:"rule"`
+	BaseVersion int            `json:"base_version"` // The version the change was made to, required for updates
+	Author      string         `json:"author"`
+	Message     string         `json:"message"`
+}
+
+// listRules returns the current rules.
+func (s *Server) listRules(c *gin.Context) {
+	rules, err := s.repo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nonNil(rules))
+}
+
+// getRule returns the current version of a rule.
+func (s *Server) getRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+	rule, err := s.repo.Get(id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// ruleHistory returns every version of a rule.
+func (s *Server) ruleHistory(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+	history, err := s.repo.History(id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// createRule saves a new rule.
+func (s *Server) createRule(c *gin.Context) {
+	req, ok := s.bindRule(c)
+	if !ok {
+		return
+	}
+	version, err := s.repo.Create(req.Rule, req.Author, req.Message)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, version)
+}
+
+// updateRule saves a new version of a rule, answering 409 Conflict when the
+// rule changed after the version the client edited.
+func (s *Server) updateRule(c *gin.Context) {
+	id, ok := ruleID(c)
+	if !ok {
+		return
+	}
+	req, ok := s.bindRule(c)
+	if !ok {
+		return
+	}
+	if req.BaseVersion <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base_version is required"})
+		return
+	}
+	req.Rule.ID = id
+	version, err := s.repo.Update(req.Rule, req.BaseVersion, req.Author, req.Message)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// bindRule decodes a rule to save, rejecting rules whose conditions or
+// actions have lint errors, and rules the rule loader would not load, such as
+// ones with an invalid experiment or beyond the validator limits. Unset
+// conditions and actions are not linted.
+func (s *Server) bindRule(c *gin.Context) (saveRequest, bool) {
+	var req saveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	if req.Author == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "author is required"})
+		return req, false
+	}
+	if req.Rule.ID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule ID"})
+		return req, false
+	}
+	linter := validator.NewLinter()
+	var diagnostics []validator.Diagnostic
+	if !validator.IsEmptyCondition(req.Rule.Conditions) {
+		diagnostics = linter.Lint(req.Rule.Conditions)
+	}
+	if !validator.IsEmptyCondition(req.Rule.Actions) {
+		diagnostics = append(diagnostics, linter.LintActions(req.Rule.Actions)...)
+	}
+	if validator.HasErrors(diagnostics) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "the rule has errors", "diagnostics": diagnostics})
+		return req, false
+	}
+	if err := validator.NewConditionValidator().CheckRule(req.Rule); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return req, false
+	}
+	return req, true
+}
+
+// ruleID parses the rule ID of the request path.
+func ruleID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// errorStatus returns the HTTP status of a repository error. Errors other
+// than a missing rule or a clash with existing rules come from the store,
+// such as failed reads and writes, and are server errors.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, validator.ErrRuleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, validator.ErrVersionConflict), errors.Is(err, validator.ErrRuleExists):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// nonNil returns an empty slice for nil, so that it encodes as [] rather than null.
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
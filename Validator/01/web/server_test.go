@@ -0,0 +1,198 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/na-ho/Work/Validator/01/validator"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	m.Run()
+}
+
+func newTestServer(t *testing.T) http.Handler {
+	t.Helper()
+	store, err := validator.NewDirectoryStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewServer(validator.NewRuleRepository(store)).Handler()
+}
+
+func do(t *testing.T, handler http.Handler, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var response map[string]interface{}
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") && rec.Body.Len() > 0 && rec.Body.Bytes()[0] == '{' {
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return rec.Code, response
+}
+
+var testConditions = validator.Condition{Type: validator.TypeCombine, Aggregator: "all", Value: "1", Conditions: []validator.Condition{
+	{Type: validator.TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "50"},
+	{Type: validator.TypeProductFound, Aggregator: "all", Value: "1", Conditions: []validator.Condition{
+		{Type: validator.TypeProduct, Attribute: "sku", Operator: "==", Value: "MUG"},
+	}},
+}}
+
+func TestServerPages(t *testing.T) {
+	handler := newTestServer(t)
+	for _, path := range []string{"/", "/static/editor.js", "/static/editor.css"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s = %d with %d bytes, want the file", path, rec.Code, rec.Body.Len())
+		}
+	}
+
+	code, schema := do(t, handler, http.MethodGet, "/api/schema", nil)
+	if code != http.StatusOK {
+		t.Fatalf("GET /api/schema = %d", code)
+	}
+	types := schema["types"].([]interface{})
+	var product map[string]interface{}
+	for _, entry := range types {
+		if entry.(map[string]interface{})["type"] == validator.TypeProduct {
+			product = entry.(map[string]interface{})
+		}
+	}
+	if product == nil || len(product["attributes"].([]interface{})) == 0 {
+		t.Errorf("schema has no product attributes: %v", product)
+	}
+}
+
+func TestServerPreviewAndEvaluate(t *testing.T) {
+	handler := newTestServer(t)
+
+	code, preview := do(t, handler, http.MethodPost, "/api/preview", map[string]interface{}{"conditions": testConditions})
+	if code != http.StatusOK || !strings.Contains(preview["text"].(string), "Subtotal equals or greater than 50") {
+		t.Errorf("POST /api/preview = %d %v", code, preview)
+	}
+
+	cart := validator.Cart{Subtotal: 60, Items: []validator.Item{{SKU: "TEE", Quantity: 1, Price: 20}, {SKU: "MUG", Quantity: 1, Price: 40}}}
+	rule := validator.Rule{Conditions: testConditions, SimpleAction: validator.ActionByPercent, DiscountAmount: 10}
+	code, result := do(t, handler, http.MethodPost, "/api/evaluate", map[string]interface{}{"rule": rule, "cart": cart})
+	if code != http.StatusOK || result["matched"] != true {
+		t.Fatalf("POST /api/evaluate = %d %v, want a match", code, result)
+	}
+	if discount := result["discount"].(float64); discount != 6 {
+		t.Errorf("discount = %v, want 6", discount)
+	}
+	trace := result["trace"].([]interface{})
+	if len(trace) != 5 {
+		t.Fatalf("trace has %d steps, want 5", len(trace))
+	}
+	if step := trace[3].(map[string]interface{}); step["item"] != "TEE" || step["result"] != false {
+		t.Errorf("trace step 3 = %v, want TEE not matching", step)
+	}
+
+	code, _ = do(t, handler, http.MethodPost, "/api/evaluate", map[string]interface{}{"rule": "not a rule"})
+	if code != http.StatusBadRequest {
+		t.Errorf("POST /api/evaluate with a malformed body = %d, want 400", code)
+	}
+}
+
+func TestServerRules(t *testing.T) {
+	handler := newTestServer(t)
+	rule := validator.Rule{Name: "Mug promo", IsActive: true, Conditions: testConditions}
+
+	code, _ := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": rule})
+	if code != http.StatusBadRequest {
+		t.Errorf("POST /api/rules without an author = %d, want 400", code)
+	}
+	code, created := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": rule, "author": "ann"})
+	if code != http.StatusCreated || created["rule_id"] != 1.0 {
+		t.Fatalf("POST /api/rules = %d %v", code, created)
+	}
+
+	broken := rule
+	broken.Conditions = validator.Condition{Type: validator.TypeCombine, Aggregator: "all", Value: "1", Conditions: []validator.Condition{
+		{Type: validator.TypeAddress, Attribute: "base_subtotal", Operator: ">=", Value: "lots"},
+	}}
+	code, rejected := do(t, handler, http.MethodPut, "/api/rules/1", map[string]interface{}{"rule": broken, "base_version": 1, "author": "bob"})
+	if code != http.StatusUnprocessableEntity || len(rejected["diagnostics"].([]interface{})) == 0 {
+		t.Errorf("PUT /api/rules/1 with lint errors = %d %v, want 422 with diagnostics", code, rejected)
+	}
+
+	rule.Name = "Mug promo, renamed"
+	if code, _ := do(t, handler, http.MethodPut, "/api/rules/1", map[string]interface{}{"rule": rule, "author": "bob"}); code != http.StatusBadRequest {
+		t.Errorf("PUT /api/rules/1 without a base version = %d, want 400", code)
+	}
+	code, updated := do(t, handler, http.MethodPut, "/api/rules/1", map[string]interface{}{"rule": rule, "base_version": 1, "author": "bob", "message": "Rename"})
+	if code != http.StatusOK || updated["version"] != 2.0 {
+		t.Errorf("PUT /api/rules/1 = %d %v, want version 2", code, updated)
+	}
+	stale := rule
+	stale.Name = "Mug promo, edited elsewhere"
+	if code, _ := do(t, handler, http.MethodPut, "/api/rules/1", map[string]interface{}{"rule": stale, "base_version": 1, "author": "ann"}); code != http.StatusConflict {
+		t.Errorf("PUT /api/rules/1 from version 1 after version 2 = %d, want 409", code)
+	}
+	code, got := do(t, handler, http.MethodGet, "/api/rules/1", nil)
+	if code != http.StatusOK || got["name"] != "Mug promo, renamed" {
+		t.Errorf("GET /api/rules/1 = %d %v", code, got)
+	}
+	if code, _ := do(t, handler, http.MethodGet, "/api/rules/2", nil); code != http.StatusNotFound {
+		t.Errorf("GET /api/rules/2 = %d, want 404", code)
+	}
+	if code, _ := do(t, handler, http.MethodPut, "/api/rules/2", map[string]interface{}{"rule": rule, "base_version": 1, "author": "bob"}); code != http.StatusNotFound {
+		t.Errorf("PUT /api/rules/2 = %d, want 404", code)
+	}
+
+	unconditional := validator.Rule{Name: "Everything 5% off", IsActive: true, SimpleAction: "by_percent", DiscountAmount: 5}
+	if code, created := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": unconditional, "author": "ann"}); code != http.StatusCreated {
+		t.Errorf("POST /api/rules without conditions or actions = %d %v, want 201", code, created)
+	}
+
+	taken := rule
+	taken.ID = 1
+	if code, _ := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": taken, "author": "ann"}); code != http.StatusConflict {
+		t.Errorf("POST /api/rules with a taken ID = %d, want 409", code)
+	}
+	unloadable := rule
+	unloadable.Variants = []string{"B"}
+	if code, _ := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": unloadable, "author": "ann"}); code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /api/rules with variants but no experiment = %d, want 422", code)
+	}
+}
+
+// failingStore is a RuleStore whose writes fail.
+type failingStore struct{}
+
+func (failingStore) Append(validator.RuleVersion) error                   { return errors.New("disk full") }
+func (failingStore) Versions(ruleID int) ([]validator.RuleVersion, error) { return nil, nil }
+func (failingStore) RuleIDs() ([]int, error)                              { return nil, nil }
+func (failingStore) Close() error                                         { return nil }
+
+func TestServerStoreErrors(t *testing.T) {
+	handler := NewServer(validator.NewRuleRepository(failingStore{})).Handler()
+	rule := validator.Rule{Name: "Mug promo", IsActive: true, Conditions: testConditions}
+	if code, _ := do(t, handler, http.MethodPost, "/api/rules", map[string]interface{}{"rule": rule, "author": "ann"}); code != http.StatusInternalServerError {
+		t.Errorf("POST /api/rules with a failing store = %d, want 500", code)
+	}
+}
@@ -0,0 +1,320 @@
+'use strict';
+
+// Types that hold subconditions, and which child types each may add
+const cartChildren = ['Combine', 'Found', 'Subselect', 'Address', 'Customer', 'Segment'];
+const itemChildren = ['ProductCombine', 'Product'];
+
+const sampleCart = {
+  Items: [
+    { SKU: 'TEE-RED-M', Name: 'T-shirt', Quantity: 2, Price: 20, CategoryIDs: [3, 12] },
+    { SKU: 'MUG', Name: 'Mug', Quantity: 1, Price: 12.5, CategoryIDs: [7] }
+  ],
+  Subtotal: 52.5,
+  ShippingAddress: { Country: 'US', Region: 'Texas', City: 'Austin', PostalCode: '78701' },
+  Customer: { ID: 42, GroupID: 1, Email: 'ann@example.com', Orders: 3, TotalSpent: 310 }
+};
+
+let schema = { types: [], operator_labels: {} };
+let rule = newRule();
+let baseVersion = 0; // The stored version of the open rule, sent with updates
+let previewTimer = null;
+
+function newRule() {
+  return {
+    id: 0, name: '', is_active: true, sort_order: 0, simple_action: 'by_percent', discount_amount: 0,
+    conditions: { type: typeByShort('Combine'), aggregator: 'all', value: '1', conditions: [] }
+  };
+}
+
+// Short names of condition types, as the editor menus show them
+function shortName(type) {
+  // Product\Combine before Combine, which it also ends with
+  const names = {
+    'Product\\Combine': 'ProductCombine', 'Combine': 'Combine', 'Found': 'Found', 'Subselect': 'Subselect',
+    'Product': 'Product', 'Address': 'Address', 'Customer': 'Customer', 'Segment': 'Segment'
+  };
+  for (const [suffix, name] of Object.entries(names)) {
+    if (type.endsWith('\\' + suffix)) {
+      return name;
+    }
+  }
+  return type;
+}
+
+function typeByShort(name) {
+  const found = schema.types.find(t => shortName(t.type) === name);
+  return found ? found.type : name;
+}
+
+function typeSchema(type) {
+  return schema.types.find(t => t.type === type) || { type: type, label: type, attributes: [] };
+}
+
+function el(tag, props, ...children) {
+  const node = document.createElement(tag);
+  Object.assign(node, props || {});
+  for (const child of children) {
+    node.append(child);
+  }
+  return node;
+}
+
+function select(options, value, onChange) {
+  const node = el('select');
+  for (const [optionValue, label] of options) {
+    node.append(el('option', { value: optionValue, textContent: label, selected: optionValue === value }));
+  }
+  node.addEventListener('change', () => onChange(node.value));
+  return node;
+}
+
+function input(value, onChange) {
+  const node = el('input', { type: 'text', value: value == null ? '' : String(value) });
+  node.addEventListener('input', () => onChange(node.value));
+  return node;
+}
+
+// renderNode draws a condition and its subconditions. remove is null for the root.
+function renderNode(condition, remove) {
+  const schemaType = typeSchema(condition.type);
+  const node = el('div', { className: 'node' + (schemaType.container ? ' container' : '') });
+  node.append(el('strong', { textContent: schemaType.label + ' ' }));
+
+  if (schemaType.container) {
+    if (shortName(condition.type) === 'Subselect') {
+      node.append(...comparison(condition, schemaType));
+    }
+    node.append(select([['all', 'ALL'], ['any', 'ANY']], condition.aggregator || 'all', v => { condition.aggregator = v; changed(); }));
+    const expected = shortName(condition.type) === 'Found' ? [['1', 'FOUND'], ['0', 'NOT FOUND']] : [['1', 'TRUE'], ['0', 'FALSE']];
+    if (shortName(condition.type) !== 'Subselect') {
+      node.append(select(expected, String(condition.value ?? '1'), v => { condition.value = v; changed(); }));
+    }
+  } else if (shortName(condition.type) === 'Segment') {
+    node.append(select([['()', 'is one of'], ['!()', 'is not one of']], condition.operator || '()', v => { condition.operator = v; changed(); }));
+    node.append(input(condition.value, v => { condition.value = v; changed(); }));
+  } else {
+    node.append(...comparison(condition, schemaType));
+  }
+  if (remove) {
+    node.append(el('button', { type: 'button', className: 'remove', textContent: 'Remove', onclick: remove }));
+  }
+
+  if (schemaType.container) {
+    condition.conditions = condition.conditions || [];
+    const children = el('div', { className: 'children' });
+    condition.conditions.forEach((child, i) => {
+      children.append(renderNode(child, () => { condition.conditions.splice(i, 1); redraw(); }));
+    });
+    const allowed = (schemaType.item_level || ['Found', 'Subselect'].includes(shortName(condition.type))) ? itemChildren : cartChildren;
+    const add = select([['', 'Add condition…'], ...allowed.map(name => [name, typeSchema(typeByShort(name)).label])], '', name => {
+      condition.conditions.push(blankCondition(typeByShort(name)));
+      redraw();
+    });
+    children.append(add);
+    node.append(children);
+  }
+  return node;
+}
+
+// comparison returns the attribute, operator and value controls of a condition.
+function comparison(condition, schemaType) {
+  const attributes = schemaType.attributes || [];
+  const attribute = attributes.find(a => a.code === condition.attribute);
+  const operators = attribute ? attributes.find(a => a.code === condition.attribute).operators : Object.keys(schema.operator_labels);
+  const attributeOptions = attributes.map(a => [a.code, a.code]);
+  if (condition.attribute && !attribute) {
+    attributeOptions.push([condition.attribute, condition.attribute]);
+  }
+  return [
+    select(attributeOptions, condition.attribute, v => {
+      condition.attribute = v;
+      const kind = attributes.find(a => a.code === v);
+      if (kind && !kind.operators.includes(condition.operator)) {
+        condition.operator = kind.operators[0];
+      }
+      redraw();
+    }),
+    select(operators.map(op => [op, schema.operator_labels[op] || op]), condition.operator, v => { condition.operator = v; changed(); }),
+    input(condition.value, v => { condition.value = v; changed(); })
+  ];
+}
+
+function blankCondition(type) {
+  const schemaType = typeSchema(type);
+  const condition = { type: type };
+  if (schemaType.container) {
+    Object.assign(condition, { aggregator: 'all', value: '1', conditions: [] });
+  }
+  if (schemaType.attributes && schemaType.attributes.length > 0) {
+    const first = schemaType.attributes[0];
+    Object.assign(condition, { attribute: first.code, operator: first.operators[0], value: '' });
+  }
+  if (shortName(type) === 'Segment') {
+    Object.assign(condition, { operator: '()', value: '' });
+  }
+  return condition;
+}
+
+function redraw() {
+  const tree = document.getElementById('tree');
+  tree.replaceChildren(renderNode(rule.conditions, null));
+  changed();
+}
+
+// changed refreshes the rule JSON and schedules a preview.
+function changed() {
+  readSettings();
+  document.getElementById('rule-json').textContent = JSON.stringify(rule, null, 2);
+  clearTimeout(previewTimer);
+  previewTimer = setTimeout(preview, 300);
+}
+
+function readSettings() {
+  rule.id = Number(document.getElementById('rule-id').value) || 0;
+  rule.name = document.getElementById('rule-name').value;
+  rule.sort_order = Number(document.getElementById('rule-sort').value) || 0;
+  rule.simple_action = document.getElementById('rule-action').value;
+  rule.discount_amount = Number(document.getElementById('rule-amount').value) || 0;
+  rule.is_active = document.getElementById('rule-active').checked;
+}
+
+function writeSettings() {
+  document.getElementById('rule-id').value = rule.id || '';
+  document.getElementById('rule-name').value = rule.name || '';
+  document.getElementById('rule-sort').value = rule.sort_order || 0;
+  document.getElementById('rule-action').value = rule.simple_action || 'by_percent';
+  document.getElementById('rule-amount').value = rule.discount_amount || 0;
+  document.getElementById('rule-active').checked = !!rule.is_active;
+}
+
+async function api(method, path, body) {
+  const response = await fetch(path, {
+    method: method,
+    headers: { 'Content-Type': 'application/json' },
+    body: body === undefined ? undefined : JSON.stringify(body)
+  });
+  const data = await response.json();
+  if (!response.ok) {
+    const error = new Error(data.error || response.statusText);
+    error.diagnostics = data.diagnostics;
+    throw error;
+  }
+  return data;
+}
+
+async function preview() {
+  try {
+    const result = await api('POST', '/api/preview', { conditions: rule.conditions });
+    document.getElementById('preview-html').innerHTML = result.html;
+    document.getElementById('preview-expression').textContent = result.expression ||
+      (result.expression_error ? 'No expression: ' + result.expression_error : '');
+    showDiagnostics(result.diagnostics);
+  } catch (err) {
+    showDiagnostics([{ severity: 'error', path: '$', message: err.message }]);
+  }
+}
+
+function showDiagnostics(diagnostics) {
+  const list = document.getElementById('diagnostics');
+  list.replaceChildren(...(diagnostics || []).map(d =>
+    el('li', { className: d.severity, textContent: `${d.path}: ${d.message}` })));
+}
+
+async function evaluate() {
+  const outcome = document.getElementById('outcome');
+  const body = document.querySelector('#trace tbody');
+  body.replaceChildren();
+  let cart;
+  try {
+    cart = JSON.parse(document.getElementById('cart').value);
+  } catch (err) {
+    outcome.textContent = 'The cart is not valid JSON: ' + err.message;
+    return;
+  }
+  try {
+    readSettings();
+    const result = await api('POST', '/api/evaluate', { rule: rule, cart: cart });
+    let text = result.matched ? 'The rule matches the cart' : 'The rule does not match the cart';
+    if (result.discount !== undefined) {
+      text += `, giving a discount of ${result.discount.toFixed(2)}`;
+    }
+    if (result.variant) {
+      text += ` (experiment variant ${result.variant})`;
+    }
+    outcome.textContent = result.error ? 'Error: ' + result.error : text + '.';
+    for (const step of result.trace) {
+      const description = el('td', { textContent: step.description });
+      description.style.paddingLeft = (0.4 + step.depth * 1.5) + 'em';
+      const status = step.error ? el('td', { className: 'error', textContent: step.error })
+        : el('td', { className: String(step.result), textContent: step.result ? 'true' : 'false' });
+      const item = [step.item_path, step.item].filter(Boolean).join(' ');
+      body.append(el('tr', {}, description, el('td', { textContent: item }), status));
+    }
+  } catch (err) {
+    outcome.textContent = 'Error: ' + err.message;
+  }
+}
+
+async function save() {
+  const status = document.getElementById('save-status');
+  readSettings();
+  const body = {
+    rule: rule,
+    base_version: baseVersion,
+    author: document.getElementById('author').value,
+    message: document.getElementById('message').value
+  };
+  try {
+    const exists = rule.id && [...document.getElementById('rules').options].some(o => Number(o.value) === rule.id);
+    const version = exists ? await api('PUT', '/api/rules/' + rule.id, body) : await api('POST', '/api/rules', body);
+    rule = version.rule;
+    baseVersion = version.version;
+    writeSettings();
+    redraw();
+    status.textContent = `Saved rule ${version.rule_id} as version ${version.version}.`;
+    await loadRules(version.rule_id);
+  } catch (err) {
+    status.textContent = 'Not saved: ' + err.message;
+    if (err.diagnostics) {
+      showDiagnostics(err.diagnostics);
+    }
+  }
+}
+
+async function loadRules(selected) {
+  const rules = await api('GET', '/api/rules');
+  const list = document.getElementById('rules');
+  list.replaceChildren(el('option', { value: '', textContent: 'New rule' }),
+    ...rules.map(r => el('option', { value: r.id, textContent: `${r.id}: ${r.name}`, selected: r.id === selected })));
+}
+
+async function openRule(id) {
+  // The history gives the rule together with the version it is at
+  const latest = id ? (await api('GET', '/api/rules/' + id + '/history')).pop() : null;
+  rule = latest ? latest.rule : newRule();
+  baseVersion = latest ? latest.version : 0;
+  if (!rule.conditions || !rule.conditions.type) {
+    rule.conditions = newRule().conditions;
+  }
+  writeSettings();
+  redraw();
+}
+
+async function start() {
+  schema = await api('GET', '/api/schema');
+  rule = newRule();
+  baseVersion = 0;
+  document.getElementById('cart').value = JSON.stringify(sampleCart, null, 2);
+  for (const id of ['rule-id', 'rule-name', 'rule-sort', 'rule-action', 'rule-amount', 'rule-active']) {
+    document.getElementById(id).addEventListener('change', changed);
+  }
+  document.getElementById('rules').addEventListener('change', e => openRule(Number(e.target.value)));
+  document.getElementById('reload').addEventListener('click', () => loadRules(rule.id));
+  document.getElementById('evaluate').addEventListener('click', evaluate);
+  document.getElementById('save-rule').addEventListener('click', save);
+  writeSettings();
+  redraw();
+  await loadRules();
+}
+
+start();